@@ -9,6 +9,9 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/dap"
+	"src.elv.sh/pkg/format"
+	"src.elv.sh/pkg/lint"
 	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
@@ -19,5 +22,6 @@ func main() {
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
 			&buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&dap.Program{}, &format.Program{}, &lint.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
@@ -7,6 +7,9 @@ import (
 
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
+	"src.elv.sh/pkg/dap"
+	"src.elv.sh/pkg/format"
+	"src.elv.sh/pkg/lint"
 	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/pprof"
 	"src.elv.sh/pkg/prog"
@@ -18,5 +21,6 @@ func main() {
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
 			&pprof.Program{}, &buildinfo.Program{}, &daemon.Program{}, &lsp.Program{},
+			&dap.Program{}, &format.Program{}, &lint.Program{},
 			&shell.Program{ActivateDaemon: daemon.Activate})))
 }
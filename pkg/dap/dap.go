@@ -0,0 +1,48 @@
+// Package dap implements a debug adapter for Elvish, speaking the Debug Adapter
+// Protocol (DAP).
+//
+// The adapter runs a single script, which is given in the arguments of the
+// "launch" request. It supports breakpoints by file and line, stepping, pausing,
+// and inspecting the call stack and variables of the paused script.
+package dap
+
+import (
+	"os"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the DAP subprogram.
+type Program struct {
+	run bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "dap", false, "Run the builtin debug adapter")
+}
+
+func (p *Program) Run(fds [3]*os.File, _ []string) error {
+	if !p.run {
+		return prog.NextProgram()
+	}
+	// DAP uses the same base protocol as LSP, so the framing of messages can be
+	// handled by jsonrpc2, even though the messages themselves are not JSON-RPC.
+	stream := jsonrpc2.NewBufferedStream(transport{fds[0], fds[1]}, jsonrpc2.VSCodeObjectCodec{})
+	s := newServer(stream)
+	s.serve()
+	return nil
+}
+
+type transport struct{ in, out *os.File }
+
+func (c transport) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c transport) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func (c transport) Close() error {
+	if err := c.in.Close(); err != nil {
+		c.out.Close()
+		return err
+	}
+	return c.out.Close()
+}
@@ -0,0 +1,267 @@
+package dap
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"src.elv.sh/pkg/testutil"
+)
+
+var script = `fn f {|x|
+  var y = (+ $x 1)
+  echo $y
+}
+f 1
+echo done
+`
+
+func TestBreakpointAndInspection(t *testing.T) {
+	c, path := setup(t)
+	c.launch(map[string]any{"program": path})
+	bps := c.request("setBreakpoints", map[string]any{
+		"source": map[string]any{"path": path}, "breakpoints": []any{map[string]any{"line": 2}}})
+	if s := string(bps); !strings.Contains(s, `"verified":true`) {
+		t.Errorf("setBreakpoints returns %s, want verified breakpoints", s)
+	}
+	c.request("configurationDone", nil)
+
+	c.expectStopped("breakpoint")
+	c.expectStack(stackFrame{Name: "var y = (+ $x 1)", Line: 2}, stackFrame{Name: "f 1", Line: 5})
+
+	var scopes scopesResponse
+	c.decode(c.request("scopes", map[string]any{"frameId": 0}), &scopes)
+	if len(scopes.Scopes) != 2 {
+		t.Errorf("got scopes %v, want 2 scopes", scopes.Scopes)
+	}
+	c.expectVariables(localsRef, variable{Name: "$x", Value: "1"}, variable{Name: "$y", Value: "$nil"})
+
+	c.request("next", nil)
+	c.expectStopped("step")
+	c.expectStack(stackFrame{Name: "echo $y", Line: 3}, stackFrame{Name: "f 1", Line: 5})
+	c.expectVariables(localsRef, variable{Name: "$x", Value: "1"}, variable{Name: "$y", Value: "(num 2)"})
+
+	var result evaluateResponse
+	c.decode(c.request("evaluate", map[string]any{"expression": "put $x $y"}), &result)
+	if result.Result != "1 (num 2)" {
+		t.Errorf("evaluate returns %q, want %q", result.Result, "1 (num 2)")
+	}
+
+	c.request("continue", nil)
+	c.expectExited(0)
+	if c.output != "2\ndone\n" {
+		t.Errorf("got output %q, want %q", c.output, "2\ndone\n")
+	}
+}
+
+func TestStepping(t *testing.T) {
+	c, path := setup(t)
+	c.launch(map[string]any{"program": path, "stopOnEntry": true})
+	c.request("configurationDone", nil)
+
+	c.expectStopped("entry")
+	c.expectStack(stackFrame{Name: "fn f {|x|", Line: 1})
+	c.request("next", nil)
+	c.expectStopped("step")
+	c.expectStack(stackFrame{Name: "f 1", Line: 5})
+	c.request("stepIn", nil)
+	c.expectStopped("step")
+	c.expectStack(stackFrame{Name: "var y = (+ $x 1)", Line: 2}, stackFrame{Name: "f 1", Line: 5})
+	c.request("stepOut", nil)
+	c.expectStopped("step")
+	c.expectStack(stackFrame{Name: "echo done", Line: 6})
+	c.request("continue", nil)
+	c.expectExited(0)
+}
+
+func TestErrors(t *testing.T) {
+	c, path := setup(t)
+	c.expectError("continue", nil, errNotPaused.Error())
+	c.expectError("configurationDone", nil, errNotLaunched.Error())
+	c.expectError("foo", nil, "unsupported command foo")
+
+	os.WriteFile(path, []byte("fail bad\n"), 0o600)
+	c.launch(map[string]any{"program": path})
+	c.request("configurationDone", nil)
+	c.expectExited(2)
+	if !strings.Contains(c.errOutput, "bad") {
+		t.Errorf("got error output %q, want it to contain the exception", c.errOutput)
+	}
+}
+
+func setup(t *testing.T) (*client, string) {
+	path := filepath.Join(testutil.TempDir(t), "a.elv")
+	os.WriteFile(path, []byte(script), 0o600)
+
+	serverConn, clientConn := net.Pipe()
+	s := newServer(jsonrpc2.NewBufferedStream(serverConn, jsonrpc2.VSCodeObjectCodec{}))
+	served := make(chan struct{})
+	go func() {
+		s.serve()
+		close(served)
+	}()
+	clientConn.SetDeadline(time.Now().Add(testutil.Scaled(10 * time.Second)))
+	c := &client{t: t, stream: jsonrpc2.NewBufferedStream(clientConn, jsonrpc2.VSCodeObjectCodec{})}
+	t.Cleanup(func() {
+		c.request("disconnect", nil)
+		<-served
+	})
+
+	c.request("initialize", map[string]any{"adapterID": "elvish"})
+	c.expectEvent("initialized")
+	return c, path
+}
+
+type client struct {
+	t      *testing.T
+	stream jsonrpc2.ObjectStream
+	seq    int
+	// Output events received so far.
+	output, errOutput string
+}
+
+type message struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *client) launch(args map[string]any) {
+	c.t.Helper()
+	c.request("launch", args)
+}
+
+// Sends a request and returns the body of the successful response.
+func (c *client) request(command string, args any) json.RawMessage {
+	c.t.Helper()
+	msg := c.send(command, args)
+	if !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+	return msg.Body
+}
+
+func (c *client) expectError(command string, args any, wantMessage string) {
+	c.t.Helper()
+	msg := c.send(command, args)
+	if msg.Success || msg.Message != wantMessage {
+		c.t.Errorf("%s: got success %v, message %q; want failure with message %q",
+			command, msg.Success, msg.Message, wantMessage)
+	}
+}
+
+func (c *client) send(command string, args any) message {
+	c.t.Helper()
+	c.seq++
+	err := c.stream.WriteObject(map[string]any{
+		"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatalf("write %s request: %v", command, err)
+	}
+	for {
+		msg := c.read()
+		if msg.Type == "response" {
+			if msg.Command != command {
+				c.t.Fatalf("got response to %s, want response to %s", msg.Command, command)
+			}
+			return msg
+		}
+		c.t.Fatalf("got %s event before response to %s", msg.Event, command)
+	}
+}
+
+// Reads the next message that is not an output event.
+func (c *client) read() message {
+	c.t.Helper()
+	for {
+		var msg message
+		if err := c.stream.ReadObject(&msg); err != nil {
+			c.t.Fatalf("read message: %v", err)
+		}
+		if msg.Type == "event" && msg.Event == "output" {
+			var output outputEvent
+			c.decode(msg.Body, &output)
+			if output.Category == "stderr" {
+				c.errOutput += output.Output
+			} else {
+				c.output += output.Output
+			}
+			continue
+		}
+		return msg
+	}
+}
+
+func (c *client) expectEvent(name string) json.RawMessage {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Type != "event" || msg.Event != name {
+		c.t.Fatalf("got %s %s%s, want %s event", msg.Type, msg.Command, msg.Event, name)
+	}
+	return msg.Body
+}
+
+func (c *client) expectStopped(reason string) {
+	c.t.Helper()
+	var stopped stoppedEvent
+	c.decode(c.expectEvent("stopped"), &stopped)
+	if stopped.Reason != reason {
+		c.t.Errorf("stopped with reason %q, want %q", stopped.Reason, reason)
+	}
+}
+
+func (c *client) expectExited(code int) {
+	c.t.Helper()
+	var exited exitedEvent
+	c.decode(c.expectEvent("exited"), &exited)
+	if exited.ExitCode != code {
+		c.t.Errorf("exited with %d, want %d", exited.ExitCode, code)
+	}
+	c.expectEvent("terminated")
+}
+
+// Checks the name and line of the stack frames.
+func (c *client) expectStack(want ...stackFrame) {
+	c.t.Helper()
+	var resp stackTraceResponse
+	c.decode(c.request("stackTrace", map[string]any{"threadId": threadID}), &resp)
+	if len(resp.StackFrames) != len(want) {
+		c.t.Fatalf("got %d stack frames, want %d", len(resp.StackFrames), len(want))
+	}
+	for i, frame := range resp.StackFrames {
+		if frame.Name != want[i].Name || frame.Line != want[i].Line {
+			c.t.Errorf("frame %d is %q at line %d, want %q at line %d",
+				i, frame.Name, frame.Line, want[i].Name, want[i].Line)
+		}
+	}
+}
+
+func (c *client) expectVariables(ref int, want ...variable) {
+	c.t.Helper()
+	var resp variablesResponse
+	c.decode(c.request("variables", map[string]any{"variablesReference": ref}), &resp)
+	got := make(map[string]string)
+	for _, v := range resp.Variables {
+		got[v.Name] = v.Value
+	}
+	for _, v := range want {
+		if got[v.Name] != v.Value {
+			c.t.Errorf("variable %s is %q, want %q", v.Name, got[v.Name], v.Value)
+		}
+	}
+}
+
+func (c *client) decode(data json.RawMessage, v any) {
+	c.t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		c.t.Fatalf("decode %s: %v", data, err)
+	}
+}
@@ -0,0 +1,264 @@
+package dap
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+)
+
+// Controls the execution of the script being debugged, by pausing it in the
+// [eval.Evaler.BeforeForm] hook.
+
+type stepMode int
+
+const (
+	runMode stepMode = iota
+	stepInMode
+	stepOverMode
+	stepOutMode
+)
+
+// Position of a form.
+type location struct {
+	name string
+	line int
+	from int
+}
+
+// Reports whether l is on the same line as prev and comes after it, which is
+// typically the case for forms nested in prev, like the form in an output
+// capture. Stepping and breakpoints skip such forms, so that they stop at most
+// once per line. A form on the same line that doesn't come after prev, like the
+// only form in the body of a single-line loop, is still stopped at.
+func (l location) continues(prev location) bool {
+	return l.name == prev.name && l.line == prev.line && l.from > prev.from
+}
+
+type debugger struct {
+	// Called on the goroutine of the script when it stops.
+	onStop func(reason string)
+	// Commands to the paused goroutine.
+	commands chan command
+	// Serializes calls to beforeForm, so that forms running in parallel, like
+	// those in a pipeline, are paused one at a time.
+	execMu sync.Mutex
+	// Set when evaluating code in the paused Frame, which must not stop.
+	evaluating atomic.Bool
+
+	mu sync.Mutex
+	// Fields below are guarded by mu.
+	disabled    bool
+	breakpoints map[string]map[int]bool
+	mode        stepMode
+	stepDepth   int
+	// If not empty, the next form stops with this reason.
+	pauseReason string
+	// Location of the last stop.
+	lastStop location
+	// Location of the last form that was checked for breakpoints.
+	prev location
+	// Indices of line starts, indexed by source names.
+	lines  map[string]*lineIndex
+	paused *pausedState
+}
+
+type lineIndex struct {
+	code   string
+	starts []int
+}
+
+type pausedState struct {
+	fm    *eval.Frame
+	r     diag.Ranging
+	depth int
+}
+
+// A command to the paused goroutine.
+type command struct {
+	// If not nil, the function is called with the paused Frame, and the
+	// goroutine stays paused. Otherwise the goroutine resumes with mode.
+	eval func(*eval.Frame)
+	mode stepMode
+}
+
+func newDebugger(onStop func(reason string)) *debugger {
+	return &debugger{onStop: onStop, commands: make(chan command),
+		breakpoints: make(map[string]map[int]bool),
+		lines:       make(map[string]*lineIndex)}
+}
+
+func (d *debugger) beforeForm(fm *eval.Frame, r diag.Ranging) {
+	if d.evaluating.Load() {
+		return
+	}
+	src := fm.Source()
+	// Check whether the form may stop before waiting for execMu, so that forms
+	// running in parallel don't contend for it when there is nothing to stop
+	// at.
+	if !d.mayStop(src.Name) {
+		return
+	}
+	d.execMu.Lock()
+	defer d.execMu.Unlock()
+
+	d.mu.Lock()
+	// Check again, since the state may have changed while waiting.
+	if !d.mayStopLocked(src.Name) {
+		d.mu.Unlock()
+		return
+	}
+	loc := location{src.Name, d.line(src, r.From), r.From}
+	depth := stackDepth(fm)
+	reason := d.stopReason(loc, depth)
+	d.prev = loc
+	if reason == "" {
+		d.mu.Unlock()
+		return
+	}
+	d.pauseReason = ""
+	d.lastStop = loc
+	d.paused = &pausedState{fm, r, depth}
+	d.mu.Unlock()
+
+	d.onStop(reason)
+	for cmd := range d.commands {
+		if cmd.eval != nil {
+			d.evaluating.Store(true)
+			cmd.eval(fm)
+			d.evaluating.Store(false)
+			continue
+		}
+		d.mu.Lock()
+		d.mode = cmd.mode
+		d.stepDepth = depth
+		d.paused = nil
+		d.mu.Unlock()
+		return
+	}
+}
+
+// Reports whether a form in the named source may stop.
+func (d *debugger) mayStop(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.mayStopLocked(name)
+}
+
+// Must be called with mu held.
+func (d *debugger) mayStopLocked(name string) bool {
+	return !d.disabled && (d.mode != runMode || d.pauseReason != "" || d.breakpoints[name] != nil)
+}
+
+// Must be called with mu held.
+func (d *debugger) stopReason(loc location, depth int) string {
+	if d.pauseReason != "" {
+		return d.pauseReason
+	}
+	switch d.mode {
+	case stepInMode:
+		if !loc.continues(d.lastStop) {
+			return "step"
+		}
+	case stepOverMode:
+		if depth < d.stepDepth || (depth == d.stepDepth && !loc.continues(d.lastStop)) {
+			return "step"
+		}
+	case stepOutMode:
+		if depth < d.stepDepth {
+			return "step"
+		}
+	}
+	if d.breakpoints[loc.name][loc.line] && !loc.continues(d.prev) {
+		return "breakpoint"
+	}
+	return ""
+}
+
+// Returns the 1-based line number of a position in src. Must be called with mu
+// held.
+func (d *debugger) line(src parse.Source, pos int) int {
+	idx := d.lines[src.Name]
+	if idx == nil || idx.code != src.Code {
+		starts := []int{0}
+		for i := 0; i < len(src.Code); i++ {
+			if src.Code[i] == '\n' {
+				starts = append(starts, i+1)
+			}
+		}
+		idx = &lineIndex{src.Code, starts}
+		d.lines[src.Name] = idx
+	}
+	return sort.SearchInts(idx.starts, pos+1)
+}
+
+func stackDepth(fm *eval.Frame) int {
+	depth := 0
+	for st := fm.StackTrace(); st != nil; st = st.Next {
+		depth++
+	}
+	return depth
+}
+
+func (d *debugger) setBreakpoints(name string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(lines) == 0 {
+		delete(d.breakpoints, name)
+		return
+	}
+	set := make(map[int]bool, len(lines))
+	for _, line := range lines {
+		set[line] = true
+	}
+	d.breakpoints[name] = set
+}
+
+// Makes the next form stop with the given reason.
+func (d *debugger) pause(reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pauseReason = reason
+}
+
+// Returns the state of the paused script, or nil if it's not paused.
+func (d *debugger) pausedState() *pausedState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused
+}
+
+// Resumes the paused script. Returns false if the script is not paused.
+func (d *debugger) resume(mode stepMode) bool {
+	if d.pausedState() == nil {
+		return false
+	}
+	d.commands <- command{mode: mode}
+	return true
+}
+
+// Calls f with the Frame of the paused script on its goroutine, and waits for
+// it to finish. Returns false if the script is not paused.
+func (d *debugger) evalInPaused(f func(*eval.Frame)) bool {
+	if d.pausedState() == nil {
+		return false
+	}
+	done := make(chan struct{})
+	d.commands <- command{eval: func(fm *eval.Frame) {
+		defer close(done)
+		f(fm)
+	}}
+	<-done
+	return true
+}
+
+// Stops pausing the script, resuming it if it is paused.
+func (d *debugger) disable() {
+	d.mu.Lock()
+	d.disabled = true
+	d.mu.Unlock()
+	d.resume(runMode)
+}
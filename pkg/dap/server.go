@@ -0,0 +1,394 @@
+package dap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/mods"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/shell"
+)
+
+var (
+	errNotPaused    = errors.New("the script is not paused")
+	errNotLaunched  = errors.New("no program to launch")
+	errInvalidArgs  = errors.New("invalid arguments")
+	errUnknownScope = errors.New("unknown variables reference")
+)
+
+// The script is the only thread.
+const threadID = 1
+
+// References of the scopes of the innermost stack frame.
+const (
+	localsRef = 1 + iota
+	upvaluesRef
+)
+
+type server struct {
+	stream jsonrpc2.ObjectStream
+	dbg    *debugger
+
+	writeMu sync.Mutex
+	seq     int
+
+	// Set by the launch request; the script is run after the
+	// configurationDone request.
+	launch *launchArguments
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newServer(stream jsonrpc2.ObjectStream) *server {
+	s := &server{stream: stream}
+	s.dbg = newDebugger(func(reason string) {
+		s.sendEvent("stopped", stoppedEvent{reason, threadID, true})
+	})
+	return s
+}
+
+type handler func(json.RawMessage) (any, error)
+
+func (s *server) handlers() map[string]handler {
+	return map[string]handler{
+		"initialize":        s.initialize,
+		"launch":            convertHandler(s.launchProgram),
+		"setBreakpoints":    convertHandler(s.setBreakpoints),
+		"configurationDone": s.configurationDone,
+		"threads":           s.threads,
+		"stackTrace":        s.stackTrace,
+		"scopes":            convertHandler(s.scopes),
+		"variables":         convertHandler(s.variables),
+		"evaluate":          convertHandler(s.evaluate),
+		"pause":             s.pause,
+
+		// Exception breakpoints are not supported, but clients send this
+		// request unconditionally.
+		"setExceptionBreakpoints": noop,
+	}
+}
+
+// Requests that resume the paused script. They are handled in serve, since
+// the script must be resumed after the response is sent, so that the client
+// sees the response before the next stopped event.
+var resumeModes = map[string]stepMode{
+	"continue": runMode,
+	"next":     stepOverMode,
+	"stepIn":   stepInMode,
+	"stepOut":  stepOutMode,
+}
+
+func convertHandler[T any](f func(T) (any, error)) handler {
+	return func(rawArgs json.RawMessage) (any, error) {
+		var args T
+		if json.Unmarshal(rawArgs, &args) != nil {
+			return nil, errInvalidArgs
+		}
+		return f(args)
+	}
+}
+
+func noop(json.RawMessage) (any, error) { return nil, nil }
+
+// Reads and handles requests until the client disconnects.
+func (s *server) serve() {
+	handlers := s.handlers()
+	for {
+		var req request
+		if err := s.stream.ReadObject(&req); err != nil {
+			s.stop()
+			return
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.stop()
+			s.sendResponse(&req, nil, nil)
+			if req.Command == "disconnect" {
+				s.stream.Close()
+				return
+			}
+			continue
+		}
+		if mode, ok := resumeModes[req.Command]; ok {
+			if s.dbg.pausedState() == nil {
+				s.sendResponse(&req, nil, errNotPaused)
+			} else {
+				s.sendResponse(&req, nil, nil)
+				s.dbg.resume(mode)
+			}
+			continue
+		}
+		h, ok := handlers[req.Command]
+		if !ok {
+			s.sendResponse(&req, nil, fmt.Errorf("unsupported command %s", req.Command))
+			continue
+		}
+		body, err := h(req.Arguments)
+		s.sendResponse(&req, body, err)
+		if req.Command == "initialize" && err == nil {
+			s.sendEvent("initialized", nil)
+		}
+	}
+}
+
+// Stops the script if it is running, and waits for it to finish.
+func (s *server) stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.dbg.disable()
+	<-s.done
+	s.cancel = nil
+}
+
+func (s *server) sendResponse(req *request, body any, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq,
+		Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(func(seq int) any { resp.Seq = seq; return resp })
+}
+
+func (s *server) sendEvent(name string, body any) {
+	s.send(func(seq int) any { return &event{seq, "event", name, body} })
+}
+
+func (s *server) send(f func(seq int) any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	s.stream.WriteObject(f(s.seq))
+}
+
+// Handler implementations. These are all called synchronously.
+
+func (s *server) initialize(json.RawMessage) (any, error) {
+	return capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (s *server) launchProgram(args launchArguments) (any, error) {
+	if args.Program == "" {
+		return nil, errNotLaunched
+	}
+	s.launch = &args
+	return nil, nil
+}
+
+func (s *server) setBreakpoints(args setBreakpointsArguments) (any, error) {
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]int, len(args.Breakpoints))
+	bps := make([]breakpoint, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		lines[i] = bp.Line
+		bps[i] = breakpoint{Verified: true, Line: bp.Line}
+	}
+	s.dbg.setBreakpoints(path, lines)
+	return setBreakpointsResponse{bps}, nil
+}
+
+func (s *server) configurationDone(json.RawMessage) (any, error) {
+	if s.launch == nil {
+		return nil, errNotLaunched
+	}
+	name, err := filepath.Abs(s.launch.Program)
+	if err != nil {
+		return nil, err
+	}
+	code, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if s.launch.StopOnEntry {
+		s.dbg.pause("entry")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		exitCode := s.run(ctx, parse.Source{Name: name, Code: string(code), IsFile: true})
+		s.sendEvent("exited", exitedEvent{exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+	return nil, nil
+}
+
+// Runs the script, sending its output as output events. Returns the exit code.
+func (s *server) run(ctx context.Context, src parse.Source) int {
+	ev := eval.NewEvaler()
+	ev.Args = vals.MakeListSlice(s.launch.Args)
+	ev.BeforeForm = s.dbg.beforeForm
+	// Set up the Evaler like the shell, so that the script can use the same
+	// modules as when run with elvish.
+	libs, err := shell.LibPaths()
+	if err != nil {
+		s.sendEvent("output", outputEvent{"stderr", "Warning: resolving lib paths: " + err.Error() + "\n"})
+	} else {
+		ev.LibDirs = libs
+	}
+	mods.AddTo(ev)
+
+	stdout, doneStdout, err := s.outputPort("stdout")
+	if err != nil {
+		s.sendEvent("output", outputEvent{"stderr", err.Error() + "\n"})
+		return 2
+	}
+	stderr, doneStderr, err := s.outputPort("stderr")
+	if err != nil {
+		doneStdout()
+		s.sendEvent("output", outputEvent{"stderr", err.Error() + "\n"})
+		return 2
+	}
+	err = ev.Eval(src, eval.EvalCfg{
+		Ports: []*eval.Port{nil, stdout, stderr}, Interrupts: ctx})
+	doneStdout()
+	doneStderr()
+	if err != nil {
+		var sb strings.Builder
+		diag.ShowError(&sb, err)
+		s.sendEvent("output", outputEvent{"stderr", sb.String()})
+		return 2
+	}
+	return 0
+}
+
+// Returns a port whose output is sent as output events of the given category.
+func (s *server) outputPort(category string) (*eval.Port, func(), error) {
+	return eval.PipePort(
+		func(ch <-chan any) {
+			for v := range ch {
+				s.sendEvent("output", outputEvent{category, "▶ " + vals.ReprPlain(v) + "\n"})
+			}
+		},
+		func(r *os.File) {
+			buf := make([]byte, 4096)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					s.sendEvent("output", outputEvent{category, string(buf[:n])})
+				}
+				if err != nil {
+					if err != io.EOF {
+						s.sendEvent("output", outputEvent{"stderr", err.Error() + "\n"})
+					}
+					return
+				}
+			}
+		})
+}
+
+func (s *server) threads(json.RawMessage) (any, error) {
+	return threadsResponse{[]thread{{threadID, "main"}}}, nil
+}
+
+func (s *server) stackTrace(json.RawMessage) (any, error) {
+	paused := s.dbg.pausedState()
+	if paused == nil {
+		return nil, errNotPaused
+	}
+	// The innermost frame is the form about to be executed; the rest are the
+	// call sites in the stack trace.
+	src := paused.fm.Source()
+	frames := []stackFrame{makeStackFrame(0, diag.NewContext(src.Name, src.Code, paused.r))}
+	for st := paused.fm.StackTrace(); st != nil; st = st.Next {
+		frames = append(frames, makeStackFrame(len(frames), st.Head))
+	}
+	return stackTraceResponse{frames, len(frames)}, nil
+}
+
+func makeStackFrame(id int, ctx *diag.Context) stackFrame {
+	name, _, _ := strings.Cut(ctx.Body, "\n")
+	src := &source{Name: filepath.Base(ctx.Name)}
+	if filepath.IsAbs(ctx.Name) {
+		src.Path = ctx.Name
+	}
+	return stackFrame{ID: id, Name: name, Source: src,
+		Line: ctx.StartLine, Column: ctx.StartCol,
+		EndLine: ctx.EndLine, EndColumn: ctx.EndCol + 1}
+}
+
+func (s *server) scopes(args scopesArguments) (any, error) {
+	if s.dbg.pausedState() == nil {
+		return nil, errNotPaused
+	}
+	// Only the innermost frame has its variables available.
+	scopes := []scope{}
+	if args.FrameID == 0 {
+		scopes = append(scopes,
+			scope{"Locals", localsRef, false}, scope{"Upvalues", upvaluesRef, false})
+	}
+	return scopesResponse{scopes}, nil
+}
+
+func (s *server) variables(args variablesArguments) (any, error) {
+	paused := s.dbg.pausedState()
+	if paused == nil {
+		return nil, errNotPaused
+	}
+	var ns *eval.Ns
+	switch args.VariablesReference {
+	case localsRef:
+		ns = paused.fm.Local()
+	case upvaluesRef:
+		ns = paused.fm.Up()
+	default:
+		return nil, errUnknownScope
+	}
+	variables := []variable{}
+	ns.IterateKeysString(func(name string) {
+		v := ns.IndexString(name).Get()
+		variables = append(variables,
+			variable{"$" + parse.QuoteVariableName(name), vals.ReprPlain(v), 0})
+	})
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variablesResponse{variables}, nil
+}
+
+func (s *server) evaluate(args evaluateArguments) (any, error) {
+	var values []any
+	var err error
+	ok := s.dbg.evalInPaused(func(fm *eval.Frame) {
+		values, err = fm.CaptureOutput(func(fm *eval.Frame) error {
+			_, err := fm.Eval(parse.Source{Name: "[debug]", Code: args.Expression}, nil, fm.Local())
+			return err
+		})
+	})
+	if !ok {
+		return nil, errNotPaused
+	}
+	if err != nil {
+		return nil, errors.New(eval.Reason(err).Error())
+	}
+	reprs := make([]string, len(values))
+	for i, v := range values {
+		reprs[i] = vals.ReprPlain(v)
+	}
+	return evaluateResponse{strings.Join(reprs, " "), 0}, nil
+}
+
+func (s *server) pause(json.RawMessage) (any, error) {
+	s.dbg.pause("pause")
+	return nil, nil
+}
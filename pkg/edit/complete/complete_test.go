@@ -272,6 +272,16 @@ func TestComplete(t *testing.T) {
 				Name: "redir", Replace: r(4, 5),
 				Items: []modes.CompletionItem{fci("a.exe", " ")}},
 			nil),
+		// Filenames are not completed for here-strings and here-documents.
+		Args(cb("p <<<"), ev, cfg).Rets((*Result)(nil), errNoCompletion),
+		Args(cb("p <<< a"), ev, cfg).Rets((*Result)(nil), errNoCompletion),
+		Args(cb("p <<EOF\nfoo "), ev, cfg).Rets((*Result)(nil), errNoCompletion),
+		// Variables in here-documents are completed.
+		Args(cb("p <<EOF\n$local-v"), ev, cfg).Rets(
+			&Result{
+				Name: "variable", Replace: r(9, 16),
+				Items: []modes.CompletionItem{ci("local-var1"), ci("local-var2")}},
+			nil),
 
 		// Completing variables.
 
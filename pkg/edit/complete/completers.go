@@ -65,8 +65,10 @@ func completeCommand(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawIte
 	var primary *parse.Primary
 	if p.Match(np.Sep, np.Store(&primary)) {
 		t := primary.Type
-		if t == parse.OutputCapture || t == parse.ExceptionCapture || t == parse.Lambda {
-			// Case 3: At the beginning of output, exception capture or lambda.
+		if t == parse.OutputCapture || t == parse.ExceptionCapture || t == parse.Lambda ||
+			t == parse.ReadSubstitution || t == parse.WriteSubstitution {
+			// Case 3: At the beginning of output capture, exception capture,
+			// lambda or process substitution.
 			//
 			// TODO: Don't trigger after "{|".
 			return generateForEmpty(p[0].Range().To)
@@ -120,7 +122,8 @@ func completeIndex(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawItem,
 }
 
 func completeRedir(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
-	if p.Match(np.Sep, np.Redir) {
+	var redir *parse.Redir
+	if p.Match(np.Sep, np.Store(&redir)) && isFileRedir(redir) {
 		// Empty redirection target.
 		ctx := &context{"redir", "", parse.Bareword, range0(p[0].Range().To)}
 		items, err := generateFileNames("", nil)
@@ -128,7 +131,7 @@ func completeRedir(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawItem,
 	}
 
 	var expr np.SimpleExprData
-	if p.Match(np.SimpleExpr(&expr, ev), np.Redir) {
+	if p.Match(np.SimpleExpr(&expr, ev), np.Store(&redir)) && isFileRedir(redir) {
 		// Non-empty redirection target.
 		ctx := &context{"redir", expr.Value, expr.PrimarType, expr.Compound.Range()}
 		items, err := generateFileNames(expr.Value, nil)
@@ -138,6 +141,12 @@ func completeRedir(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawItem,
 	return nil, nil, errNoCompletion
 }
 
+// Reports whether the target of a redirection can be a filename, which is not
+// the case for here-documents and here-strings.
+func isFileRedir(n *parse.Redir) bool {
+	return n.Mode != parse.ReadHereDoc && n.Mode != parse.ReadHereString
+}
+
 func completeVariable(p np.Path, ev *eval.Evaler, cfg Config) (*context, []RawItem, error) {
 	primary, ok := p[0].(*parse.Primary)
 	if !ok || primary.Type != parse.Variable {
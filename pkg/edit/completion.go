@@ -256,28 +256,24 @@ type matcherOpts struct {
 
 func (*matcherOpts) SetDefaultOptions() {}
 
-type wrappedMatcher func(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.Inputs) error
+type wrappedMatcher func(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.StoppableInputs) error
 
 func wrapMatcher(m matcher) wrappedMatcher {
-	return func(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.Inputs) error {
+	return func(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.StoppableInputs) error {
 		out := fm.ValueOutput()
 		var errOut error
 		if opts.IgnoreCase || (opts.SmartCase && seed == strings.ToLower(seed)) {
 			if opts.IgnoreCase {
 				seed = strings.ToLower(seed)
 			}
-			inputs(func(v any) {
-				if errOut != nil {
-					return
-				}
+			inputs(func(v any) bool {
 				errOut = out.Put(m(strings.ToLower(vals.ToString(v)), seed))
+				return errOut == nil
 			})
 		} else {
-			inputs(func(v any) {
-				if errOut != nil {
-					return
-				}
+			inputs(func(v any) bool {
 				errOut = out.Put(m(vals.ToString(v), seed))
+				return errOut == nil
 			})
 		}
 		return errOut
@@ -45,6 +45,9 @@ const (
 	variableRegion     = "variable" // Could also be semantic.
 	wildcardRegion     = "wildcard"
 	tildeRegion        = "tilde"
+	// A region of text in a here-document, including the delimiters. Note
+	// that it corresponds to a parse.Sep node.
+	hereDocRegion = "here-doc"
 	// A comment region. Note that this is the only type of Sep leaf node that
 	// is not identified by its text.
 	commentRegion = "comment"
@@ -102,6 +105,14 @@ func emitRegions(n parse.Node, f func(parse.Node, regionKind, string)) {
 		emitRegionsInPrimary(n, f)
 	case *parse.Sep:
 		emitRegionsInSep(n, f)
+	case *parse.Redir:
+		if n.Mode == parse.ReadHereDoc && n.HereDoc.Delimiter != "" {
+			emitRegionsInHereDocRedir(n, f)
+			return
+		}
+	case *parse.HereDoc:
+		emitRegionsInHereDoc(n, f)
+		return
 	}
 	for _, child := range parse.Children(n) {
 		emitRegions(child, f)
@@ -228,6 +239,26 @@ func emitRegionsInPrimary(n *parse.Primary, f func(parse.Node, regionKind, strin
 	}
 }
 
+func emitRegionsInHereDocRedir(n *parse.Redir, f func(parse.Node, regionKind, string)) {
+	children := parse.Children(n)
+	for _, child := range children[:len(children)-1] {
+		emitRegions(child, f)
+	}
+	// The delimiter is the last child.
+	f(children[len(children)-1], lexicalRegion, hereDocRegion)
+}
+
+func emitRegionsInHereDoc(n *parse.HereDoc, f func(parse.Node, regionKind, string)) {
+	for _, child := range parse.Children(n) {
+		switch child := child.(type) {
+		case *parse.Sep:
+			f(child, lexicalRegion, hereDocRegion)
+		case *parse.Primary:
+			emitRegionsInPrimary(child, f)
+		}
+	}
+}
+
 func emitRegionsInSep(n *parse.Sep, f func(parse.Node, regionKind, string)) {
 	text := sourceText(n)
 	trimmed := strings.TrimLeftFunc(text, parse.IsWhitespace)
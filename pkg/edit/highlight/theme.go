@@ -11,22 +11,28 @@ var stylingFor = map[string]ui.Styling{
 	variableRegion:     ui.FgMagenta,
 	wildcardRegion:     nil,
 	tildeRegion:        nil,
+	hereDocRegion:      ui.FgYellow,
 
 	commentRegion: ui.FgCyan,
 
-	">":  ui.FgGreen,
-	">>": ui.FgGreen,
-	"<":  ui.FgGreen,
-	"?>": ui.FgGreen,
-	"|":  ui.FgGreen,
-	"?(": ui.Bold,
-	"(":  ui.Bold,
-	")":  ui.Bold,
-	"[":  ui.Bold,
-	"]":  ui.Bold,
-	"{":  ui.Bold,
-	"}":  ui.Bold,
-	"&":  ui.Bold,
+	">":   ui.FgGreen,
+	">>":  ui.FgGreen,
+	"<":   ui.FgGreen,
+	"<<":  ui.FgGreen,
+	"<<<": ui.FgGreen,
+	"?>":  ui.FgGreen,
+	"|":   ui.FgGreen,
+	"|&":  ui.FgGreen,
+	"?(":  ui.Bold,
+	"<(":  ui.Bold,
+	">(":  ui.Bold,
+	"(":   ui.Bold,
+	")":   ui.Bold,
+	"[":   ui.Bold,
+	"]":   ui.Bold,
+	"{":   ui.Bold,
+	"}":   ui.Bold,
+	"&":   ui.Bold,
 
 	commandRegion: ui.FgGreen,
 	keywordRegion: ui.FgYellow,
@@ -9,8 +9,6 @@ import (
 
 // Command and process control.
 
-// TODO(xiaq): Document "fg".
-
 func init() {
 	addBuiltinFns(map[string]any{
 		// Command resolution
@@ -19,7 +17,6 @@ func init() {
 		"search-external": searchExternal,
 
 		// Process control
-		"fg":   fg,
 		"exec": execFn,
 		"exit": exit,
 	})
@@ -17,6 +17,8 @@ func init() {
 
 		"make-map": makeMap,
 
+		"record-kind": recordKind,
+
 		"conj":   conj,
 		"assoc":  assoc,
 		"dissoc": dissoc,
@@ -43,38 +45,46 @@ func nsFn(m vals.Map) (*Ns, error) {
 	return nb.Ns(), nil
 }
 
-func makeMap(input Inputs) (vals.Map, error) {
+func makeMap(input StoppableInputs) (vals.Map, error) {
 	m := vals.EmptyMap
 	var errMakeMap error
-	input(func(v any) {
-		if errMakeMap != nil {
-			return
-		}
+	input(func(v any) bool {
 		if !vals.CanIterate(v) {
 			errMakeMap = errs.BadValue{
 				What: "input to make-map", Valid: "iterable", Actual: vals.Kind(v)}
-			return
+			return false
 		}
 		if l := vals.Len(v); l != 2 {
 			errMakeMap = errs.BadValue{
 				What: "input to make-map", Valid: "iterable with 2 elements",
 				Actual: fmt.Sprintf("%v with %v elements", vals.Kind(v), l)}
-			return
+			return false
 		}
 		elems, err := vals.Collect(v)
 		if err != nil {
 			errMakeMap = err
-			return
+			return false
 		}
 		if len(elems) != 2 {
 			errMakeMap = fmt.Errorf("internal bug: collected %v values", len(elems))
-			return
+			return false
 		}
 		m = m.Assoc(elems[0], elems[1])
+		return true
 	})
 	return m, errMakeMap
 }
 
+func recordKind(opts RawOptions, name string, fields ...string) (Callable, error) {
+	kind, err := vals.NewRecordKind(name, fields, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewGoFn(name, func(opts RawOptions) (*vals.Record, error) {
+		return kind.New(opts)
+	}), nil
+}
+
 func conj(li vals.List, more ...any) vals.List {
 	for _, val := range more {
 		li = li.Conj(val)
@@ -98,6 +108,8 @@ func dissoc(a, k any) (any, error) {
 
 func hasValue(container, value any) (bool, error) {
 	switch container := container.(type) {
+	case vals.Set:
+		return container.Has(value), nil
 	case vals.Map:
 		for it := container.Iterator(); it.HasElem(); it.Next() {
 			_, v := it.Elem()
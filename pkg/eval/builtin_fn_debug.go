@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"errors"
 	"runtime"
 
 	"src.elv.sh/pkg/logutil"
@@ -9,10 +10,11 @@ import (
 
 func init() {
 	addBuiltinFns(map[string]any{
-		"src":    src,
-		"-gc":    _gc,
-		"-stack": _stack,
-		"-log":   _log,
+		"src":        src,
+		"breakpoint": breakpoint,
+		"-gc":        _gc,
+		"-stack":     _stack,
+		"-log":       _log,
 	})
 }
 
@@ -20,6 +22,21 @@ func src(fm *Frame) parse.Source {
 	return fm.src
 }
 
+// ErrBreakpointNotInForeground is thrown by breakpoint when it is called
+// outside the foreground job and [Evaler.OnBreakpoint] is set.
+var ErrBreakpointNotInForeground = errors.New(
+	"breakpoint can only be used in the foreground, not in background pipelines, peach or spawn")
+
+func breakpoint(fm *Frame) error {
+	if fm.Evaler.OnBreakpoint == nil {
+		return nil
+	}
+	if fm.detached {
+		return ErrBreakpointNotInForeground
+	}
+	return fm.Evaler.OnBreakpoint(fm)
+}
+
 func _gc() {
 	runtime.GC()
 }
@@ -29,8 +29,16 @@ func init() {
 		"continue":    continueFn,
 		"defer":       deferFn,
 		// Iterations.
-		"each":  each,
-		"peach": peach,
+		"each":      each,
+		"peach":     peach,
+		"generator": generator,
+		// Futures.
+		"spawn":  spawn,
+		"await":  await,
+		"cancel": cancelFn,
+		// Timeouts.
+		"with-timeout":  withTimeout,
+		"with-deadline": withDeadline,
 	})
 }
 
@@ -52,13 +60,10 @@ func runParallel(fm *Frame, functions ...Callable) error {
 	return MakePipelineError(exceptions)
 }
 
-func each(fm *Frame, f Callable, inputs Inputs) error {
+func each(fm *Frame, f Callable, inputs StoppableInputs) error {
 	broken := false
 	var err error
-	inputs(func(v any) {
-		if broken {
-			return
-		}
+	inputs(func(v any) bool {
 		newFm := fm.Fork()
 		ex := f.Call(newFm, []any{v}, NoOpts)
 
@@ -73,6 +78,7 @@ func each(fm *Frame, f Callable, inputs Inputs) error {
 				err = ex
 			}
 		}
+		return !broken
 	})
 	return err
 }
@@ -81,7 +87,7 @@ type peachOpt struct{ NumWorkers vals.Num }
 
 func (o *peachOpt) SetDefaultOptions() { o.NumWorkers = math.Inf(1) }
 
-func peach(fm *Frame, opts peachOpt, f Callable, inputs Inputs) error {
+func peach(fm *Frame, opts peachOpt, f Callable, inputs StoppableInputs) error {
 	var wg sync.WaitGroup
 	var broken int32
 	var errMu sync.Mutex
@@ -98,17 +104,22 @@ func peach(fm *Frame, opts peachOpt, f Callable, inputs Inputs) error {
 
 	ctx := fm.Context()
 
-	inputs(func(v any) {
+	inputs(func(v any) bool {
 		if atomic.LoadInt32(&broken) != 0 {
-			return
+			return false
 		}
 		if workerSema != nil {
-			workerSema.Acquire(ctx, 1)
+			if workerSema.Acquire(ctx, 1) != nil {
+				// The Context has been canceled; stop starting new workers.
+				atomic.StoreInt32(&broken, 1)
+				return false
+			}
 		}
 		wg.Add(1)
 		go func() {
 			newFm := fm.Fork()
 			newFm.ports[0] = DummyInputPort
+			newFm.detached = true
 			ex := f.Call(newFm, []any{v}, NoOpts)
 
 			if ex != nil {
@@ -129,6 +140,7 @@ func peach(fm *Frame, opts peachOpt, f Callable, inputs Inputs) error {
 				workerSema.Release(1)
 			}
 		}()
+		return true
 	})
 	wg.Wait()
 	return err
@@ -2,18 +2,28 @@ package eval
 
 import (
 	"bufio"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/strutil"
+	"src.elv.sh/pkg/sys"
+	"src.elv.sh/pkg/toml"
+	"src.elv.sh/pkg/ui"
+	"src.elv.sh/pkg/wcwidth"
+	"src.elv.sh/pkg/yaml"
 )
 
 // Input and output.
@@ -51,11 +61,23 @@ func init() {
 		"from-lines":      fromLines,
 		"from-json":       fromJSON,
 		"from-terminated": fromTerminated,
+		"from-csv":        fromCSV,
+		"from-tsv":        fromTSV,
+		"from-yaml":       fromYAML,
+		"from-toml":       fromTOML,
 
 		// Value to bytes
 		"to-lines":      toLines,
 		"to-json":       toJSON,
 		"to-terminated": toTerminated,
+		"to-csv":        toCSV,
+		"to-tsv":        toTSV,
+		"to-yaml":       toYAML,
+		"to-toml":       toTOML,
+		"to-table":      toTable,
+
+		// Capturing output
+		"capture": capture,
 	})
 }
 
@@ -81,7 +103,19 @@ func repeat(fm *Frame, n int, v any) error {
 	return nil
 }
 
-func readBytes(fm *Frame, max int) (string, error) {
+type bytesOpt struct{ Bytes bool }
+
+func (*bytesOpt) SetDefaultOptions() {}
+
+// Returns buf as a bytes value if &bytes is set, or a string otherwise.
+func (opt bytesOpt) wrap(buf []byte) any {
+	if opt.Bytes {
+		return vals.Bytes(buf)
+	}
+	return string(buf)
+}
+
+func readBytes(fm *Frame, opts bytesOpt, max int) (any, error) {
 	in := fm.InputFile()
 	buf := make([]byte, max)
 	read := 0
@@ -91,10 +125,10 @@ func readBytes(fm *Frame, max int) (string, error) {
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
-	return string(buf[:read]), nil
+	return opts.wrap(buf[:read]), nil
 }
 
 func readUpto(fm *Frame, terminator string) (string, error) {
@@ -324,9 +358,12 @@ type blackholeWriter struct{}
 
 func (blackholeWriter) Write(p []byte) (int, error) { return len(p), nil }
 
-func slurp(fm *Frame) (string, error) {
+func slurp(fm *Frame, opts bytesOpt) (any, error) {
 	b, err := io.ReadAll(fm.InputFile())
-	return string(b), err
+	if err != nil {
+		return nil, err
+	}
+	return opts.wrap(b), nil
 }
 
 func fromLines(fm *Frame) error {
@@ -444,45 +481,783 @@ func fromTerminated(fm *Frame, terminator string) error {
 	}
 }
 
-func toLines(fm *Frame, inputs Inputs) error {
+func toLines(fm *Frame, inputs StoppableInputs) error {
 	out := fm.ByteOutput()
 	var errOut error
 
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		// TODO: Don't ignore the error.
 		_, errOut = fmt.Fprintln(out, vals.ToString(v))
+		return errOut == nil
 	})
 	return errOut
 }
 
-func toTerminated(fm *Frame, terminator string, inputs Inputs) error {
+func toTerminated(fm *Frame, terminator string, inputs StoppableInputs) error {
 	if err := checkTerminator(terminator); err != nil {
 		return err
 	}
 
 	out := fm.ByteOutput()
 	var errOut error
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		_, errOut = fmt.Fprint(out, vals.ToString(v), terminator)
+		return errOut == nil
 	})
 	return errOut
 }
 
-func toJSON(fm *Frame, inputs Inputs) error {
+func toJSON(fm *Frame, inputs StoppableInputs) error {
 	encoder := json.NewEncoder(fm.ByteOutput())
 
 	var errEncode error
-	inputs(func(v any) {
-		if errEncode != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		errEncode = encoder.Encode(v)
+		return errEncode == nil
+	})
+	return errEncode
+}
+
+type fromCSVOpts struct {
+	Delimiter  string
+	Header     bool
+	LazyQuotes bool
+}
+
+func (o *fromCSVOpts) SetDefaultOptions() { o.Delimiter = "," }
+
+func fromCSV(fm *Frame, opts fromCSVOpts) error {
+	comma, err := checkDelimiter(opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(fm.InputFile())
+	r.Comma = comma
+	r.LazyQuotes = opts.LazyQuotes
+	// Records are converted to Elvish values before reading the next one, so
+	// the slice can be reused.
+	r.ReuseRecord = true
+
+	out := fm.ValueOutput()
+	var header []string
+	for {
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if opts.Header && header == nil {
+			header = slices.Clone(record)
+			continue
+		}
+		if err := out.Put(recordValue(record, header)); err != nil {
+			return err
+		}
+	}
+}
+
+// Converts a record read by from-csv or from-tsv to a map keyed by header if
+// header is not nil, or a list otherwise.
+func recordValue(record, header []string) any {
+	if header != nil {
+		m := vals.EmptyMap
+		for i, field := range record {
+			m = m.Assoc(header[i], field)
+		}
+		return m
+	}
+	l := vals.EmptyList
+	for _, field := range record {
+		l = l.Conj(field)
+	}
+	return l
+}
+
+type fromTSVOpts struct {
+	Header bool
+}
+
+func (*fromTSVOpts) SetDefaultOptions() {}
+
+func fromTSV(fm *Frame, opts fromTSVOpts) error {
+	r := bufio.NewReader(fm.InputFile())
+	out := fm.ValueOutput()
+	var header []string
+	nFields := -1
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		// Empty lines are skipped, like in from-csv.
+		if line != "" {
+			record := strings.Split(line, "\t")
+			if nFields == -1 {
+				nFields = len(record)
+			} else if len(record) != nFields {
+				return fmt.Errorf("record on line %d: wrong number of fields", lineNo)
+			}
+			if opts.Header && header == nil {
+				header = record
+			} else if err := out.Put(recordValue(record, header)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+type toCSVOpts struct {
+	Delimiter string
+	Columns   vals.List
+	Header    bool
+}
+
+func (o *toCSVOpts) SetDefaultOptions() {
+	o.Delimiter = ","
+	o.Header = true
+}
+
+func toCSV(fm *Frame, opts toCSVOpts, inputs StoppableInputs) error {
+	comma, err := checkDelimiter(opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(fm.ByteOutput())
+	w.Comma = comma
+	return writeRecords("to-csv", opts.Columns, opts.Header, inputs, func(record []string) error {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		// Flush after each record, so that the output can be consumed as
+		// soon as possible.
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// Converts each input of to-csv or to-tsv to a record, and writes it with
+// write. The header, if any, is also written with write.
+func writeRecords(name string, columnsOpt vals.List, header bool, inputs StoppableInputs, write func([]string) error) error {
+	var columns []string
+	if columnsOpt != nil {
+		for it := columnsOpt.Iterator(); it.HasElem(); it.Next() {
+			columns = append(columns, vals.ToString(it.Elem()))
+		}
+	}
+	wroteHeader := false
+	var errOut error
+	inputs(func(v any) bool {
+		var record []string
+		switch v := v.(type) {
+		case vals.List:
+			for it := v.Iterator(); it.HasElem(); it.Next() {
+				record = append(record, vals.ToString(it.Elem()))
+			}
+		case vals.Map:
+			if columns == nil {
+				for it := v.Iterator(); it.HasElem(); it.Next() {
+					k, _ := it.Elem()
+					columns = append(columns, vals.ToString(k))
+				}
+				slices.Sort(columns)
+			}
+			if header && !wroteHeader {
+				if errOut = write(columns); errOut != nil {
+					return false
+				}
+				wroteHeader = true
+			}
+			for _, column := range columns {
+				// Missing fields and $nil are written as empty strings.
+				field, _ := v.Index(column)
+				if field == nil {
+					record = append(record, "")
+				} else {
+					record = append(record, vals.ToString(field))
+				}
+			}
+		default:
+			errOut = errs.BadValue{What: "input to " + name,
+				Valid: "list or map", Actual: vals.Kind(v)}
+			return false
+		}
+		errOut = write(record)
+		return errOut == nil
+	})
+	return errOut
+}
+
+type toTSVOpts struct {
+	Columns vals.List
+	Header  bool
+}
+
+func (o *toTSVOpts) SetDefaultOptions() { o.Header = true }
+
+func toTSV(fm *Frame, opts toTSVOpts, inputs StoppableInputs) error {
+	out := fm.ByteOutput()
+	return writeRecords("to-tsv", opts.Columns, opts.Header, inputs, func(record []string) error {
+		for _, field := range record {
+			if strings.ContainsAny(field, "\t\r\n") {
+				return errs.BadValue{What: "field in to-tsv",
+					Valid: "string without tab, CR or LF", Actual: parse.Quote(field)}
+			}
+		}
+		_, err := io.WriteString(out, strings.Join(record, "\t")+"\n")
+		return err
+	})
+}
+
+func checkDelimiter(s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, errs.BadValue{What: "delimiter",
+			Valid: "a single character other than quote, CR and LF", Actual: parse.Quote(s)}
+	}
+	return r, nil
+}
+
+func fromYAML(fm *Frame) error {
+	dec := yaml.NewDecoder(fm.InputFile())
+	out := fm.ValueOutput()
+	for {
+		node, err := dec.Decode()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		v, err := (&yamlConverter{}).convert(node)
+		if err != nil {
+			return err
+		}
+		err = out.Put(v)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Maximum number of nodes that can be converted from the expansion of aliases
+// in a single YAML document. This guards against documents like the "billion
+// laughs", which are small but expand exponentially.
+const maxYAMLAliasedNodes = 1000000
+
+var errYAMLTooManyAliasedNodes = errors.New("too many nodes from expanding aliases")
+
+// Converts YAML nodes in a document to Elvish values, using the same rules as
+// fromJSONInterface where applicable.
+type yamlConverter struct {
+	// Targets of aliases currently being expanded, used to detect recursive
+	// aliases.
+	expanding map[*yaml.Node]bool
+	// Number of nodes converted while expanding aliases.
+	aliasedNodes int
+}
+
+func (c *yamlConverter) convert(node *yaml.Node) (any, error) {
+	if len(c.expanding) > 0 {
+		c.aliasedNodes++
+		if c.aliasedNodes > maxYAMLAliasedNodes {
+			return nil, errYAMLTooManyAliasedNodes
+		}
+	}
+	switch node.Kind {
+	case yaml.AliasNode:
+		if c.expanding[node.Alias] {
+			return nil, fmt.Errorf("line %d: recursive alias *%s", node.Line, node.Value)
+		}
+		if c.expanding == nil {
+			c.expanding = make(map[*yaml.Node]bool)
+		}
+		c.expanding[node.Alias] = true
+		defer delete(c.expanding, node.Alias)
+		return c.convert(node.Alias)
+	case yaml.SequenceNode:
+		vec := vals.EmptyList
+		for _, elem := range node.Content {
+			converted, err := c.convert(elem)
+			if err != nil {
+				return nil, err
+			}
+			vec = vec.Conj(converted)
+		}
+		return vec, nil
+	case yaml.MappingNode:
+		return c.convertMapping(node)
+	}
+	v, err := node.ScalarValue()
+	if z, ok := v.(*big.Int); ok {
+		return vals.NormalizeBigInt(z), nil
+	}
+	return v, err
+}
+
+func (c *yamlConverter) convertMapping(node *yaml.Node) (any, error) {
+	m := vals.EmptyMap
+	// Entries from merge keys ("<<") don't override explicit entries, so they
+	// are assoc'ed first.
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag != "!!merge" {
+			continue
+		}
+		merged := node.Content[i+1]
+		v, err := c.convert(merged)
+		if err != nil {
+			return nil, err
+		}
+		toMerge := []any{v}
+		if l, ok := v.(vals.List); ok {
+			toMerge = nil
+			for it := l.Iterator(); it.HasElem(); it.Next() {
+				toMerge = append(toMerge, it.Elem())
+			}
+		}
+		for _, v := range toMerge {
+			mergedMap, ok := v.(vals.Map)
+			if !ok {
+				return nil, fmt.Errorf("line %d: map merge requires map or list of maps", merged.Line)
+			}
+			for it := mergedMap.Iterator(); it.HasElem(); it.Next() {
+				k, v := it.Elem()
+				if _, exists := m.Index(k); !exists {
+					m = m.Assoc(k, v)
+				}
+			}
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag == "!!merge" {
+			continue
+		}
+		k, err := c.convert(node.Content[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := c.convert(node.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		m = m.Assoc(k, v)
+	}
+	return m, nil
+}
+
+func toYAML(fm *Frame, inputs StoppableInputs) error {
+	out := fm.ByteOutput()
+	first := true
+	var errEncode error
+	inputs(func(v any) bool {
+		if !first {
+			// Separate documents.
+			if _, errEncode = out.WriteString("---\n"); errEncode != nil {
+				return false
+			}
+		}
+		first = false
+		var goValue any
+		goValue, errEncode = toGoForEncoding(v, func(z *big.Int) (any, error) {
+			return z, nil
+		})
+		if errEncode == nil {
+			errEncode = yaml.Encode(out, goValue)
+		}
+		return errEncode == nil
 	})
 	return errEncode
 }
+
+func fromTOML(fm *Frame) error {
+	src, err := io.ReadAll(fm.InputFile())
+	if err != nil {
+		return err
+	}
+	m, err := toml.Parse(string(src))
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(fromTOMLInterface(m))
+}
+
+// Converts a value decoded by the toml package to an Elvish value, using the
+// same rules as fromJSONInterface where applicable.
+func fromTOMLInterface(v any) any {
+	switch v := v.(type) {
+	case int64:
+		return vals.NormalizeBigInt(big.NewInt(v))
+	case toml.Datetime:
+		// Date and time values are converted to strings in the same format
+		// as in TOML.
+		return string(v)
+	case []any:
+		vec := vals.EmptyList
+		for _, elem := range v {
+			vec = vec.Conj(fromTOMLInterface(elem))
+		}
+		return vec
+	case map[string]any:
+		m := vals.EmptyMap
+		for key, val := range v {
+			m = m.Assoc(key, fromTOMLInterface(val))
+		}
+		return m
+	default:
+		// bool, float64 and string
+		return v
+	}
+}
+
+func toTOML(fm *Frame, inputs StoppableInputs) error {
+	out := fm.ByteOutput()
+	var errEncode error
+	inputs(func(v any) bool {
+		if vals.Kind(v) != "map" {
+			errEncode = errs.BadValue{What: "input to to-toml",
+				Valid: "map", Actual: vals.Kind(v)}
+			return false
+		}
+		var goValue any
+		goValue, errEncode = toGoForEncoding(v, func(z *big.Int) (any, error) {
+			return nil, errs.OutOfRange{What: "integer in TOML",
+				ValidLow: "-2^63", ValidHigh: "2^63-1", Actual: z.String()}
+		})
+		if errEncode == nil {
+			errEncode = toml.Encode(out, goValue.(map[string]any))
+		}
+		return errEncode == nil
+	})
+	return errEncode
+}
+
+// Converts an Elvish value to a Go value suitable for encoders of data formats.
+// Lists and sets are converted to []any, and maps to map[string]any with keys
+// converted to strings. Rational numbers are converted to strings like "1/2",
+// consistent with to-json. Big ints are converted with the bigInt callback.
+// Other values that are not nil, booleans, strings or numbers are converted to
+// strings.
+func toGoForEncoding(v any, bigInt func(*big.Int) (any, error)) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string, int, float64:
+		return v, nil
+	case *big.Int:
+		return bigInt(v)
+	case *big.Rat:
+		return v.RatString(), nil
+	case vals.List:
+		s := make([]any, 0, v.Len())
+		for it := v.Iterator(); it.HasElem(); it.Next() {
+			converted, err := toGoForEncoding(it.Elem(), bigInt)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, converted)
+		}
+		return s, nil
+	case vals.Set:
+		var elems []any
+		v.Iterate(func(elem any) bool {
+			elems = append(elems, elem)
+			return true
+		})
+		slices.SortFunc(elems, func(a, b any) int {
+			return int(vals.CmpTotal(a, b)) - int(vals.CmpEqual)
+		})
+		return toGoForEncoding(vals.MakeList(elems...), bigInt)
+	}
+	var keys []any
+	if vals.IterateKeys(v, func(k any) bool {
+		keys = append(keys, k)
+		return true
+	}) != nil {
+		return vals.ToString(v), nil
+	}
+	m := make(map[string]any, len(keys))
+	for _, k := range keys {
+		elem, err := vals.Index(v, k)
+		if err != nil {
+			return nil, err
+		}
+		converted, err := toGoForEncoding(elem, bigInt)
+		if err != nil {
+			return nil, err
+		}
+		m[vals.ToString(k)] = converted
+	}
+	return m, nil
+}
+
+type toTableOpts struct {
+	Columns vals.List
+	Header  bool
+	Width   int
+}
+
+func (o *toTableOpts) SetDefaultOptions() { o.Header = true }
+
+// Returns the width of the terminal f is connected to, or 0 if f is not a
+// terminal. Can be overridden in tests.
+var terminalWidth = func(f *os.File) int {
+	if f == nil || !sys.IsATTY(f.Fd()) {
+		return 0
+	}
+	_, width := sys.WinSize(f)
+	return width
+}
+
+const (
+	tableColumnSep      = "  "
+	tableMinColumnWidth = 3
+)
+
+// Control characters that would break the alignment of tables.
+var tableCellReplacer = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+
+func toTable(fm *Frame, opts toTableOpts, inputs StoppableInputs) error {
+	if opts.Width < 0 {
+		return errs.BadValue{What: "width",
+			Valid: "non-negative integer", Actual: strconv.Itoa(opts.Width)}
+	}
+	// Rows are either all lists or all map-like values, like maps and records;
+	// the kind of the first row decides which.
+	var rows []any
+	listRows := false
+	var errOut error
+	inputs(func(v any) bool {
+		_, isList := v.(vals.List)
+		isMap := !isList && vals.IterateKeys(v, func(any) bool { return false }) == nil
+		switch {
+		case !isList && !isMap:
+			errOut = errs.BadValue{What: "input to to-table",
+				Valid: "list or map", Actual: vals.Kind(v)}
+		case isList && opts.Columns != nil:
+			// Columns are named, so they can't select the elements of lists.
+			errOut = errs.BadValue{What: "input to to-table with &columns",
+				Valid: "map", Actual: vals.Kind(v)}
+		case len(rows) == 0:
+			listRows = isList
+		case isList != listRows:
+			valid := "map like the first input"
+			if listRows {
+				valid = "list like the first input"
+			}
+			errOut = errs.BadValue{What: "input to to-table",
+				Valid: valid, Actual: vals.Kind(v)}
+		}
+		if errOut != nil {
+			return false
+		}
+		rows = append(rows, v)
+		return true
+	})
+	if errOut != nil {
+		return errOut
+	}
+
+	var columns []string
+	if opts.Columns != nil {
+		for it := opts.Columns.Iterator(); it.HasElem(); it.Next() {
+			columns = append(columns, vals.ToString(it.Elem()))
+		}
+	} else if !listRows {
+		for _, row := range rows {
+			vals.IterateKeys(row, func(k any) bool {
+				columns = append(columns, vals.ToString(k))
+				return true
+			})
+		}
+		slices.Sort(columns)
+		columns = slices.Compact(columns)
+	}
+
+	// Convert all the rows to cells, and work out which columns only contain
+	// numbers, which are right-aligned.
+	cells := make([][]ui.Text, len(rows))
+	var numeric []bool
+	for i, row := range rows {
+		var values []any
+		if listRows {
+			for it := row.(vals.List).Iterator(); it.HasElem(); it.Next() {
+				values = append(values, it.Elem())
+			}
+		} else {
+			for _, column := range columns {
+				// Missing fields are written as empty cells.
+				value, _ := vals.Index(row, column)
+				values = append(values, value)
+			}
+		}
+		for j, value := range values {
+			if j >= len(numeric) {
+				numeric = append(numeric, true)
+			}
+			switch value.(type) {
+			case nil:
+			case int, *big.Int, float64, *big.Rat:
+			default:
+				numeric[j] = false
+			}
+			cells[i] = append(cells[i], tableCell(value))
+		}
+	}
+	termWidth := terminalWidth(fm.Port(1).File)
+	showHeader := opts.Header && !listRows
+	var header []ui.Text
+	if showHeader {
+		for _, column := range columns {
+			if termWidth > 0 {
+				header = append(header, ui.T(column, ui.Bold))
+			} else {
+				header = append(header, ui.T(column))
+			}
+		}
+	}
+	nColumns := max(len(numeric), len(header))
+	if nColumns == 0 {
+		return nil
+	}
+	numeric = append(numeric, make([]bool, nColumns-len(numeric))...)
+
+	widths := make([]int, nColumns)
+	for _, row := range append(cells, header) {
+		for j, cell := range row {
+			widths[j] = max(widths[j], textWidth(cell))
+		}
+	}
+	maxWidth := opts.Width
+	if maxWidth == 0 {
+		maxWidth = termWidth
+	}
+	if maxWidth > 0 {
+		// Shrink the widest column until the table fits.
+		total := len(tableColumnSep) * (nColumns - 1)
+		for _, w := range widths {
+			total += w
+		}
+		for total > maxWidth {
+			widest := 0
+			for j, w := range widths {
+				if w > widths[widest] {
+					widest = j
+				}
+			}
+			if widths[widest] <= tableMinColumnWidth {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	out := fm.ByteOutput()
+	writeRow := func(row []ui.Text) error {
+		var sb strings.Builder
+		for j := range nColumns {
+			if j > 0 {
+				sb.WriteString(tableColumnSep)
+			}
+			var cell ui.Text
+			if j < len(row) {
+				cell = row[j]
+			}
+			if textWidth(cell) > widths[j] {
+				cell = ui.Concat(cell.TrimWcwidth(widths[j]-1), ui.T("…"))
+			}
+			padding := strings.Repeat(" ", widths[j]-textWidth(cell))
+			if numeric[j] {
+				sb.WriteString(padding)
+			}
+			sb.WriteString(cellString(cell))
+			if !numeric[j] {
+				sb.WriteString(padding)
+			}
+		}
+		_, err := out.WriteString(strings.TrimRight(sb.String(), " ") + "\n")
+		return err
+	}
+	if showHeader {
+		if err := writeRow(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range cells {
+		if err := writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Converts a value to a table cell. Styled text keeps its styles.
+func tableCell(v any) ui.Text {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case ui.Text:
+		cell := make(ui.Text, len(v))
+		for i, seg := range v {
+			cell[i] = &ui.Segment{Style: seg.Style, Text: tableCellReplacer.Replace(seg.Text)}
+		}
+		return cell
+	default:
+		return ui.T(tableCellReplacer.Replace(vals.ToString(v)))
+	}
+}
+
+// Returns the visual width of the text, not counting the styles.
+func textWidth(t ui.Text) int {
+	w := 0
+	for _, seg := range t {
+		w += wcwidth.Of(seg.Text)
+	}
+	return w
+}
+
+// Renders a table cell, only using escape sequences if it has any styles.
+func cellString(t ui.Text) string {
+	for _, seg := range t {
+		if seg.SGR() != "" {
+			return t.VTString()
+		}
+	}
+	var sb strings.Builder
+	for _, seg := range t {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
+
+func capture(fm *Frame, f Callable) (vals.Map, error) {
+	outPort, collectOut, err := CapturePort()
+	if err != nil {
+		return nil, err
+	}
+	errPort, collectErr, err := CapturePort()
+	if err != nil {
+		collectOut()
+		return nil, err
+	}
+	newFm := fm.Fork()
+	newFm.ports[1] = outPort
+	*growAccess(&newFm.ports, 2) = errPort
+	exc := f.Call(newFm, NoArgs, NoOpts)
+	values, outBytes := collectOut()
+	// Values written to stderr are discarded, like they are normally.
+	_, errBytes := collectErr()
+
+	var status any = OK
+	if exc != nil {
+		status = exc
+	}
+	return vals.MakeMap(
+		"out", string(outBytes),
+		"err", string(errBytes),
+		"values", vals.MakeList(values...),
+		"status", status), nil
+}
@@ -0,0 +1,65 @@
+package eval
+
+// Job control builtins. See job.go for the implementation of jobs.
+
+func init() {
+	addBuiltinFns(map[string]any{
+		"jobs":   jobs,
+		"fg":     fg,
+		"bg":     bg,
+		"disown": disown,
+	})
+}
+
+func jobs(fm *Frame) error {
+	out := fm.ValueOutput()
+	for _, j := range fm.Evaler.getJobs() {
+		err := out.Put(j.info())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fg(fm *Frame, ids ...int) error {
+	j, err := fm.Evaler.findJob(ids)
+	if err != nil {
+		return err
+	}
+	running, err := j.bringToFg()
+	if err != nil {
+		return err
+	}
+	if !running || j.waitFg() {
+		return nil
+	}
+	return j.err
+}
+
+func bg(fm *Frame, ids ...int) error {
+	j, err := fm.Evaler.findJob(ids)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	if !j.stopped {
+		j.mu.Unlock()
+		return ErrJobNotStopped
+	}
+	j.stopped = false
+	j.mu.Unlock()
+	return j.resume()
+}
+
+func disown(fm *Frame, ids ...int) error {
+	j, err := fm.Evaler.findJob(ids)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.disowned = true
+	j.mu.Unlock()
+	fm.Evaler.removeJob(j)
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"math"
 	"math/big"
 	"strconv"
+	"unicode"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
@@ -25,6 +27,7 @@ func init() {
 		"!=s": func(a, b string) bool { return a != b },
 
 		"to-string": toString,
+		"to-bytes":  toBytes,
 
 		"base": base,
 
@@ -55,6 +58,56 @@ func toString(fm *Frame, args ...any) error {
 	return nil
 }
 
+type toBytesOpts struct{ Codepoints bool }
+
+func (*toBytesOpts) SetDefaultOptions() {}
+
+func toBytes(opts toBytesOpts, v any) (vals.Bytes, error) {
+	switch v := v.(type) {
+	case vals.Bytes:
+		return v, nil
+	case string:
+		return vals.Bytes(v), nil
+	}
+	var buf []byte
+	var errElem error
+	errIterate := vals.Iterate(v, func(elem any) bool {
+		var b int
+		if errElem = vals.ScanToGo(elem, &b); errElem != nil {
+			return false
+		}
+		if opts.Codepoints {
+			if b < 0 || b > unicode.MaxRune {
+				errElem = errs.OutOfRange{What: "codepoint",
+					ValidLow: "0", ValidHigh: strconv.Itoa(unicode.MaxRune),
+					Actual: vals.ToString(elem)}
+				return false
+			}
+			if !utf8.ValidRune(rune(b)) {
+				errElem = errs.BadValue{What: "codepoint",
+					Valid: "valid Unicode codepoint", Actual: vals.ToString(elem)}
+				return false
+			}
+			buf = utf8.AppendRune(buf, rune(b))
+			return true
+		}
+		if b < 0 || b > 255 {
+			errElem = errs.OutOfRange{What: "byte",
+				ValidLow: "0", ValidHigh: "255", Actual: vals.ToString(elem)}
+			return false
+		}
+		buf = append(buf, byte(b))
+		return true
+	})
+	if errIterate != nil {
+		return "", errIterate
+	}
+	if errElem != nil {
+		return "", errElem
+	}
+	return vals.Bytes(buf), nil
+}
+
 func base(fm *Frame, b int, nums ...vals.Num) error {
 	if b < 2 || b > 36 {
 		return errs.OutOfRange{What: "base",
@@ -29,26 +29,25 @@ func init() {
 	})
 }
 
-func all(fm *Frame, inputs Inputs) error {
+func all(fm *Frame, inputs StoppableInputs) error {
 	out := fm.ValueOutput()
 	var errOut error
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		errOut = out.Put(v)
+		return errOut == nil
 	})
 	return errOut
 }
 
-func one(fm *Frame, inputs Inputs) error {
+func one(fm *Frame, inputs StoppableInputs) error {
 	var val any
 	n := 0
-	inputs(func(v any) {
+	inputs(func(v any) bool {
 		if n == 0 {
 			val = v
 		}
 		n++
+		return true
 	})
 	if n == 1 {
 		return fm.ValueOutput().Put(val)
@@ -56,53 +55,48 @@ func one(fm *Frame, inputs Inputs) error {
 	return errs.ArityMismatch{What: "values", ValidLow: 1, ValidHigh: 1, Actual: n}
 }
 
-func take(fm *Frame, n int, inputs Inputs) error {
+func take(fm *Frame, n int, inputs StoppableInputs) error {
+	if n <= 0 {
+		return nil
+	}
 	out := fm.ValueOutput()
 	var errOut error
 	i := 0
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
-		if i < n {
-			errOut = out.Put(v)
-		}
+	inputs(func(v any) bool {
+		errOut = out.Put(v)
 		i++
+		return errOut == nil && i < n
 	})
 	return errOut
 }
 
-func drop(fm *Frame, n int, inputs Inputs) error {
+func drop(fm *Frame, n int, inputs StoppableInputs) error {
 	out := fm.ValueOutput()
 	var errOut error
 	i := 0
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		if i >= n {
 			errOut = out.Put(v)
 		}
 		i++
+		return errOut == nil
 	})
 	return errOut
 }
 
-func compact(fm *Frame, inputs Inputs) error {
+func compact(fm *Frame, inputs StoppableInputs) error {
 	out := fm.ValueOutput()
 	first := true
 	var errOut error
 	var prev any
 
-	inputs(func(v any) {
-		if errOut != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		if first || !vals.Equal(v, prev) {
 			errOut = out.Put(v)
 			first = false
 			prev = v
 		}
+		return errOut == nil
 	})
 	return errOut
 }
@@ -115,8 +109,9 @@ func count(fm *Frame, args ...any) (int, error) {
 	switch nargs := len(args); nargs {
 	case 0:
 		// Count inputs.
-		fm.IterateInputs(func(any) {
+		fm.IterateStoppableInputs(func(any) bool {
 			n++
+			return true
 		})
 	case 1:
 		// Get length of argument.
@@ -124,12 +119,15 @@ func count(fm *Frame, args ...any) (int, error) {
 		if len := vals.Len(v); len >= 0 {
 			n = len
 		} else {
-			err := vals.Iterate(v, func(any) bool {
+			if !vals.CanIterate(v) {
+				return 0, fmt.Errorf("cannot get length of a %s", vals.Kind(v))
+			}
+			err := iterate(fm, v, func(any) bool {
 				n++
 				return true
 			})
 			if err != nil {
-				return 0, fmt.Errorf("cannot get length of a %s", vals.Kind(v))
+				return 0, err
 			}
 		}
 	default:
@@ -153,12 +151,15 @@ func (opt *orderOptions) SetDefaultOptions() {}
 // &less-than options are specified.
 var ErrBothTotalAndLessThan = errors.New("both &total and &less-than specified")
 
-func order(fm *Frame, opts orderOptions, inputs Inputs) error {
+func order(fm *Frame, opts orderOptions, inputs StoppableInputs) error {
 	if opts.Total && opts.LessThan != nil {
 		return ErrBothTotalAndLessThan
 	}
 	var values, keys []any
-	inputs(func(v any) { values = append(values, v) })
+	inputs(func(v any) bool {
+		values = append(values, v)
+		return true
+	})
 	if opts.Key != nil {
 		keys = make([]any, len(values))
 		for i, value := range values {
@@ -262,12 +263,9 @@ func (s *slice) Swap(i, j int) {
 	}
 }
 
-func keepIf(fm *Frame, f Callable, inputs Inputs) error {
+func keepIf(fm *Frame, f Callable, inputs StoppableInputs) error {
 	var err error
-	inputs(func(v any) {
-		if err != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		outputs, errF := fm.CaptureOutput(func(fm *Frame) error {
 			return f.Call(fm, []any{v}, NoOpts)
 		})
@@ -287,6 +285,7 @@ func keepIf(fm *Frame, f Callable, inputs Inputs) error {
 				err = fm.ValueOutput().Put(v)
 			}
 		}
+		return err == nil
 	})
 	return err
 }
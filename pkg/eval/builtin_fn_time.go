@@ -29,22 +29,9 @@ var (
 )
 
 func sleep(fm *Frame, duration any) error {
-	var f float64
-	var d time.Duration
-
-	if err := vals.ScanToGo(duration, &f); err == nil {
-		d = time.Duration(f * float64(time.Second))
-	} else {
-		// See if it is a duration string rather than a simple number.
-		switch duration := duration.(type) {
-		case string:
-			d, err = time.ParseDuration(duration)
-			if err != nil {
-				return ErrInvalidSleepDuration
-			}
-		default:
-			return ErrInvalidSleepDuration
-		}
+	d, ok := parseDuration(duration)
+	if !ok {
+		return ErrInvalidSleepDuration
 	}
 
 	if d < 0 {
@@ -53,12 +40,31 @@ func sleep(fm *Frame, duration any) error {
 
 	select {
 	case <-fm.Context().Done():
-		return ErrInterrupted
+		return canceledError(fm.Context())
 	case <-timeAfter(fm, d):
 		return nil
 	}
 }
 
+// Parses a duration, which is either a number of seconds, a string accepted by
+// [time.ParseDuration], or a duration value from the time: module. The duration
+// may be negative.
+func parseDuration(v any) (time.Duration, bool) {
+	if d, ok := v.(interface{ GoDuration() time.Duration }); ok {
+		return d.GoDuration(), true
+	}
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		return time.Duration(f * float64(time.Second)), true
+	}
+	// See if it is a duration string rather than a simple number.
+	if s, ok := v.(string); ok {
+		d, err := time.ParseDuration(s)
+		return d, err == nil
+	}
+	return 0, false
+}
+
 type timeOpt struct{ OnEnd Callable }
 
 func (o *timeOpt) SetDefaultOptions() {}
@@ -59,6 +59,14 @@ func (err PluginLoadError) Error() string {
 // Unwrap returns the wrapped error.
 func (err PluginLoadError) Unwrap() error { return err.err }
 
+// NoMatch encodes an error where no clause of a match form matches the value.
+type NoMatch struct{ value any }
+
+// Error implements the error interface.
+func (err NoMatch) Error() string {
+	return "no pattern matches " + vals.ReprPlain(err.value)
+}
+
 func init() {
 	// Needed to avoid initialization loop
 	builtinSpecials = map[string]compileBuiltin{
@@ -76,6 +84,7 @@ func init() {
 		"coalesce": compileCoalesce,
 
 		"if":    compileIf,
+		"match": compileMatch,
 		"while": compileWhile,
 		"for":   compileFor,
 		"try":   compileTry,
@@ -335,7 +344,8 @@ func compileFn(cp *compiler, fn *parse.Form) effectOp {
 
 	// Define the variable before compiling the body, so that the body may refer
 	// to the function itself.
-	index := cp.thisScope().add(name + FnSuffix)
+	index := cp.declare(name+FnSuffix, fnDecl, fn.Args[0])
+	cp.thisScope().infos[index].sig = lambdaSig(bodyNode)
 	op := cp.lambda(bodyNode)
 
 	return fnOp{fn.Args[0].Range(), index, op}
@@ -388,7 +398,12 @@ func compileUse(cp *compiler, fn *parse.Form) effectOp {
 		return nil
 	}
 
-	return useOp{fn.Range(), cp.thisScope().add(name + NsSuffix), spec}
+	index := cp.declare(name+NsSuffix, moduleDecl, fn)
+	if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		// The same namespace will be returned by the use function at runtime.
+		cp.thisScope().infos[index].mod = cp.modules[spec]
+	}
+	return useOp{fn.Range(), index, spec}
 }
 
 type useOp struct {
@@ -648,6 +663,332 @@ func (op *ifOp) exec(fm *Frame) Exception {
 	return nil
 }
 
+// MatchForm = 'match' Compound '{' { MatchClause } '}'
+// MatchClause = Pattern [ 'if' Compound ] Lambda
+func compileMatch(cp *compiler, fn *parse.Form) effectOp {
+	args := getArgs(cp, fn)
+	valueNode := args.get(0, "value").any()
+	clausesNode := args.get(1, "match clauses").thunk()
+	if !args.finish() {
+		return nil
+	}
+
+	valueOp := cp.compoundOp(valueNode)
+	var clauses []matchClause
+	for _, pn := range clausesNode.Chunk.Pipelines {
+		if len(pn.Forms) != 1 || pn.Background {
+			cp.errorpf(pn, "match clause must be a single command")
+			continue
+		}
+		form := pn.Forms[0]
+		if len(form.Opts) > 0 || len(form.Redirs) > 0 {
+			cp.errorpf(form, "match clause must not have options or redirections")
+			continue
+		}
+		clauseArgs := getArgs(cp, form)
+		var guardNode *parse.Compound
+		i := 0
+		if clauseArgs.hasKeyword(0, "if") {
+			guardNode = clauseArgs.get(1, "guard").any()
+			i = 2
+		}
+		bodyNode := clauseArgs.get(i, "match body").thunk()
+		if !clauseArgs.finish() {
+			continue
+		}
+
+		// Each clause has its own scope, like a lambda, so that the variables
+		// bound by the pattern are only visible in the guard and the body. The
+		// pattern is compiled first, so that they can use these variables.
+		local, capture := cp.pushScope()
+		clause := matchClause{Ranging: form.Range(), pattern: cp.matchPattern(form.Head)}
+		if guardNode != nil {
+			clause.guardOp = cp.compoundOp(guardNode)
+		}
+		clause.bodyOp = cp.primaryOp(bodyNode)
+		clause.local = local.infos
+		clause.capture = capture
+		cp.popScope()
+		clauses = append(clauses, clause)
+	}
+
+	return &matchOp{fn.Range(), valueOp, clauses}
+}
+
+type matchClause struct {
+	diag.Ranging
+	pattern matchPattern
+	guardOp valuesOp // nil if there is no guard
+	bodyOp  valuesOp
+	local   []staticVarInfo
+	capture *staticUpNs
+}
+
+type matchOp struct {
+	diag.Ranging
+	valueOp valuesOp
+	clauses []matchClause
+}
+
+func (op *matchOp) exec(fm *Frame) Exception {
+	value, exc := evalForValue(fm, op.valueOp, "value being matched")
+	if exc != nil {
+		return exc
+	}
+	for _, clause := range op.clauses {
+		// Clauses are not run as forms, but should still be covered when
+		// they are tried.
+		fm.recordCoverage(clause.Ranging)
+		clauseFm := fm.Fork()
+		clauseFm.up = captureUpNs(fm, clause.capture)
+		clauseFm.local = &Ns{
+			make([]vars.Var, len(clause.local)),
+			make([]staticVarInfo, len(clause.local))}
+		for i, info := range clause.local {
+			clauseFm.local.infos[i] = info
+			clauseFm.local.slots[i] = MakeVarFromName(info.name)
+		}
+		var bindings []matchBinding
+		matched, exc := clause.pattern.match(clauseFm, value, &bindings)
+		if exc != nil {
+			return exc
+		}
+		if !matched {
+			continue
+		}
+		for _, b := range bindings {
+			variable, err := derefLValue(clauseFm, b.lvalue)
+			if err != nil {
+				return fm.errorp(b.lvalue, err)
+			}
+			if exc := set(clauseFm, b.lvalue, variable, b.value, nil); exc != nil {
+				return exc
+			}
+		}
+		if clause.guardOp != nil {
+			guardValues, exc := clause.guardOp.exec(clauseFm.Fork())
+			if exc != nil {
+				return exc
+			}
+			if !allTrue(guardValues) {
+				continue
+			}
+		}
+		body := execLambdaOp(clauseFm, clause.bodyOp)
+		return fm.errorp(op, body.Call(fm.Fork(), NoArgs, NoOpts))
+	}
+	return fm.errorp(op, NoMatch{value})
+}
+
+// A compiled pattern of a match clause.
+type matchPattern interface {
+	// Matches the value against the pattern. Variables to bind are appended to
+	// bindings, and only assigned when the whole pattern matches.
+	match(fm *Frame, v any, bindings *[]matchBinding) (bool, Exception)
+}
+
+type matchBinding struct {
+	lvalue lvalue
+	value  any
+}
+
+func (cp *compiler) matchPattern(n *parse.Compound) matchPattern {
+	if len(n.Indexings) == 1 && len(n.Indexings[0].Indices) == 0 {
+		head := n.Indexings[0].Head
+		switch head.Type {
+		case parse.Bareword:
+			if head.Value == "_" {
+				return anyPattern{}
+			}
+		case parse.Braced:
+			return cp.bindPattern(head)
+		case parse.List:
+			return cp.listMatchPattern(head)
+		case parse.Map:
+			return cp.mapMatchPattern(head)
+		}
+	}
+	return literalPattern{cp.compoundOp(n)}
+}
+
+// Declares a variable bound by a pattern. Returns nil if the name is _.
+func (cp *compiler) matchVariable(r diag.Ranger, name string) *lvalue {
+	if name == "_" {
+		return nil
+	}
+	if !parse.ValidLHSVariable(&parse.Primary{Type: parse.Bareword, Value: name}, false) {
+		cp.errorpf(r, "invalid variable name %s in pattern", parse.Quote(name))
+		return nil
+	}
+	ref := &varRef{localScope,
+		staticVarInfo{name: name}, cp.declare(name, varDecl, r), nil}
+	return &lvalue{Ranging: r.Range(), ref: ref}
+}
+
+// The pattern _, which matches anything.
+type anyPattern struct{}
+
+func (anyPattern) match(*Frame, any, *[]matchBinding) (bool, Exception) {
+	return true, nil
+}
+
+// Any pattern that is not special, which is evaluated and compared with the
+// value.
+type literalPattern struct{ op valuesOp }
+
+func (p literalPattern) match(fm *Frame, v any, _ *[]matchBinding) (bool, Exception) {
+	want, exc := evalForValue(fm, p.op, "pattern")
+	if exc != nil {
+		return false, exc
+	}
+	return vals.Equal(v, want), nil
+}
+
+// A pattern like {name}, {name:kind} or {:kind}, which binds the value to a
+// variable, optionally requiring it to be of a kind.
+type bindPattern struct {
+	lvalue *lvalue // nil if the value is not bound
+	kind   string  // empty if any kind is allowed
+}
+
+func (cp *compiler) bindPattern(n *parse.Primary) matchPattern {
+	var s string
+	ok := len(n.Braced) == 1
+	if ok {
+		s, ok = cmpd.StringLiteral(n.Braced[0])
+	}
+	if !ok {
+		cp.errorpf(n, "binding pattern must be like {name} or {name:kind}")
+		return anyPattern{}
+	}
+	name, kind, _ := strings.Cut(s, ":")
+	if name == "" {
+		name = "_"
+	}
+	return bindPattern{cp.matchVariable(n, name), kind}
+}
+
+func (p bindPattern) match(fm *Frame, v any, bindings *[]matchBinding) (bool, Exception) {
+	if p.kind != "" && vals.Kind(v) != p.kind {
+		return false, nil
+	}
+	if p.lvalue != nil {
+		*bindings = append(*bindings, matchBinding{*p.lvalue, v})
+	}
+	return true, nil
+}
+
+// A pattern like [a {b} @c], which matches lists.
+type listMatchPattern struct {
+	elems []matchPattern
+	// Number of elements before the rest variable, or -1 if there is no rest
+	// variable.
+	rest   int
+	restLV *lvalue
+}
+
+func (cp *compiler) listMatchPattern(n *parse.Primary) matchPattern {
+	p := listMatchPattern{rest: -1}
+	for _, elem := range n.Elements {
+		if name, ok := cmpd.StringLiteral(elem); ok && strings.HasPrefix(name, "@") {
+			if p.rest != -1 {
+				cp.errorpf(elem, "at most one rest variable is allowed")
+				continue
+			}
+			p.rest = len(p.elems)
+			p.restLV = cp.matchVariable(elem, name[1:])
+			continue
+		}
+		p.elems = append(p.elems, cp.matchPattern(elem))
+	}
+	return p
+}
+
+func (p listMatchPattern) match(fm *Frame, v any, bindings *[]matchBinding) (bool, Exception) {
+	list, ok := v.(vals.List)
+	if !ok {
+		return false, nil
+	}
+	if (p.rest == -1 && list.Len() != len(p.elems)) || list.Len() < len(p.elems) {
+		return false, nil
+	}
+	values := make([]any, 0, list.Len())
+	for it := list.Iterator(); it.HasElem(); it.Next() {
+		values = append(values, it.Elem())
+	}
+	restLen := len(values) - len(p.elems)
+	for i, elem := range p.elems {
+		j := i
+		if p.rest != -1 && i >= p.rest {
+			j += restLen
+		}
+		matched, exc := elem.match(fm, values[j], bindings)
+		if !matched || exc != nil {
+			return false, exc
+		}
+	}
+	if p.restLV != nil {
+		restValues := values[p.rest : p.rest+restLen]
+		*bindings = append(*bindings,
+			matchBinding{*p.restLV, vals.MakeList(restValues...)})
+	}
+	return true, nil
+}
+
+// A pattern like [&a &b={c} &d=foo], which matches maps and other values whose
+// keys can be iterated, like records.
+type mapMatchPattern struct{ keys []matchKey }
+
+type matchKey struct {
+	keyOp   valuesOp
+	pattern matchPattern
+}
+
+func (cp *compiler) mapMatchPattern(n *parse.Primary) matchPattern {
+	var p mapMatchPattern
+	for _, pair := range n.MapPairs {
+		keyOp := cp.compoundOp(pair.Key)
+		var pattern matchPattern
+		if pair.Value == nil {
+			// &key binds the value to a variable of the same name.
+			name, ok := cmpd.StringLiteral(pair.Key)
+			if !ok {
+				cp.errorpf(pair.Key, "map pattern key without a value must be a variable name")
+				continue
+			}
+			pattern = bindPattern{cp.matchVariable(pair.Key, name), ""}
+		} else {
+			pattern = cp.matchPattern(pair.Value)
+		}
+		p.keys = append(p.keys, matchKey{keyOp, pattern})
+	}
+	return p
+}
+
+func (p mapMatchPattern) match(fm *Frame, v any, bindings *[]matchBinding) (bool, Exception) {
+	if err := vals.IterateKeys(v, func(any) bool { return false }); err != nil {
+		return false, nil
+	}
+	for _, key := range p.keys {
+		k, exc := evalForValue(fm, key.keyOp, "map pattern key")
+		if exc != nil {
+			return false, exc
+		}
+		if !vals.HasKey(v, k) {
+			return false, nil
+		}
+		elem, err := vals.Index(v, k)
+		if err != nil {
+			return false, nil
+		}
+		matched, exc := key.pattern.match(fm, elem, bindings)
+		if !matched || exc != nil {
+			return false, exc
+		}
+	}
+	return true, nil
+}
+
 func compileWhile(cp *compiler, fn *parse.Form) effectOp {
 	args := getArgs(cp, fn)
 	condNode := args.get(0, "condition").any()
@@ -736,9 +1077,13 @@ type forOp struct {
 }
 
 func (op *forOp) exec(fm *Frame) Exception {
-	variable, err := derefLValue(fm, op.lvalue)
-	if err != nil {
-		return fm.errorp(op.lvalue, err)
+	var variable vars.Var
+	if op.lvalue.pattern == nil {
+		var err error
+		variable, err = derefLValue(fm, op.lvalue)
+		if err != nil {
+			return fm.errorp(op.lvalue, err)
+		}
 	}
 	iterable, err := evalForValue(fm, op.iterOp, "value being iterated")
 	if err != nil {
@@ -750,14 +1095,18 @@ func (op *forOp) exec(fm *Frame) Exception {
 
 	iterated := false
 	var errElement error
-	errIterate := vals.Iterate(iterable, func(v any) bool {
+	errIterate := iterate(fm, iterable, func(v any) bool {
 		iterated = true
-		err := variable.Set(v)
-		if err != nil {
+		if variable == nil {
+			if exc := destructure(fm, op.lvalue, v, nil); exc != nil {
+				errElement = exc
+				return false
+			}
+		} else if err := variable.Set(v); err != nil {
 			errElement = err
 			return false
 		}
-		err = body.Call(fm.Fork(), NoArgs, NoOpts)
+		err := body.Call(fm.Fork(), NoArgs, NoOpts)
 		if err != nil {
 			exc := err.(Exception)
 			if exc.Reason() == Continue {
@@ -821,6 +1170,9 @@ func compileTry(cp *compiler, fn *parse.Form) effectOp {
 	bodyOp = cp.primaryOp(bodyNode)
 	if catchVarNode != nil {
 		catchVar = cp.compileOneLValue(catchVarNode, setLValue|newLValue)
+		if catchVar.pattern != nil {
+			cp.errorpf(catchVarNode, "exception variable must not be a pattern")
+		}
 	}
 	if catchNode != nil {
 		catchOp = cp.primaryOp(catchNode)
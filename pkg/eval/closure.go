@@ -26,6 +26,7 @@ type Closure struct {
 	Src         parse.Source
 	DefRange    diag.Ranging
 	op          effectOp
+	argPatterns []argPattern
 	newLocal    []staticVarInfo
 	captured    *Ns
 }
@@ -99,7 +100,9 @@ func (c *Closure) Call(fm *Frame, args []any, opts map[string]any) error {
 	local := &Ns{make([]vars.Var, localSize), make([]staticVarInfo, localSize)}
 
 	for i, name := range c.ArgNames {
-		local.infos[i] = staticVarInfo{name, false, false}
+		// Arguments that are destructuring patterns have empty names and
+		// can't be referred to.
+		local.infos[i] = staticVarInfo{name: name, deleted: name == ""}
 	}
 	if c.RestArg == -1 {
 		for i := range c.ArgNames {
@@ -123,7 +126,7 @@ func (c *Closure) Call(fm *Frame, args []any, opts map[string]any) error {
 		if !ok {
 			v = c.OptDefaults[i]
 		}
-		local.infos[offset+i] = staticVarInfo{name, false, false}
+		local.infos[offset+i] = staticVarInfo{name: name}
 		local.slots[offset+i] = vars.FromInit(v)
 	}
 
@@ -136,6 +139,16 @@ func (c *Closure) Call(fm *Frame, args []any, opts map[string]any) error {
 
 	fm.local = local
 	fm.src = c.Src
+	if fm.prof != nil {
+		defer fm.enterProfile(c.DefRange, true)()
+	}
+	for _, p := range c.argPatterns {
+		exc := destructure(fm, p.lvalue, local.slots[p.index].Get(), nil)
+		if exc != nil {
+			return exc
+		}
+	}
+	fm.futures = &futureScope{}
 	fm.defers = new([]func(*Frame) Exception)
 	exc := c.op.exec(fm)
 	excDefer := fm.runDefers()
@@ -143,6 +156,9 @@ func (c *Closure) Call(fm *Frame, args []any, opts map[string]any) error {
 	if excDefer != nil && exc == nil {
 		exc = excDefer
 	}
+	if excFutures := fm.futures.exit(exc != nil); excFutures != nil && exc == nil {
+		exc = excFutures
+	}
 	return exc
 }
 
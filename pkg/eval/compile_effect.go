@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
 	"sync/atomic"
 
@@ -24,7 +25,11 @@ type chunkOp struct {
 }
 
 func (cp *compiler) chunkOp(n *parse.Chunk) *chunkOp {
-	return &chunkOp{n.Range(), cp.pipelineOps(n.Pipelines)}
+	op := &chunkOp{n.Range(), cp.pipelineOps(n.Pipelines)}
+	if cp.lint != nil {
+		cp.lintUnreachable(n)
+	}
+	return op
 }
 
 func (op *chunkOp) exec(fm *Frame) Exception {
@@ -38,7 +43,7 @@ func (op *chunkOp) exec(fm *Frame) Exception {
 	// We also check for interrupts before each pipeline, so there is no
 	// need to check it before the chunk or after each pipeline.
 	if fm.Canceled() {
-		return fm.errorp(op, ErrInterrupted)
+		return fm.errorp(op, canceledError(fm.ctx))
 	}
 	return nil
 }
@@ -84,14 +89,24 @@ func (fop formOwnedPort) close(p *Port) {
 
 func (op *pipelineOp) exec(fm *Frame) Exception {
 	if fm.Canceled() {
-		return fm.errorp(op, ErrInterrupted)
+		return fm.errorp(op, canceledError(fm.ctx))
 	}
 
+	// The job started by this pipeline, if any.
+	var j *job
 	if op.bg {
 		fm = fm.Fork()
 		fm.ctx = context.Background()
 		fm.background = true
+		fm.detached = true
+		j = newJob(fm.Evaler, op.source, false, fm.jobControl)
+		fm.job = j
+		fm.Evaler.addJob(j)
 		fm.Evaler.addNumBgJobs(1)
+	} else if fm.job == nil && fm.jobControl {
+		fm = fm.Fork()
+		j = newJob(fm.Evaler, op.source, true, true)
+		fm.job = j
 	}
 
 	nforms := len(op.forms)
@@ -105,7 +120,16 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 	// For each form, create a dedicated evalCtx and run asynchronously
 	for i, form := range op.forms {
 		newFm := fm.Fork()
+		// Process substitutions started by the form are appended to
+		// procSubs; clip it so that forms running concurrently don't share
+		// the same backing array.
+		newFm.procSubs = slices.Clip(newFm.procSubs)
+		nProcSubs := len(newFm.procSubs)
 		var fops []formOwnedPort
+		// The output pipe when it is shared by stdout and stderr with "|&".
+		// It is not owned by either port, so that redirecting one of them
+		// doesn't close the pipe for the other.
+		var sharedOut *Port
 		inputIsPipe := i > 0
 		outputIsPipe := i < nforms-1
 		if inputIsPipe {
@@ -127,13 +151,18 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 			newFm.ports[1] = &Port{
 				File: writer, Chan: ch,
 				sendStop: sendStop, sendError: sendError, readerGone: readerGone}
-			*growAccess(&fops, 1) = formOwnedPort{File: true, Chan: true}
+			if form.pipesStderr {
+				*growAccess(&newFm.ports, 2) = newFm.ports[1]
+				sharedOut = newFm.ports[1]
+			} else {
+				*growAccess(&fops, 1) = formOwnedPort{File: true, Chan: true}
+			}
 			nextIn = &Port{
 				File: reader, Chan: ch,
 				// Store in input port for ease of retrieval later
 				sendStop: sendStop, sendError: sendError, readerGone: readerGone}
 		}
-		f := func(form *formOp, fops []formOwnedPort, pexc *Exception) {
+		f := func(form *formOp, fops []formOwnedPort, sharedOut *Port, pexc *Exception) {
 			exc := form.exec(newFm, &fops)
 			if exc != nil && !(outputIsPipe && isReaderGone(exc)) {
 				*pexc = exc
@@ -147,35 +176,37 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 			for i, fop := range fops {
 				fop.close(newFm.ports[i])
 			}
+			if sharedOut != nil {
+				formOwnedPort{File: true, Chan: true}.close(sharedOut)
+			}
+			// Wait for process substitutions after closing the ports, since
+			// the form may have redirected them to a >(...).
+			if subs := newFm.procSubs[nProcSubs:]; len(subs) > 0 {
+				*pexc = waitProcSubs(newFm, form, subs, *pexc)
+			}
 			wg.Done()
 		}
-		if i == nforms-1 && !op.bg {
-			f(form, fops, &excs[i])
+		if i == nforms-1 && j == nil {
+			f(form, fops, sharedOut, &excs[i])
 		} else {
-			go f(form, fops, &excs[i])
+			go f(form, fops, sharedOut, &excs[i])
 		}
 	}
 
-	if op.bg {
-		// Background job, wait for form termination asynchronously.
-		go func() {
-			wg.Wait()
-			fm.Evaler.addNumBgJobs(-1)
-			if notify := fm.Evaler.BgJobNotify; notify != nil {
-				msg := "job " + op.source + " finished"
-				err := MakePipelineError(excs)
-				if err != nil {
-					msg += ", errors = " + err.Error()
-				}
-				if fm.Evaler.getNotifyBgJobSuccess() || err != nil {
-					notify(msg)
-				}
-			}
-		}()
+	if j == nil {
+		wg.Wait()
+		return fm.errorp(op, MakePipelineError(excs))
+	}
+	// Wait for form termination asynchronously, so that a foreground job can
+	// be moved to the background when it gets stopped.
+	go func() {
+		wg.Wait()
+		j.finish(MakePipelineError(excs))
+	}()
+	if op.bg || j.waitFg() {
 		return nil
 	}
-	wg.Wait()
-	return fm.errorp(op, MakePipelineError(excs))
+	return fm.errorp(op, j.err)
 }
 
 func isReaderGone(exc Exception) bool {
@@ -185,8 +216,9 @@ func isReaderGone(exc Exception) bool {
 
 type formOp struct {
 	diag.Ranging
-	redirs []*redirOp
-	body   formBody
+	redirs      []*redirOp
+	body        formBody
+	pipesStderr bool
 }
 
 func (cp *compiler) formOps(ns []*parse.Form) []*formOp {
@@ -201,7 +233,7 @@ func (cp *compiler) formOp(n *parse.Form) *formOp {
 	redirOps := cp.redirOps(n.Redirs)
 	body := cp.formBody(n)
 
-	return &formOp{n.Range(), redirOps, body}
+	return &formOp{n.Range(), redirOps, body, n.PipesStderr}
 }
 
 type formBody struct {
@@ -237,6 +269,7 @@ func (cp *compiler) formBody(n *parse.Form) formBody {
 		// commands are already handled above).
 		if _, fnRef := resolveCmdHeadInternally(cp, head, n.Head); fnRef != nil {
 			headOp = variableOp{n.Head.Range(), false, head + FnSuffix, fnRef}
+			cp.checkCall(n, fnRef)
 		} else {
 			cp.autofixUnresolvedVar(head + FnSuffix)
 			if cp.currentPragma().unknownCommandIsExternal || fsutil.DontSearch(head) {
@@ -259,6 +292,14 @@ func (op *formOp) exec(fm *Frame, fops *[]formOwnedPort) (errRet Exception) {
 	// fm here is always a sub-frame created in compiler.pipeline, so it can
 	// be safely modified.
 
+	if hook := fm.Evaler.BeforeForm; hook != nil {
+		hook(fm, op.Ranging)
+	}
+	if fm.prof != nil {
+		defer fm.enterProfile(op.Ranging, false)()
+	}
+	fm.recordCoverage(op.Ranging)
+
 	// Redirections.
 	for _, redirOp := range op.redirs {
 		exc := redirOp.exec(fm, fops)
@@ -374,12 +415,18 @@ func (cp *compiler) redirOp(n *parse.Redir) *redirOp {
 		// TODO: Record and get redirection sign position
 		cp.errorpf(n, "bad redirection sign")
 	}
-	return &redirOp{n.Range(), dstOp, cp.compoundOp(n.Right), n.RightIsFd, n.Mode, flag}
+	var srcOp valuesOp
+	if n.Mode == parse.ReadHereDoc {
+		srcOp = cp.hereDocOp(n.HereDoc)
+	} else {
+		srcOp = cp.compoundOp(n.Right)
+	}
+	return &redirOp{n.Range(), dstOp, srcOp, n.RightIsFd, n.Mode, flag}
 }
 
 func makeFlag(m parse.RedirMode) int {
 	switch m {
-	case parse.Read:
+	case parse.Read, parse.ReadHereDoc, parse.ReadHereString:
 		return os.O_RDONLY
 	case parse.Write:
 		return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
@@ -401,7 +448,7 @@ func (op *redirOp) exec(fm *Frame, fops *[]formOwnedPort) Exception {
 	if op.dstOp == nil {
 		// No explicit FD destination specified; use default destinations
 		switch op.mode {
-		case parse.Read:
+		case parse.Read, parse.ReadHereDoc, parse.ReadHereString:
 			dst = 0
 		case parse.Write, parse.ReadWrite, parse.Append:
 			dst = 1
@@ -424,6 +471,24 @@ func (op *redirOp) exec(fm *Frame, fops *[]formOwnedPort) Exception {
 		*dstFop = formOwnedPort{File: false, Chan: false}
 	}
 
+	if op.mode == parse.ReadHereDoc || op.mode == parse.ReadHereString {
+		src, exc := evalForValue(fm, op.srcOp, "here-string")
+		if exc != nil {
+			return exc
+		}
+		text := vals.ToString(src)
+		if op.mode == parse.ReadHereString {
+			text += "\n"
+		}
+		port, err := textRedirPort(text)
+		if err != nil {
+			return fm.errorpf(op, "failed to create pipe: %s", err)
+		}
+		*dstPort = port
+		dstFop.File = true
+		return nil
+	}
+
 	if op.srcIsFd {
 		src, err := evalForFd(fm, op.srcOp, true, "redirection source")
 		if err != nil {
@@ -24,6 +24,26 @@ type lvalue struct {
 	ref      *varRef
 	indexOps []valuesOp
 	ends     []int
+	// If non-nil, the lvalue is a destructuring pattern and the fields above
+	// are not used.
+	pattern *pattern
+}
+
+// Parsed destructuring pattern, either a list pattern like [a [b c] @d], or a
+// map pattern like [&a &b=default].
+type pattern struct {
+	isMap bool
+	// Elements of a list pattern.
+	elems lvaluesGroup
+	// Keys of a map pattern.
+	keys []patternKey
+}
+
+// A key in a map pattern. The name of the variable is also used as the key.
+type patternKey struct {
+	key       string
+	lvalue    lvalue
+	defaultOp valuesOp // nil if there is no default value
 }
 
 type lvalueFlag uint
@@ -56,6 +76,9 @@ func (cp *compiler) compileCompoundLValues(ns []*parse.Compound, f lvalueFlag) l
 var dummyLValuesGroup = lvaluesGroup{[]lvalue{{}}, -1}
 
 func (cp *compiler) compileIndexingLValue(n *parse.Indexing, f lvalueFlag) lvaluesGroup {
+	if isPattern(n) {
+		return lvaluesGroup{[]lvalue{cp.compilePattern(n.Head, f)}, -1}
+	}
 	if !parse.ValidLHSVariable(n.Head, true) {
 		cp.errorpf(n.Head, "lvalue must be valid literal variable names")
 		return dummyLValuesGroup
@@ -74,6 +97,10 @@ func (cp *compiler) compileIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalu
 			cp.errorpf(n, "variable $%s is read-only", parse.Quote(qname))
 			return dummyLValuesGroup
 		}
+		if ref != nil && len(ref.subNames) == 0 {
+			// The variable may no longer hold the value known at compile time.
+			cp.forgetStaticValue(qname)
+		}
 	}
 	if ref == nil {
 		if f&newLValue == 0 {
@@ -90,7 +117,7 @@ func (cp *compiler) compileIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalu
 			// Unqualified name - implicit local
 			name := segs[0]
 			ref = &varRef{localScope,
-				staticVarInfo{name, false, false}, cp.thisScope().add(name), nil}
+				staticVarInfo{name: name}, cp.declare(name, varDecl, n), nil}
 		} else {
 			cp.errorpf(n, "cannot create variable $%s; "+
 				"new variables can only be created in the current scope",
@@ -104,7 +131,7 @@ func (cp *compiler) compileIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalu
 	for i, idx := range n.Indices {
 		ends[i+1] = idx.Range().To
 	}
-	lv := lvalue{n.Range(), ref, cp.arrayOps(n.Indices), ends}
+	lv := lvalue{n.Range(), ref, cp.arrayOps(n.Indices), ends, nil}
 	restIndex := -1
 	if sigil == "@" {
 		restIndex = 0
@@ -113,6 +140,40 @@ func (cp *compiler) compileIndexingLValue(n *parse.Indexing, f lvalueFlag) lvalu
 	return lvaluesGroup{[]lvalue{lv}, restIndex}
 }
 
+func isPattern(n *parse.Indexing) bool {
+	return len(n.Indices) == 0 && (n.Head.Type == parse.List || n.Head.Type == parse.Map)
+}
+
+func (cp *compiler) compilePattern(n *parse.Primary, f lvalueFlag) lvalue {
+	if n.Type == parse.List {
+		return lvalue{Ranging: n.Range(),
+			pattern: &pattern{elems: cp.compileCompoundLValues(n.Elements, f)}}
+	}
+	keys := make([]patternKey, 0, len(n.MapPairs))
+	for _, pair := range n.MapPairs {
+		// Compile the default value first, so that it refers to the variable
+		// in the outer scope if the key declares a new variable of the same
+		// name.
+		var defaultOp valuesOp
+		if pair.Value != nil {
+			defaultOp = cp.compoundOp(pair.Value)
+		}
+		if len(pair.Key.Indexings) != 1 || isPattern(pair.Key.Indexings[0]) ||
+			len(pair.Key.Indexings[0].Indices) > 0 {
+			cp.errorpf(pair.Key, "map pattern key must be a variable name")
+			continue
+		}
+		lv := cp.compileIndexingLValue(pair.Key.Indexings[0], f)
+		if lv.rest != -1 {
+			cp.errorpf(pair.Key, "map pattern key must not be a rest variable")
+			continue
+		}
+		_, qname := SplitSigil(pair.Key.Indexings[0].Head.Value)
+		keys = append(keys, patternKey{qname, lv.lvalues[0], defaultOp})
+	}
+	return lvalue{Ranging: n.Range(), pattern: &pattern{isMap: true, keys: keys}}
+}
+
 type assignOp struct {
 	diag.Ranging
 	lhs  lvaluesGroup
@@ -130,13 +191,9 @@ func (op *assignOp) exec(fm *Frame) Exception {
 
 func doAssign(fm *Frame, r diag.Ranger, lhs lvaluesGroup, rhs valuesOp, rc restoreCollector) Exception {
 	// Evaluate LHS.
-	variables := make([]vars.Var, len(lhs.lvalues))
-	for i, lvalue := range lhs.lvalues {
-		variable, err := derefLValue(fm, lvalue)
-		if err != nil {
-			return fm.errorp(lhs.lvalues[i], err)
-		}
-		variables[i] = variable
+	variables, exc := derefLValues(fm, lhs)
+	if exc != nil {
+		return exc
 	}
 
 	// Evaluate RHS.
@@ -146,36 +203,59 @@ func doAssign(fm *Frame, r diag.Ranger, lhs lvaluesGroup, rhs valuesOp, rc resto
 	}
 
 	// Now perform assignment.
+	return assignValues(fm, r, lhs, variables, values, "assignment right-hand-side", rc)
+}
+
+// Dereferences all the lvalues in the group that are not patterns. The
+// variables for patterns are left as nil.
+func derefLValues(fm *Frame, lhs lvaluesGroup) ([]vars.Var, Exception) {
+	variables := make([]vars.Var, len(lhs.lvalues))
+	for i, lvalue := range lhs.lvalues {
+		if lvalue.pattern != nil {
+			continue
+		}
+		variable, err := derefLValue(fm, lvalue)
+		if err != nil {
+			return nil, fm.errorp(lhs.lvalues[i], err)
+		}
+		variables[i] = variable
+	}
+	return variables, nil
+}
+
+// Assigns values to a group of lvalues, whose variables have been obtained
+// from derefLValues. The what argument describes the values in errors.
+func assignValues(fm *Frame, r diag.Ranger, lhs lvaluesGroup, variables []vars.Var, values []any, what string, rc restoreCollector) Exception {
 	if rest := lhs.rest; rest == -1 {
 		if len(variables) != len(values) {
-			return fm.errorp(r, errs.ArityMismatch{What: "assignment right-hand-side",
+			return fm.errorp(r, errs.ArityMismatch{What: what,
 				ValidLow: len(variables), ValidHigh: len(variables), Actual: len(values)})
 		}
 		for i, variable := range variables {
-			exc := set(fm, lhs.lvalues[i], variable, values[i], rc)
+			exc := assign(fm, lhs.lvalues[i], variable, values[i], rc)
 			if exc != nil {
 				return exc
 			}
 		}
 	} else {
 		if len(values) < len(variables)-1 {
-			return fm.errorp(r, errs.ArityMismatch{What: "assignment right-hand-side",
+			return fm.errorp(r, errs.ArityMismatch{What: what,
 				ValidLow: len(variables) - 1, ValidHigh: -1, Actual: len(values)})
 		}
 		for i := 0; i < rest; i++ {
-			exc := set(fm, lhs.lvalues[i], variables[i], values[i], rc)
+			exc := assign(fm, lhs.lvalues[i], variables[i], values[i], rc)
 			if exc != nil {
 				return exc
 			}
 		}
 		restOff := len(values) - len(variables)
-		exc := set(fm, lhs.lvalues[rest],
+		exc := assign(fm, lhs.lvalues[rest],
 			variables[rest], vals.MakeList(values[rest:rest+restOff+1]...), rc)
 		if exc != nil {
 			return exc
 		}
 		for i := rest + 1; i < len(variables); i++ {
-			exc := set(fm, lhs.lvalues[i], variables[i], values[i+restOff], rc)
+			exc := assign(fm, lhs.lvalues[i], variables[i], values[i+restOff], rc)
 			if exc != nil {
 				return exc
 			}
@@ -184,6 +264,60 @@ func doAssign(fm *Frame, r diag.Ranger, lhs lvaluesGroup, rhs valuesOp, rc resto
 	return nil
 }
 
+// Assigns the value to the lvalue. If the lvalue is a pattern, destructures the
+// value; otherwise sets the variable, which must be obtained from
+// derefLValue(fm, lv).
+func assign(fm *Frame, lv lvalue, variable vars.Var, value any, rc restoreCollector) Exception {
+	if lv.pattern != nil {
+		return destructure(fm, lv, value, rc)
+	}
+	return set(fm, lv, variable, value, rc)
+}
+
+// Destructures the value into the variables in the pattern lv.
+func destructure(fm *Frame, lv lvalue, value any, rc restoreCollector) Exception {
+	p := lv.pattern
+	if !p.isMap {
+		values, err := vals.Collect(value)
+		if err != nil {
+			return fm.errorp(lv, err)
+		}
+		variables, exc := derefLValues(fm, p.elems)
+		if exc != nil {
+			return exc
+		}
+		return assignValues(fm, lv, p.elems, variables, values, "list elements", rc)
+	}
+	for _, key := range p.keys {
+		var v any
+		if vals.HasKey(value, key.key) {
+			var err error
+			v, err = vals.Index(value, key.key)
+			if err != nil {
+				return fm.errorp(key.lvalue, err)
+			}
+		} else if key.defaultOp != nil {
+			var exc Exception
+			v, exc = evalForValue(fm, key.defaultOp, "default value")
+			if exc != nil {
+				return exc
+			}
+		} else {
+			return fm.errorp(key.lvalue, vals.NoSuchKey(key.key))
+		}
+		// Keys of map patterns are never patterns themselves.
+		variable, err := derefLValue(fm, key.lvalue)
+		if err != nil {
+			return fm.errorp(key.lvalue, err)
+		}
+		exc := set(fm, key.lvalue, variable, v, rc)
+		if exc != nil {
+			return exc
+		}
+	}
+	return nil
+}
+
 type restoreCollector func(func(*Frame) Exception)
 
 // Sets the variable to the value.
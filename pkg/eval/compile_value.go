@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"src.elv.sh/pkg/diag"
@@ -286,6 +287,8 @@ func (cp *compiler) primaryOp(n *parse.Primary) valuesOp {
 		return exceptionCaptureOp{n.Range(), cp.chunkOp(n.Chunk)}
 	case parse.OutputCapture:
 		return outputCaptureOp{n.Range(), cp.chunkOp(n.Chunk)}
+	case parse.ReadSubstitution, parse.WriteSubstitution:
+		return processSubstOp{n.Range(), n.Type == parse.ReadSubstitution, cp.chunkOp(n.Chunk)}
 	case parse.List:
 		return listOp{n.Range(), cp.compoundOps(n.Elements)}
 	case parse.Lambda:
@@ -381,12 +384,22 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 		restArg       int = -1
 		optNames      []string
 		optDefaultOps []valuesOp
+		// Indices of arguments that are destructuring patterns.
+		patternArgs []int
 	)
 	if len(n.Elements) > 0 {
 		// Argument list.
 		argNames = make([]string, len(n.Elements))
 		seenName := make(map[string]bool)
 		for i, arg := range n.Elements {
+			if len(arg.Indexings) == 1 && isPattern(arg.Indexings[0]) {
+				// The argument itself has an empty name, which can't be
+				// referred to; the pattern is compiled in the scope of the
+				// lambda below.
+				argNames[i] = ""
+				patternArgs = append(patternArgs, i)
+				continue
+			}
 			ref := stringLiteralOrError(cp, arg, "argument name")
 			sigil, qname := SplitSigil(ref)
 			name, rest := SplitQName(qname)
@@ -434,18 +447,36 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 	}
 
 	local, capture := cp.pushScope()
-	for _, argName := range argNames {
-		local.add(argName)
+	for i, argName := range argNames {
+		if slices.Contains(patternArgs, i) {
+			// Reserve the slot without making the empty name visible.
+			local.infos = append(local.infos, staticVarInfo{name: argName, deleted: true})
+		} else {
+			cp.declare(argName, paramDecl, n.Elements[i])
+		}
 	}
-	for _, optName := range optNames {
-		local.add(optName)
+	for i, optName := range optNames {
+		cp.declare(optName, optDecl, n.MapPairs[i].Key)
 	}
 	scopeSizeInit := len(local.infos)
+	// Variables in patterns are declared after the arguments and options, so
+	// that they become part of newLocal.
+	argPatterns := make([]argPattern, len(patternArgs))
+	for i, argIndex := range patternArgs {
+		argPatterns[i] = argPattern{argIndex,
+			cp.compilePattern(n.Elements[argIndex].Indexings[0].Head, newLValue)}
+	}
 	chunkOp := cp.chunkOp(n.Chunk)
 	newLocal := local.infos[scopeSizeInit:]
 	cp.popScope()
 
-	return &lambdaOp{n.Range(), argNames, restArg, optNames, optDefaultOps, newLocal, capture, chunkOp, cp.src}
+	return &lambdaOp{n.Range(), argNames, restArg, optNames, optDefaultOps, argPatterns, newLocal, capture, chunkOp, cp.src}
+}
+
+// An argument of a closure that is a destructuring pattern.
+type argPattern struct {
+	index  int
+	lvalue lvalue
 }
 
 type lambdaOp struct {
@@ -454,6 +485,7 @@ type lambdaOp struct {
 	restArg       int
 	optNames      []string
 	optDefaultOps []valuesOp
+	argPatterns   []argPattern
 	newLocal      []staticVarInfo
 	capture       *staticUpNs
 	subop         effectOp
@@ -461,10 +493,25 @@ type lambdaOp struct {
 }
 
 func (op *lambdaOp) exec(fm *Frame) ([]any, Exception) {
+	capture := captureUpNs(fm, op.capture)
+	optDefaults := make([]any, len(op.optDefaultOps))
+	for i, op := range op.optDefaultOps {
+		defaultValue, err := evalForValue(fm, op, "option default value")
+		if err != nil {
+			return nil, err
+		}
+		optDefaults[i] = defaultValue
+	}
+	return []any{&Closure{op.argNames, op.restArg, op.optNames, optDefaults, op.srcMeta, op.Range(), op.subop, op.argPatterns, op.newLocal, capture}}, nil
+}
+
+// Builds the upvalue namespace of a scope from the variables of fm, according
+// to the captures recorded when the scope was compiled.
+func captureUpNs(fm *Frame, up *staticUpNs) *Ns {
 	capture := &Ns{
-		make([]vars.Var, len(op.capture.infos)),
-		make([]staticVarInfo, len(op.capture.infos))}
-	for i, info := range op.capture.infos {
+		make([]vars.Var, len(up.infos)),
+		make([]staticVarInfo, len(up.infos))}
+	for i, info := range up.infos {
 		if info.local {
 			capture.slots[i] = fm.local.slots[info.index]
 			capture.infos[i] = fm.local.infos[info.index]
@@ -473,15 +520,7 @@ func (op *lambdaOp) exec(fm *Frame) ([]any, Exception) {
 			capture.infos[i] = fm.up.infos[info.index]
 		}
 	}
-	optDefaults := make([]any, len(op.optDefaultOps))
-	for i, op := range op.optDefaultOps {
-		defaultValue, err := evalForValue(fm, op, "option default value")
-		if err != nil {
-			return nil, err
-		}
-		optDefaults[i] = defaultValue
-	}
-	return []any{&Closure{op.argNames, op.restArg, op.optNames, optDefaults, op.srcMeta, op.Range(), op.subop, op.newLocal, capture}}, nil
+	return capture
 }
 
 type mapOp struct {
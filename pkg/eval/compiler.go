@@ -16,14 +16,18 @@ import (
 type compiler struct {
 	// Builtin namespace.
 	builtin *staticNs
+	// Values in the builtin namespace, used for checking calls to builtin
+	// functions.
+	builtinNs *Ns
 	// Lexical namespaces.
 	scopes []*staticNs
 	// Sources of captured variables.
 	captures []*staticUpNs
 	// Pragmas tied to scopes.
 	pragmas []*scopePragma
-	// Names of internal modules.
-	modules []string
+	// Pre-defined modules, keyed by their module spec. Used for suggesting
+	// autofixes and checking calls to functions in them.
+	modules map[string]*Ns
 	// Destination of warning messages. This is currently only used for
 	// deprecation messages.
 	warn io.Writer
@@ -35,23 +39,43 @@ type compiler struct {
 	errors []*CompilationError
 	// Suggested code to fix potential issues found during compilation.
 	autofixes []string
+	// State of the linter, or nil if the code is not being linted.
+	lint *linter
+	// Names of variables that are reassigned somewhere in the source. See
+	// reassignedNames.
+	reassigned map[string]bool
+	// Whether to report calls that don't match the signature of the function
+	// as compilation errors. This is only done when checking code without
+	// evaluating it; see checkCall.
+	checkCalls bool
 }
 
 type scopePragma struct {
 	unknownCommandIsExternal bool
 }
 
-func compile(b, g *staticNs, modules []string, tree parse.Tree, w io.Writer) (nsOp, []string, error) {
+func compile(b *Ns, g *staticNs, modules map[string]*Ns, tree parse.Tree, w io.Writer) (nsOp, []string, error) {
 	g = g.clone()
-	cp := &compiler{
-		b, []*staticNs{g}, []*staticUpNs{new(staticUpNs)},
-		[]*scopePragma{{unknownCommandIsExternal: true}},
-		modules,
-		w, newDeprecationRegistry(), tree.Source, nil, nil}
+	cp := newCompiler(b, g, modules, tree, w)
 	chunkOp := cp.chunkOp(tree.Root)
 	return nsOp{chunkOp, g}, cp.autofixes, diag.PackErrors(cp.errors)
 }
 
+func newCompiler(b *Ns, g *staticNs, modules map[string]*Ns, tree parse.Tree, w io.Writer) *compiler {
+	// Signatures recorded by fn are only trusted within the same source, since
+	// code evaluated in between may have assigned the variables.
+	for i := range g.infos {
+		g.infos[i].sig = nil
+	}
+	return &compiler{
+		builtin: b.static(), builtinNs: b,
+		scopes: []*staticNs{g}, captures: []*staticUpNs{new(staticUpNs)},
+		pragmas: []*scopePragma{{unknownCommandIsExternal: true}},
+		modules: modules,
+		warn:    w, deprecations: newDeprecationRegistry(), src: tree.Source,
+		reassigned: reassignedNames(tree.Root)}
+}
+
 type nsOp struct {
 	inner    effectOp
 	template *staticNs
@@ -134,6 +158,9 @@ func (cp *compiler) pushScope() (*staticNs, *staticUpNs) {
 }
 
 func (cp *compiler) popScope() {
+	if cp.lint != nil {
+		cp.lintUnused(cp.thisScope(), false)
+	}
 	cp.scopes[len(cp.scopes)-1] = nil
 	cp.scopes = cp.scopes[:len(cp.scopes)-1]
 	cp.captures[len(cp.captures)-1] = nil
@@ -163,11 +190,15 @@ type deprecationTag struct{}
 func (deprecationTag) ErrorTag() string { return "deprecation" }
 
 func (cp *compiler) deprecate(r diag.Ranger, msg string, minLevel int) {
-	if cp.warn == nil || r == nil {
+	if (cp.warn == nil && cp.lint == nil) || r == nil {
 		return
 	}
 	dep := deprecation{cp.src.Name, r.Range(), msg}
 	if prog.DeprecationLevel >= minLevel && cp.deprecations.register(dep) {
+		if cp.lint != nil {
+			cp.lintWarnf(r, "%s", msg)
+			return
+		}
 		err := diag.Error[deprecationTag]{
 			Message: msg,
 			Context: *diag.NewContext(cp.src.Name, cp.src.Code, r.Range())}
@@ -182,7 +213,7 @@ func (cp *compiler) autofixUnresolvedVar(qname string) {
 	}
 	first, _ := SplitQName(qname)
 	mod := strings.TrimSuffix(first, ":")
-	if mod != first && sliceContains(cp.modules, mod) {
+	if _, ok := cp.modules[mod]; mod != first && ok {
 		cp.autofixes = append(cp.autofixes, "use "+mod)
 	}
 }
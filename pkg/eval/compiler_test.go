@@ -7,7 +7,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"src.elv.sh/pkg/diag"
 	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/mods/str"
 	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/tt"
 )
 
 var autofixTests = []struct {
@@ -67,6 +69,68 @@ func TestAutofix(t *testing.T) {
 	}
 }
 
+// Checks the code and returns each compilation error as its message followed by
+// the source text it covers.
+func checkErrors(ev *Evaler, code string) []string {
+	_, _, err := ev.Check(parse.Source{Name: "[test]", Code: code}, nil)
+	var strs []string
+	for _, e := range UnpackCompilationErrors(err) {
+		strs = append(strs, e.Message+": "+code[e.Context.From:e.Context.To])
+	}
+	return strs
+}
+
+func TestCheck_Calls(t *testing.T) {
+	ev := NewEvaler()
+	ev.AddModule("str", str.Ns)
+	check := func(code string) []string { return checkErrors(ev, code) }
+
+	tt.Test(t, tt.Fn(check).Named("check"),
+		// Builtin functions.
+		Args("not-eq 1 2 3").Rets([]string{
+			"arity mismatch: arguments must be 2 values, but is 3 values: not-eq 1 2 3"}),
+		Args("peach &num-worker=4 {|x| }").Rets([]string{
+			"unknown option: num-worker: &num-worker=4"}),
+		// Functions in pre-defined modules.
+		Args("use str; str:join , a b").Rets([]string{
+			"arity mismatch: arguments must be 1 to 2 values, but is 3 values: str:join , a b"}),
+		// Functions defined with fn, including in inner scopes.
+		Args("fn f {|a &k=v| }; f; f a &x=y").Rets([]string{
+			"arity mismatch: arguments must be 1 value, but is 0 values: f",
+			"unknown option: x: &x=y"}),
+		Args("fn g {|a @rest| }; g").Rets([]string{
+			"arity mismatch: arguments must be 1 or more values, but is 0 values: g"}),
+		Args("fn h { }; fn k { h a }").Rets([]string{
+			"arity mismatch: arguments must be 0 values, but is 1 value: h a "}),
+		// Arguments are not checked when their number is not known statically.
+		Args("var l = [1 2 3]; not-eq $@l").Rets([]string(nil)),
+		// Functions assigned anywhere in the same source are not checked,
+		// including when the assignment comes after the call.
+		Args("fn f { }; set f~ = {|a| }; f foo").Rets([]string(nil)),
+		Args("fn f { }; fn caller { f x }; set f~ = {|a| }").Rets([]string(nil)),
+		Args("fn f { }; fn caller { f x }; { tmp f~ = {|a| }; caller }").Rets([]string(nil)),
+	)
+}
+
+func TestCheck_Calls_FnFromEarlierCode(t *testing.T) {
+	ev := NewEvaler()
+	// The function may be reassigned by code that is not visible to the
+	// compiler, like code evaluated with eval, so its signature from earlier
+	// code is not trusted.
+	for _, code := range []string{
+		"fn f {|a| put $a }",
+		"eval 'set f~ = {|a b| put $a $b }'",
+	} {
+		err := ev.Eval(parse.Source{Name: "[test]", Code: code}, EvalCfg{})
+		if err != nil {
+			t.Fatalf("eval %q: %v", code, err)
+		}
+	}
+	if errs := checkErrors(ev, "f 1 2"); len(errs) > 0 {
+		t.Errorf("got errors %v, want none", errs)
+	}
+}
+
 // TODO: Turn this into a fuzz test.
 func TestPartialCompilationError(t *testing.T) {
 	for _, code := range transcriptCodes {
@@ -0,0 +1,211 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+)
+
+// Coverage records which forms of Elvish code have been run, and reports the
+// line coverage of the code.
+//
+// Only code from files and bundled modules is recorded; interactive code and
+// code passed to eval are not. A line is considered coverable if a form starts
+// on it, and its count is the largest number of times a form starting on it
+// has been run.
+//
+// The same Coverage may be used by multiple Evalers concurrently, which is
+// useful for collecting the coverage of multiple tests.
+type Coverage struct {
+	mu    sync.Mutex
+	files map[string]*coverageFile
+}
+
+type coverageFile struct {
+	src    parse.Source
+	counts map[diag.Ranging]int64
+}
+
+// NewCoverage creates a new Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{files: make(map[string]*coverageFile)}
+}
+
+func (c *Coverage) record(src parse.Source, r diag.Ranging) {
+	if !src.IsFile && !strings.HasPrefix(src.Name, "[bundled ") {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file := c.files[src.Name]
+	if file == nil || file.src.Code != src.Code {
+		// If the file has changed, only the last version is kept.
+		file = &coverageFile{src, make(map[diag.Ranging]int64)}
+		c.files[src.Name] = file
+	}
+	file.counts[r]++
+}
+
+// Records fm as running the form at the given range, if the Evaler has
+// coverage enabled.
+func (fm *Frame) recordCoverage(r diag.Ranging) {
+	if c := fm.Evaler.Coverage; c != nil {
+		c.record(fm.src, r)
+	}
+}
+
+// CoverageFormats lists the supported formats of [Coverage.Write].
+var CoverageFormats = []string{"lcov", "summary", "annotated"}
+
+// Write writes the coverage in the given format, which must be one of
+// CoverageFormats:
+//
+//   - "lcov": The tracefile format of lcov, supported by many tools that
+//     display coverage.
+//
+//   - "summary": The number of covered and coverable lines of each file.
+//
+//   - "annotated": The source of each file, with each line prefixed by its
+//     count, "#####" if it is not covered, or "-" if it is not coverable.
+func (c *Coverage) Write(w io.Writer, format string) error {
+	var write func(*strings.Builder, parse.Source, []int64)
+	switch format {
+	case "lcov":
+		write = writeLcov
+	case "summary":
+		write = writeCoverageSummary
+	case "annotated":
+		write = writeAnnotated
+	default:
+		return fmt.Errorf("unknown coverage format %q, must be one of %s",
+			format, strings.Join(CoverageFormats, ", "))
+	}
+	var sb strings.Builder
+	var covered, coverable int
+	for _, file := range c.sortedFiles() {
+		lines := file.lineCounts()
+		fileCovered, fileCoverable := lineStats(lines)
+		covered += fileCovered
+		coverable += fileCoverable
+		write(&sb, file.src, lines)
+	}
+	if format == "summary" {
+		fmt.Fprintf(&sb, "total: %s\n", coverageRatio(covered, coverable))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (c *Coverage) sortedFiles() []*coverageFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := make([]*coverageFile, 0, len(c.files))
+	for _, file := range c.files {
+		// Copy the counts, so that the file can be used without holding mu.
+		counts := make(map[diag.Ranging]int64, len(file.counts))
+		for r, n := range file.counts {
+			counts[r] = n
+		}
+		files = append(files, &coverageFile{file.src, counts})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].src.Name < files[j].src.Name })
+	return files
+}
+
+// Returns the count of each line, indexed by 0-based line numbers. Lines that
+// are not coverable have a count of -1.
+func (file *coverageFile) lineCounts() []int64 {
+	code := file.src.Code
+	starts := []int{0}
+	for i := 0; i < len(code); i++ {
+		if code[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	lineOf := func(pos int) int { return sort.SearchInts(starts, pos+1) - 1 }
+	counts := make([]int64, len(starts))
+	for i := range counts {
+		counts[i] = -1
+	}
+	// Parse errors are ignored, since code with parse errors can't be run.
+	tree, _ := parse.Parse(file.src, parse.Config{})
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		if _, ok := n.(*parse.Form); ok {
+			counts[lineOf(n.Range().From)] = 0
+		}
+		for _, ch := range parse.Children(n) {
+			walk(ch)
+		}
+	}
+	walk(tree.Root)
+	for r, n := range file.counts {
+		if line := lineOf(r.From); n > counts[line] {
+			counts[line] = n
+		}
+	}
+	// A trailing newline doesn't start a new line.
+	if strings.HasSuffix(code, "\n") {
+		counts = counts[:len(counts)-1]
+	}
+	return counts
+}
+
+func writeLcov(sb *strings.Builder, src parse.Source, lines []int64) {
+	fmt.Fprintf(sb, "TN:\nSF:%s\n", src.Name)
+	for i, n := range lines {
+		if n >= 0 {
+			fmt.Fprintf(sb, "DA:%d,%d\n", i+1, n)
+		}
+	}
+	covered, coverable := lineStats(lines)
+	fmt.Fprintf(sb, "LF:%d\nLH:%d\nend_of_record\n", coverable, covered)
+}
+
+func writeCoverageSummary(sb *strings.Builder, src parse.Source, lines []int64) {
+	fmt.Fprintf(sb, "%s: %s\n", src.Name, coverageRatio(lineStats(lines)))
+}
+
+func lineStats(lines []int64) (covered, coverable int) {
+	for _, n := range lines {
+		if n >= 0 {
+			coverable++
+		}
+		if n > 0 {
+			covered++
+		}
+	}
+	return covered, coverable
+}
+
+func coverageRatio(covered, coverable int) string {
+	if coverable == 0 {
+		return "no coverable lines"
+	}
+	return fmt.Sprintf("%d/%d lines (%.1f%%)",
+		covered, coverable, 100*float64(covered)/float64(coverable))
+}
+
+func writeAnnotated(sb *strings.Builder, src parse.Source, lines []int64) {
+	fmt.Fprintf(sb, "=== %s ===\n", src.Name)
+	for i, line := range strings.SplitAfter(src.Code, "\n") {
+		if i >= len(lines) {
+			break
+		}
+		var count string
+		switch n := lines[i]; {
+		case n < 0:
+			count = "-"
+		case n == 0:
+			count = "#####"
+		default:
+			count = fmt.Sprint(n)
+		}
+		fmt.Fprintf(sb, "%8s %5d  %s\n", count, i+1, strings.TrimSuffix(line, "\n"))
+	}
+}
@@ -0,0 +1,90 @@
+package eval_test
+
+import (
+	"strings"
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+
+	"src.elv.sh/pkg/parse"
+)
+
+var coveredCode = `fn f {|x|
+  if $x {
+    put yes
+  } else {
+    put no
+  }
+}
+f $true; f $true
+match foo {
+  bar {
+    put bar
+  }
+  _ { }
+}
+`
+
+var coverageTests = []struct {
+	format string
+	want   string
+}{
+	{"lcov", `TN:
+SF:a.elv
+DA:1,1
+DA:2,2
+DA:3,2
+DA:5,0
+DA:8,1
+DA:9,1
+DA:10,1
+DA:11,0
+DA:13,1
+LF:9
+LH:7
+end_of_record
+`},
+	{"summary", "a.elv: 7/9 lines (77.8%)\ntotal: 7/9 lines (77.8%)\n"},
+	{"annotated", `=== a.elv ===
+       1     1  fn f {|x|
+       2     2    if $x {
+       2     3      put yes
+       -     4    } else {
+   #####     5      put no
+       -     6    }
+       -     7  }
+       1     8  f $true; f $true
+       1     9  match foo {
+       1    10    bar {
+   #####    11      put bar
+       -    12    }
+       1    13    _ { }
+       -    14  }
+`},
+}
+
+func TestCoverage(t *testing.T) {
+	ev := NewEvaler()
+	ev.Coverage = NewCoverage()
+	err := ev.Eval(parse.Source{Name: "a.elv", Code: coveredCode, IsFile: true},
+		EvalCfg{Ports: DummyPorts})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// Interactive code is not recorded.
+	ev.Eval(parse.Source{Name: "[tty]", Code: "nop"}, EvalCfg{})
+
+	for _, test := range coverageTests {
+		var sb strings.Builder
+		if err := ev.Coverage.Write(&sb, test.format); err != nil {
+			t.Errorf("Write %s: %v", test.format, err)
+		}
+		if got := sb.String(); got != test.want {
+			t.Errorf("Write %s writes:\n%s\nwant:\n%s", test.format, got, test.want)
+		}
+	}
+
+	if err := ev.Coverage.Write(&strings.Builder{}, "bad"); err == nil {
+		t.Errorf("Write with bad format returns nil error")
+	}
+}
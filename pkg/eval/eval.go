@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"sync"
 
+	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
@@ -59,6 +60,20 @@ type Evaler struct {
 	// are not used by the Evaler itself right now; they are here so that they
 	// can be exposed to the runtime: module.
 	RcPath, EffectiveRcPath string
+	// If not nil, called before each form is executed, with the Frame the form
+	// is executed in and the range of the form within [Frame.Source]. It is
+	// called on the goroutine executing the form, so it can pause the execution
+	// by blocking. Used to implement debuggers.
+	BeforeForm func(fm *Frame, r diag.Ranging)
+	// If not nil, called by the breakpoint builtin with the Frame it is called
+	// in, whose variables are available via [Frame.Local] and [Frame.Up]. An
+	// error returned by it is thrown by breakpoint. It is not called outside
+	// the foreground job, but may be called concurrently, for example from
+	// multiple forms of a pipeline. Used by the interactive shell to run a
+	// nested REPL.
+	OnBreakpoint func(fm *Frame) error
+	// If not nil, the forms run by the Evaler are recorded in it.
+	Coverage *Coverage
 
 	mu sync.RWMutex
 	// Mutations to fields below must be guarded by mutex.
@@ -86,6 +101,8 @@ type Evaler struct {
 	notifyBgJobSuccess bool
 	// The current number of background jobs, exposed as $num-bg-jobs.
 	numBgJobs int
+	// The job table, sorted by job IDs.
+	jobs []*job
 }
 
 // NewEvaler creates a new Evaler.
@@ -280,8 +297,16 @@ type EvalCfg struct {
 	// Whether the Eval method should try to put the Elvish in the foreground
 	// after the code is executed.
 	PutInFg bool
+	// Whether to enable job control. When enabled, every top-level foreground
+	// pipeline becomes a job; on Unix, its external commands run in their own
+	// process group that is given the terminal, so that it can be suspended
+	// and later resumed with fg or bg. This should only be set when the
+	// standard input is the controlling terminal of Elvish.
+	JobControl bool
 	// If not nil, used the given global namespace, instead of Evaler's own.
 	Global *Ns
+	// If not nil, the code is profiled with the given profiler.
+	Profiler *Profiler
 }
 
 func (cfg *EvalCfg) fillDefaults() {
@@ -311,7 +336,7 @@ func (ev *Evaler) Eval(src parse.Source, cfg EvalCfg) error {
 	}
 
 	ev.mu.Lock()
-	b := ev.builtin
+	b, m := ev.builtin, ev.modules
 	defaultGlobal := cfg.Global == nil
 	if defaultGlobal {
 		// If cfg.Global is nil, use the Evaler's default global, and also
@@ -323,7 +348,7 @@ func (ev *Evaler) Eval(src parse.Source, cfg EvalCfg) error {
 		ev.mu.Unlock()
 	}
 
-	op, _, err := compile(b.static(), cfg.Global.static(), nil, tree, errFile)
+	op, _, err := compile(b, cfg.Global.static(), m, tree, errFile)
 	if err != nil {
 		if defaultGlobal {
 			ev.mu.Unlock()
@@ -340,7 +365,11 @@ func (ev *Evaler) Eval(src parse.Source, cfg EvalCfg) error {
 		ev.mu.Unlock()
 	}
 
-	return exec()
+	exc := exec()
+	if excFutures := fm.futures.exit(exc != nil); excFutures != nil && exc == nil {
+		exc = excFutures
+	}
+	return exc
 }
 
 // CallCfg keeps configuration for the (*Evaler).Call method.
@@ -371,7 +400,11 @@ func (ev *Evaler) Call(f Callable, callCfg CallCfg, evalCfg EvalCfg) error {
 	}
 	fm, cleanup := ev.prepareFrame(parse.Source{Name: callCfg.From}, evalCfg)
 	defer cleanup()
-	return f.Call(fm, callCfg.Args, callCfg.Opts)
+	err := f.Call(fm, callCfg.Args, callCfg.Opts)
+	if excFutures := fm.futures.exit(err != nil); excFutures != nil && err == nil {
+		err = excFutures
+	}
+	return err
 }
 
 func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
@@ -382,7 +415,8 @@ func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
 
 	ports := fillDefaultDummyPorts(cfg.Ports)
 
-	fm := &Frame{ev, intCtx, ports, nil, false, src, cfg.Global, new(Ns), nil}
+	fm := &Frame{ev, intCtx, ports, nil, false, false, nil, cfg.JobControl,
+		cfg.Profiler.root(), &futureScope{}, nil, src, cfg.Global, new(Ns), nil}
 	return fm, func() {
 		if cfg.PutInFg {
 			err := putSelfInFg()
@@ -423,6 +457,8 @@ func (ev *Evaler) CheckTree(tree parse.Tree, w io.Writer) ([]string, error) {
 	ev.mu.RLock()
 	b, g, m := ev.builtin, ev.global, ev.modules
 	ev.mu.RUnlock()
-	_, autofixes, compileErr := compile(b.static(), g.static(), mapKeys(m), tree, w)
-	return autofixes, compileErr
+	cp := newCompiler(b, g.static().clone(), m, tree, w)
+	cp.checkCalls = true
+	cp.chunkOp(tree.Root)
+	return cp.autofixes, diag.PackErrors(cp.errors)
 }
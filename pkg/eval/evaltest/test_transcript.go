@@ -105,6 +105,21 @@
 // This mechanism enables editor plugins that can fill or update the output of
 // transcript tests without requiring user to leave the editor.
 //
+// # ELVISH_TRANSCRIPT_COVERAGE
+//
+// The environment variable ELVISH_TRANSCRIPT_COVERAGE may be set to a path. If
+// it is set, the line coverage of the Elvish code run by the transcripts is
+// recorded with [eval.Coverage], and written to the path after the tests are
+// run, in the format given by ELVISH_TRANSCRIPT_COVERAGE_FORMAT, "lcov" by
+// default. Only code from files and bundled modules is covered, so this is
+// useful for checking which parts of a module the transcripts exercise:
+//
+//	env ELVISH_TRANSCRIPT_COVERAGE=epm.lcov go test ./pkg/mods/epm
+//
+// Since tests are run in the directory of the package, a relative path is
+// resolved relative to the package directory. The coverage of all calls to
+// [TestTranscriptNodes] in the same test binary is accumulated.
+//
 // # Deterministic output order
 //
 // When Elvish code writes to both the value output and byte output, or to both
@@ -125,8 +140,10 @@ import (
 	"os"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"src.elv.sh/pkg/diag"
@@ -176,7 +193,50 @@ func TestTranscriptNodes(t *testing.T, nodes []*transcript.Node, setupPairs ...a
 		}
 		run = &runCfg{lineNo, outputPrefix}
 	}
-	testTranscripts(t, buildSetupDirectives(setupPairs), nodes, nil, run)
+	coverage := transcriptCoverage(t)
+	testTranscripts(t, buildSetupDirectives(setupPairs), nodes, coverage, nil, run)
+	if coverage != nil {
+		writeTranscriptCoverage(t, coverage)
+	}
+}
+
+// Coverage accumulated across calls to TestTranscriptNodes.
+var (
+	accumulatedCoverageMutex sync.Mutex
+	accumulatedCoverage      *eval.Coverage
+)
+
+func transcriptCoverage(t *testing.T) *eval.Coverage {
+	if os.Getenv("ELVISH_TRANSCRIPT_COVERAGE") == "" {
+		return nil
+	}
+	if format := coverageFormat(); !slices.Contains(eval.CoverageFormats, format) {
+		t.Fatalf("unknown ELVISH_TRANSCRIPT_COVERAGE_FORMAT %q", format)
+	}
+	accumulatedCoverageMutex.Lock()
+	defer accumulatedCoverageMutex.Unlock()
+	if accumulatedCoverage == nil {
+		accumulatedCoverage = eval.NewCoverage()
+	}
+	return accumulatedCoverage
+}
+
+func coverageFormat() string {
+	if format := os.Getenv("ELVISH_TRANSCRIPT_COVERAGE_FORMAT"); format != "" {
+		return format
+	}
+	return "lcov"
+}
+
+func writeTranscriptCoverage(t *testing.T, c *eval.Coverage) {
+	file, err := os.Create(os.Getenv("ELVISH_TRANSCRIPT_COVERAGE"))
+	if err != nil {
+		t.Fatalf("write coverage: %v", err)
+	}
+	defer file.Close()
+	if err := c.Write(file, coverageFormat()); err != nil {
+		t.Fatalf("write coverage: %v", err)
+	}
 }
 
 type runCfg struct {
@@ -199,13 +259,14 @@ func parseFileNameAndLineNo(s string) (string, int, bool) {
 
 var solPattern = regexp.MustCompile("(?m:^)")
 
-func testTranscripts(t *testing.T, sd *setupDirectives, nodes []*transcript.Node, setups []setupFunc, run *runCfg) {
+func testTranscripts(t *testing.T, sd *setupDirectives, nodes []*transcript.Node, coverage *eval.Coverage, setups []setupFunc, run *runCfg) {
 	for _, node := range nodes {
 		if run != nil && !(node.LineFrom <= run.line && run.line < node.LineTo) {
 			continue
 		}
 		t.Run(node.Name, func(t *testing.T) {
 			ev := eval.NewEvaler()
+			ev.Coverage = coverage
 			mods.AddTo(ev)
 			for _, setup := range setups {
 				setup(t, ev)
@@ -252,7 +313,7 @@ func testTranscripts(t *testing.T, sd *setupDirectives, nodes []*transcript.Node
 				allSetups := make([]setupFunc, 0, len(setups)+len(eachSetups))
 				allSetups = append(allSetups, setups...)
 				allSetups = append(allSetups, eachSetups...)
-				testTranscripts(t, sd, node.Children, allSetups, run)
+				testTranscripts(t, sd, node.Children, coverage, allSetups, run)
 			}
 		})
 	}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"context"
 	"errors"
 	"os"
 	"os/exec"
@@ -78,6 +79,13 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 			files[i] = port.File
 		}
 	}
+	// Process substitutions in the arguments are /dev/fd paths with the file
+	// descriptors in this process, so pass them with the same numbers.
+	for _, sub := range fm.procSubs {
+		if file := growAccess(&files, sub.fd); *file == nil {
+			*file = sub.file
+		}
+	}
 
 	args := make([]string, len(argVals)+1)
 	for i, a := range argVals {
@@ -105,13 +113,40 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 
 	args[0] = path
 
-	sys := makeSysProcAttr(fm.background)
-	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	var proc *os.Process
+	if fm.job != nil {
+		proc, err = fm.job.startProcess(path, args, files)
+	} else {
+		sys := makeSysProcAttr(fm.background)
+		proc, err = os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	}
 	if err != nil {
 		return err
 	}
-
-	state, err := proc.Wait()
+	exited := make(chan struct{})
+	defer close(exited)
+	stopTerminating := context.AfterFunc(fm.ctx, func() {
+		if !shouldTerminate(fm.ctx) {
+			return
+		}
+		if fm.job != nil {
+			fm.job.terminateProcess(proc, exited)
+		} else {
+			terminateProcess(proc, exited)
+		}
+	})
+	defer stopTerminating()
+
+	var ws syscall.WaitStatus
+	if fm.job != nil {
+		ws, err = fm.job.waitProcess(proc)
+	} else {
+		var state *os.ProcessState
+		state, err = proc.Wait()
+		if err == nil {
+			ws = state.Sys().(syscall.WaitStatus)
+		}
+	}
 	if err != nil {
 		// This should be a can't happen situation. Nonetheless, treat it as a
 		// soft error rather than panicking since the Go documentation is not
@@ -119,12 +154,11 @@ func (e externalCmd) Call(fm *Frame, argVals []any, opts map[string]any) error {
 		// calling `Wait` twice on a particular process object.
 		return err
 	}
-	ws := state.Sys().(syscall.WaitStatus)
 	if ws.Signaled() && isSIGPIPE(ws.Signal()) {
 		readerGone := fm.ports[1].readerGone
 		if readerGone != nil && readerGone.Load() {
 			return errs.ReaderGone{}
 		}
 	}
-	return NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), proc.Pid)
+	return NewExternalCmdExit(e.Name, ws, proc.Pid)
 }
@@ -0,0 +1,296 @@
+package eval
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// Static checking of function calls.
+//
+// When the head of a command form resolves to a function whose signature is
+// known at compile time, the compiler checks the number of arguments and the
+// names of options against the signature. The signature is known in the
+// following cases:
+//
+//   - The function is a builtin, or a read-only variable in a module imported
+//     from a pre-defined module (like str:join). Such functions can't be
+//     changed, so their signature can be taken from their value.
+//
+//   - The function is defined with the fn special command in a visible scope
+//     of the same source, and the variable is not assigned anywhere else in
+//     it. In this case the signature is derived from the lambda literal.
+//
+// The number of arguments is only checked when each argument evaluates to
+// exactly one value, which can be determined from the syntax alone. Options
+// are only checked when their names are string literals.
+//
+// Since a call that doesn't match may never be executed, mismatches don't stop
+// the code from being evaluated; such calls still throw an exception when they
+// are executed. Mismatches are reported as compilation errors when checking
+// code without evaluating it (as done by elvish -compileonly and the editor's
+// highlighter), and as warnings by the linter.
+
+// Signature of a function known at compile time.
+type fnSig struct {
+	minArgs int
+	// Maximum number of arguments, or -1 if there is no upper limit.
+	maxArgs int
+	// Names of supported options.
+	opts []string
+	// If true, any option is accepted.
+	anyOpts bool
+}
+
+func (s *fnSig) acceptsOpt(name string) bool {
+	return s.anyOpts || slices.Contains(s.opts, name)
+}
+
+// Returns the signature of a callable value, or nil if it can't be determined.
+func callableSig(v any) *fnSig {
+	switch v := v.(type) {
+	case *goFn:
+		return v.sig()
+	case *Closure:
+		return closureSig(v.ArgNames, v.RestArg, v.OptNames)
+	}
+	return nil
+}
+
+func (b *goFn) sig() *fnSig {
+	s := &fnSig{minArgs: len(b.normalArgs), maxArgs: len(b.normalArgs)}
+	if b.variadicArg != nil {
+		s.maxArgs = -1
+	} else if b.inputs {
+		s.maxArgs++
+	}
+	if b.rawOptions {
+		s.anyOpts = true
+	} else if b.options != nil {
+		s.opts = vals.GetFieldMapKeys(reflect.Zero(b.options).Interface())
+	}
+	return s
+}
+
+func closureSig(argNames []string, restArg int, optNames []string) *fnSig {
+	s := &fnSig{minArgs: len(argNames), maxArgs: len(argNames), opts: optNames}
+	if restArg != -1 {
+		s.minArgs--
+		s.maxArgs = -1
+	}
+	return s
+}
+
+// Derives the signature of a lambda literal, or returns nil if the signature
+// has errors; such errors are reported when the lambda itself is compiled.
+func lambdaSig(n *parse.Primary) *fnSig {
+	argNames := make([]string, len(n.Elements))
+	restArg := -1
+	for i, arg := range n.Elements {
+		name, ok := cmpd.StringLiteral(arg)
+		if !ok {
+			return nil
+		}
+		if strings.HasPrefix(name, "@") {
+			if restArg != -1 {
+				return nil
+			}
+			restArg = i
+		}
+		argNames[i] = name
+	}
+	optNames := make([]string, len(n.MapPairs))
+	for i, opt := range n.MapPairs {
+		name, ok := cmpd.StringLiteral(opt.Key)
+		if !ok {
+			return nil
+		}
+		optNames[i] = name
+	}
+	return closureSig(argNames, restArg, optNames)
+}
+
+// Returns the signature of the function a command head resolves to, or nil if
+// it is not known.
+func (cp *compiler) resolvedSig(ref *varRef) *fnSig {
+	switch ref.scope {
+	case localScope, captureScope:
+	case builtinScope:
+		if ref.info.readOnly && len(ref.subNames) == 0 {
+			return callableSig(cp.builtinNs.slots[ref.index].Get())
+		}
+		return nil
+	default:
+		return nil
+	}
+	if len(ref.subNames) == 0 {
+		if cp.reassigned[ref.info.name] {
+			return nil
+		}
+		return ref.info.sig
+	}
+	// Look up the function in a namespace known at compile time. Only
+	// read-only variables are considered, since other variables may be
+	// assigned before the call happens.
+	ns := ref.info.mod
+	for i, subName := range ref.subNames {
+		if ns == nil {
+			return nil
+		}
+		info, index := ns.lookup(subName)
+		if index == -1 || !info.readOnly {
+			return nil
+		}
+		v := ns.slots[index].Get()
+		if i == len(ref.subNames)-1 {
+			return callableSig(v)
+		}
+		ns, _ = v.(*Ns)
+	}
+	return nil
+}
+
+// Checks the arguments and options of a form against the signature of the
+// function its head resolves to.
+func (cp *compiler) checkCall(n *parse.Form, ref *varRef) {
+	if !cp.checkCalls && cp.lint == nil {
+		return
+	}
+	sig := cp.resolvedSig(ref)
+	if sig == nil {
+		return
+	}
+	if allSingleValues(n.Args) {
+		nargs := len(n.Args)
+		if nargs < sig.minArgs || (sig.maxArgs != -1 && nargs > sig.maxArgs) {
+			err := errs.ArityMismatch{What: "arguments",
+				ValidLow: sig.minArgs, ValidHigh: sig.maxArgs, Actual: nargs}
+			// Missing arguments may still be typed, so the error is partial.
+			cp.callMismatch(n, err.Error(), nargs < sig.minArgs)
+		}
+	}
+	for _, opt := range n.Opts {
+		if name, ok := cmpd.StringLiteral(opt.Key); ok && !sig.acceptsOpt(name) {
+			cp.callMismatch(opt, UnknownOption{name}.Error(), false)
+		}
+	}
+}
+
+// Reports a call that doesn't match the signature of the function, as a lint
+// warning when linting and as a compilation error otherwise.
+func (cp *compiler) callMismatch(r diag.Ranger, msg string, partial bool) {
+	switch {
+	case cp.lint != nil:
+		cp.lintWarnf(r, "%s", msg)
+	case partial:
+		cp.errorpfPartial(r, "%s", msg)
+	default:
+		cp.errorpf(r, "%s", msg)
+	}
+}
+
+// Reports whether each of the compound expressions evaluates to exactly one
+// value, so that the number of arguments is known statically.
+func allSingleValues(ns []*parse.Compound) bool {
+	for _, n := range ns {
+		if !isSingleValue(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSingleValue(n *parse.Compound) bool {
+	if len(n.Indexings) == 0 {
+		return false
+	}
+	for _, in := range n.Indexings {
+		switch in.Head.Type {
+		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted,
+			parse.Tilde, parse.ExceptionCapture, parse.List, parse.Lambda,
+			parse.Map, parse.ReadSubstitution, parse.WriteSubstitution:
+		case parse.Variable:
+			if strings.HasPrefix(in.Head.Value, "@") {
+				return false
+			}
+		default:
+			return false
+		}
+		for _, index := range in.Indices {
+			if len(index.Compounds) != 1 || !isSingleValue(index.Compounds[0]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Forgets the compile-time knowledge about the value of a variable in the
+// innermost scope that has it, after it gets assigned.
+func (cp *compiler) forgetStaticValue(name string) {
+	for i := len(cp.scopes) - 1; i >= 0; i-- {
+		if _, index := cp.scopes[i].lookup(name); index != -1 {
+			cp.scopes[i].infos[index].sig = nil
+			cp.scopes[i].infos[index].mod = nil
+			return
+		}
+	}
+}
+
+// Returns the names of variables that may hold a different value than the one
+// known when they are defined, because they are assigned with set, tmp or var
+// anywhere in the tree. Since such an
+// assignment may happen either before or after a call, even if it appears
+// later in the source, calls to these variables are not checked.
+//
+// This is conservative: the same name in unrelated scopes is also considered
+// reassigned.
+func reassignedNames(root parse.Node) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		if form, ok := n.(*parse.Form); ok {
+			head, _ := cmpd.StringLiteral(form.Head)
+			switch head {
+			case "set", "tmp", "var":
+			args:
+				for _, arg := range form.Args {
+					if s, ok := cmpd.StringLiteral(arg); ok && s == "=" {
+						break args
+					}
+					addLValueNames(arg, names)
+				}
+			}
+		}
+		for _, child := range parse.Children(n) {
+			walk(child)
+		}
+	}
+	walk(root)
+	return names
+}
+
+// Adds the names of all the variables in an lvalue, including those in
+// destructuring patterns, to names.
+func addLValueNames(n parse.Node, names map[string]bool) {
+	if p, ok := n.(*parse.Primary); ok {
+		switch p.Type {
+		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
+			_, qname := SplitSigil(p.Value)
+			first, rest := SplitQName(qname)
+			if first == "local:" || first == "up:" {
+				first, _ = SplitQName(rest)
+			}
+			names[first] = true
+		}
+	}
+	for _, child := range parse.Children(n) {
+		addLValueNames(child, names)
+	}
+}
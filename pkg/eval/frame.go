@@ -35,6 +35,22 @@ type Frame struct {
 	ports      []*Port
 	traceback  *StackTrace
 	background bool
+	// Whether the code runs outside the foreground job, in a background
+	// pipeline, peach or spawn.
+	detached bool
+	// The job that processes started from this frame belong to, and whether
+	// top-level foreground pipelines should create new jobs.
+	job        *job
+	jobControl bool
+	// If not nil, the code run in this frame is profiled, as a callee of
+	// this node.
+	prof *profNode
+	// Futures spawned in this frame are joined or canceled when this scope
+	// exits.
+	futures *futureScope
+	// Process substitutions started by the forms this frame runs in. The
+	// files they pass to the forms are passed on to external commands.
+	procSubs []*procSub
 
 	// The following fields are only relevant when running Elvish code (as
 	// opposed to a builtin function or external command).
@@ -66,13 +82,20 @@ func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func
 		traceback = fm.addTraceback(r)
 	}
 	newFm := &Frame{
-		fm.Evaler, fm.ctx, fm.ports, traceback, fm.background, src, local, new(Ns), nil}
-	op, _, err := compile(fm.Evaler.Builtin().static(), local.static(), nil, tree, fm.ErrorFile())
+		fm.Evaler, fm.ctx, fm.ports, traceback, fm.background, fm.detached, fm.job, fm.jobControl,
+		fm.prof, &futureScope{}, fm.procSubs, src, local, new(Ns), nil}
+	op, _, err := compile(fm.Evaler.Builtin(), local.static(), fm.Evaler.modules, tree, fm.ErrorFile())
 	if err != nil {
 		return nil, nil, err
 	}
 	newLocal, exec := op.prepare(newFm)
-	return newLocal, exec, nil
+	return newLocal, func() Exception {
+		exc := exec()
+		if excFutures := newFm.futures.exit(exc != nil); excFutures != nil && exc == nil {
+			exc = excFutures
+		}
+		return exc
+	}, nil
 }
 
 // Eval evaluates a piece of code in a copy of the current Frame. It returns the
@@ -124,38 +147,70 @@ func (fm *Frame) Port(i int) *Port {
 	return fm.ports[i]
 }
 
-// IterateInputs calls the passed function for each input element.
+// IterateInputs calls the passed function for each input element. It stops
+// early if the Context of the Frame is canceled.
 func (fm *Frame) IterateInputs(f func(any)) {
+	fm.IterateStoppableInputs(func(v any) bool {
+		f(v)
+		return true
+	})
+}
+
+// IterateStoppableInputs is like IterateInputs, but also stops when the passed
+// function returns false.
+func (fm *Frame) IterateStoppableInputs(f func(any) bool) {
 	var wg sync.WaitGroup
 	inputs := make(chan any)
+	// Closed when the iteration stops, so that the goroutines feeding inputs
+	// don't block forever if it stops early.
+	stop := make(chan struct{})
+	defer close(stop)
 
 	wg.Add(2)
 	go func() {
-		linesToChan(fm.InputFile(), inputs)
+		linesToChan(fm.InputFile(), inputs, stop)
 		wg.Done()
 	}()
 	go func() {
+		defer wg.Done()
 		for v := range fm.ports[0].Chan {
-			inputs <- v
+			select {
+			case inputs <- v:
+			case <-stop:
+				return
+			}
 		}
-		wg.Done()
 	}()
 	go func() {
 		wg.Wait()
 		close(inputs)
 	}()
 
-	for v := range inputs {
-		f(v)
+	// Stop early when the Context is canceled, for example when the code
+	// doing the iteration has timed out.
+	done := fm.ctx.Done()
+	for {
+		select {
+		case v, ok := <-inputs:
+			if !ok || !f(v) {
+				return
+			}
+		case <-done:
+			return
+		}
 	}
 }
 
-func linesToChan(r io.Reader, ch chan<- any) {
+func linesToChan(r io.Reader, ch chan<- any, stop <-chan struct{}) {
 	filein := bufio.NewReader(r)
 	for {
 		line, err := filein.ReadString('\n')
 		if line != "" {
-			ch <- strutil.ChopLineEnding(line)
+			select {
+			case ch <- strutil.ChopLineEnding(line):
+			case <-stop:
+				return
+			}
 		}
 		if err != nil {
 			if err != io.EOF {
@@ -181,6 +236,25 @@ func (fm *Frame) Canceled() bool {
 	}
 }
 
+// CanceledError returns the error to throw when the Context of the Frame has
+// been canceled, either [ErrInterrupted] or the cause of the cancellation.
+func (fm *Frame) CanceledError() error {
+	return canceledError(fm.ctx)
+}
+
+// Source returns the source of the code running in fm.
+func (fm *Frame) Source() parse.Source { return fm.src }
+
+// StackTrace returns the stack trace of fm. The head is the call site of the
+// innermost function call.
+func (fm *Frame) StackTrace() *StackTrace { return fm.traceback }
+
+// Local returns the local namespace of fm.
+func (fm *Frame) Local() *Ns { return fm.local }
+
+// Up returns the namespace of variables captured by the closure running in fm.
+func (fm *Frame) Up() *Ns { return fm.up }
+
 // Fork returns a copy of fm, with the ports cloned.
 func (fm *Frame) Fork() *Frame {
 	newFm := *fm
@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"unsafe"
+
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Future is the result of a callable running in the background, started by
+// spawn.
+//
+// Futures are structured: a future belongs to the scope it is spawned in,
+// which is the body of the closure or the piece of code being evaluated. When
+// the scope exits, the futures in it that have not been awaited are canceled
+// and waited for, so they never outlive the scope.
+type Future struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	scope  *futureScope
+	done   chan struct{}
+	// Set before done is closed.
+	values []any
+	err    error
+}
+
+// Kind returns "future".
+func (*Future) Kind() string { return "future" }
+
+// Equal compares by address.
+func (f *Future) Equal(rhs any) bool { return f == rhs }
+
+// Hash returns the hash of the address of the future.
+func (f *Future) Hash() uint32 { return hash.Pointer(unsafe.Pointer(f)) }
+
+// Repr returns an opaque representation "<future 0x23333333>".
+func (f *Future) Repr(int) string { return fmt.Sprintf("<future %p>", f) }
+
+// A scope of futures.
+type futureScope struct {
+	mu      sync.Mutex
+	futures []*Future
+}
+
+func (s *futureScope) add(f *Future) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.futures = append(s.futures, f)
+}
+
+func (s *futureScope) remove(f *Future) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.futures, f); i != -1 {
+		s.futures = slices.Delete(s.futures, i, i+1)
+	}
+}
+
+// Waits for the futures that have not been awaited to finish, canceling them
+// first if cancel is true, which is the case when the scope exits with an
+// exception. Returns the first exception thrown by them, ignoring futures that
+// have been canceled.
+func (s *futureScope) exit(cancel bool) Exception {
+	s.mu.Lock()
+	futures := s.futures
+	s.futures = nil
+	s.mu.Unlock()
+	if cancel {
+		for _, f := range futures {
+			f.cancel(ErrCanceled)
+		}
+	}
+	var exc Exception
+	for _, f := range futures {
+		<-f.done
+		if f.err == nil || exc != nil || context.Cause(f.ctx) == ErrCanceled {
+			continue
+		}
+		if e, ok := f.err.(Exception); ok {
+			exc = e
+		} else {
+			exc = &exception{f.err, nil}
+		}
+	}
+	return exc
+}
+
+func spawn(fm *Frame, f Callable) *Future {
+	ctx, cancel := context.WithCancelCause(fm.ctx)
+	future := &Future{ctx: ctx, cancel: cancel, scope: fm.futures, done: make(chan struct{})}
+	// The future runs outside of the job running the caller, since it may
+	// outlive the pipeline.
+	newFm := fm.Fork()
+	newFm.ctx = ctx
+	newFm.job, newFm.jobControl = nil, false
+	newFm.detached = true
+	newFm.ports[0] = DummyInputPort
+	if future.scope != nil {
+		future.scope.add(future)
+	}
+	go func() {
+		future.values, future.err = newFm.CaptureOutput(func(fm *Frame) error {
+			return f.Call(fm, NoArgs, NoOpts)
+		})
+		cancel(nil)
+		close(future.done)
+	}()
+	return future
+}
+
+func await(fm *Frame, futures ...*Future) error {
+	out := fm.ValueOutput()
+	for _, f := range futures {
+		select {
+		case <-f.done:
+		case <-fm.ctx.Done():
+			return canceledError(fm.ctx)
+		}
+		if f.scope != nil {
+			f.scope.remove(f)
+		}
+		for _, v := range f.values {
+			if err := out.Put(v); err != nil {
+				return err
+			}
+		}
+		if f.err != nil {
+			return f.err
+		}
+	}
+	return nil
+}
+
+func cancelFn(futures ...*Future) {
+	for _, f := range futures {
+		f.cancel(ErrCanceled)
+	}
+}
@@ -0,0 +1,142 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Generator is a lazy sequence of values produced by a callable.
+//
+// Each time a generator is iterated, the callable is called with a yield
+// function as its only argument, and each value passed to the yield function
+// becomes an element of the sequence. The callable runs on a separate
+// goroutine, and the yield function blocks until the consumer asks for the
+// next element, so elements are only computed on demand. When the consumer
+// stops iterating early, the pending call to the yield function throws
+// [errs.ReaderGone], which is not treated as an error of the iteration.
+//
+// When iterated by Elvish code, the callable runs with the output and error
+// ports of the code doing the iteration; see [Generator.IterateFrame].
+type Generator struct {
+	// The Frame the generator is created in is not kept, since its ports may
+	// be closed by the time the generator is iterated.
+	ev *Evaler
+	f  Callable
+}
+
+var _ FrameIterator = (*Generator)(nil)
+
+func generator(fm *Frame, f Callable) *Generator {
+	return &Generator{fm.Evaler, f}
+}
+
+// Kind returns "generator".
+func (*Generator) Kind() string { return "generator" }
+
+// Equal compares by address.
+func (g *Generator) Equal(rhs any) bool { return g == rhs }
+
+// Hash returns the hash of the address of the generator.
+func (g *Generator) Hash() uint32 { return hash.Pointer(unsafe.Pointer(g)) }
+
+// Repr returns an opaque representation "<generator 0x23333333>".
+func (g *Generator) Repr(int) string { return fmt.Sprintf("<generator %p>", g) }
+
+// Iterate calls the callable of the generator and calls f with each value it
+// yields, until either the callable returns or f returns false. It returns any
+// exception thrown by the callable.
+//
+// Since Iterate has no access to the Frame of the caller, the callable runs
+// with ports that discard all output. Code in this package should use
+// [iterate] instead.
+func (g *Generator) Iterate(f func(any) bool) error {
+	// DevNull is only open for reading, so open another one for writing.
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+	out := &Port{File: devNull, Chan: BlackholeChan}
+	fm, cleanup := g.ev.prepareFrame(parse.Source{Name: "[generator]"},
+		EvalCfg{Ports: []*Port{nil, out, out}, Global: g.ev.Global()})
+	defer cleanup()
+	return g.IterateFrame(fm, f)
+}
+
+// IterateFrame is like Iterate, but the callable runs with the ports of fm.
+func (g *Generator) IterateFrame(fm *Frame, f func(any) bool) error {
+	values := make(chan any)
+	resume := make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	yield := NewGoFn("yield", func(vs ...any) error {
+		for _, v := range vs {
+			select {
+			case values <- v:
+			case <-stop:
+				return errs.ReaderGone{}
+			}
+			select {
+			case <-resume:
+			case <-stop:
+				return errs.ReaderGone{}
+			}
+		}
+		return nil
+	})
+	go func() {
+		callFm := fm.Fork()
+		callFm.ports[0] = DummyInputPort
+		done <- g.f.Call(callFm, []any{yield}, NoOpts)
+	}()
+
+	for {
+		select {
+		case v := <-values:
+			if !f(v) {
+				close(stop)
+				if err := <-done; err != nil && !isReaderGoneError(err) {
+					return err
+				}
+				return nil
+			}
+			resume <- struct{}{}
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// FrameIterator is implemented by iterable values whose iteration depends on
+// the Frame of the code doing the iteration, for example to run code with its
+// ports, or to stop when its Context is canceled.
+type FrameIterator interface {
+	vals.ErrIterator
+	// IterateFrame is like Iterate, but is called with the Frame of the code
+	// doing the iteration.
+	IterateFrame(fm *Frame, f func(any) bool) error
+}
+
+// Like [vals.Iterate], but values implementing [FrameIterator] are iterated
+// with fm.
+func iterate(fm *Frame, v any, f func(any) bool) error {
+	if it, ok := v.(FrameIterator); ok {
+		return it.IterateFrame(fm, f)
+	}
+	return vals.Iterate(v, f)
+}
+
+func isReaderGoneError(err error) bool {
+	if exc, ok := err.(Exception); ok {
+		return isReaderGone(exc)
+	}
+	_, ok := err.(errs.ReaderGone)
+	return ok
+}
@@ -256,7 +256,7 @@ func doGlob(ctx context.Context, gp globPattern) ([]any, error) {
 		}
 		return true
 	}) {
-		return nil, ErrInterrupted
+		return nil, canceledError(ctx)
 	}
 	if len(vs) == 0 && !gp.Flags.Has(noMatchOK) {
 		return nil, ErrWildcardNoMatch
@@ -48,6 +48,8 @@ type goFn struct {
 	options reflect.Type
 	// If not nil, pass the inputs as an Input-typed last argument.
 	inputs bool
+	// Whether the last argument has type StoppableInputs rather than Inputs.
+	stoppableInputs bool
 	// Type of "normal" (non-frame, non-options, non-variadic) arguments.
 	normalArgs []reflect.Type
 	// If not nil, type of variadic arguments.
@@ -65,11 +67,18 @@ type optionsPtr interface {
 // for details.
 type Inputs func(func(any))
 
+// StoppableInputs is like Inputs, but the function passed to it returns
+// whether to continue the iteration. Returning false stops the iteration
+// early, which is necessary when the inputs come from an infinite iterable like
+// a generator or an unclosed channel.
+type StoppableInputs func(func(any) bool)
+
 var (
-	frameType      = reflect.TypeOf((*Frame)(nil))
-	rawOptionsType = reflect.TypeOf(RawOptions(nil))
-	optionsPtrType = reflect.TypeOf((*optionsPtr)(nil)).Elem()
-	inputsType     = reflect.TypeOf(Inputs(nil))
+	frameType           = reflect.TypeOf((*Frame)(nil))
+	rawOptionsType      = reflect.TypeOf(RawOptions(nil))
+	optionsPtrType      = reflect.TypeOf((*optionsPtr)(nil)).Elem()
+	inputsType          = reflect.TypeOf(Inputs(nil))
+	stoppableInputsType = reflect.TypeOf(StoppableInputs(nil))
 )
 
 // NewGoFn wraps a Go function into an Elvish function using reflection.
@@ -93,10 +102,10 @@ var (
 // If the function does not declare that it accepts options via either method
 // described above, it accepts no options.
 //
-// 3. If the last parameter is non-variadic and has type Inputs, it represents
-// an optional parameter that contains the input to this function. If the
-// argument is not supplied, the input channel of the Frame will be used to
-// supply the inputs.
+// 3. If the last parameter is non-variadic and has type Inputs or
+// StoppableInputs, it represents an optional parameter that contains the input
+// to this function. If the argument is not supplied, the input channel of the
+// Frame will be used to supply the inputs.
 //
 // 4. Other parameters are converted using vals.ScanToGo.
 //
@@ -133,8 +142,9 @@ func NewGoFn(name string, impl any) Callable {
 			if implType.IsVariadic() {
 				b.variadicArg = paramType.Elem()
 				break
-			} else if paramType == inputsType {
+			} else if paramType == inputsType || paramType == stoppableInputsType {
 				b.inputs = true
+				b.stoppableInputs = paramType == stoppableInputsType
 				break
 			}
 		}
@@ -223,25 +233,33 @@ func (b *goFn) Call(f *Frame, args []any, opts map[string]any) error {
 		in = append(in, ptr.Elem())
 	}
 
+	// Error from iterating an iterable argument wrapped in Inputs. This can
+	// only be non-nil for values implementing [vals.ErrIterator].
+	var errIterate error
 	if b.inputs {
-		var inputs Inputs
+		var inputs StoppableInputs
 		if len(args) == len(b.normalArgs) {
-			inputs = f.IterateInputs
+			inputs = f.IterateStoppableInputs
 		} else {
-			// Wrap an iterable argument in Inputs.
+			// Wrap an iterable argument in StoppableInputs.
 			iterable := args[len(args)-1]
 			if !vals.CanIterate(iterable) {
 				return fmt.Errorf("%s cannot be iterated", vals.Kind(iterable))
 			}
-			inputs = func(f func(any)) {
-				// CanIterate(iterable) is true
-				_ = vals.Iterate(iterable, func(v any) bool {
-					f(v)
+			inputs = func(g func(any) bool) {
+				errIterate = iterate(f, iterable, g)
+			}
+		}
+		if b.stoppableInputs {
+			in = append(in, reflect.ValueOf(inputs))
+		} else {
+			in = append(in, reflect.ValueOf(Inputs(func(g func(any)) {
+				inputs(func(v any) bool {
+					g(v)
 					return true
 				})
-			}
+			})))
 		}
-		in = append(in, reflect.ValueOf(inputs))
 	}
 
 	rets := reflect.ValueOf(b.impl).Call(in)
@@ -253,6 +271,9 @@ func (b *goFn) Call(f *Frame, args []any, opts map[string]any) error {
 		}
 		rets = rets[:len(rets)-1]
 	}
+	if errIterate != nil {
+		return errIterate
+	}
 
 	out := f.ValueOutput()
 	for _, ret := range rets {
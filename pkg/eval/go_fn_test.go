@@ -36,6 +36,12 @@ var goFnsMod = BuildNs().AddGoFns(map[string]any{
 			fmt.Fprintf(fm.ByteOutput(), "input: %v\n", x)
 		})
 	},
+	"takes-first-input": func(fm *Frame, i StoppableInputs) {
+		i(func(x any) bool {
+			fmt.Fprintf(fm.ByteOutput(), "input: %v\n", x)
+			return false
+		})
+	},
 	"takes-options": func(fm *Frame, opts someOptions) {
 		fmt.Fprintf(fm.ByteOutput(), "opts = %#v\n", opts)
 	},
@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"os"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// A here-document, which evaluates to its body with the values of the
+// variables in it interpolated.
+type hereDocOp struct {
+	diag.Ranging
+	texts  []string
+	varOps []valuesOp
+}
+
+func (cp *compiler) hereDocOp(n *parse.HereDoc) valuesOp {
+	return &hereDocOp{n.Range(), n.Texts, cp.primaryOps(n.Vars)}
+}
+
+func (op *hereDocOp) exec(fm *Frame) ([]any, Exception) {
+	var sb strings.Builder
+	for i, text := range op.texts {
+		if i > 0 && i <= len(op.varOps) {
+			v, exc := evalForValue(fm, op.varOps[i-1], "interpolated variable")
+			if exc != nil {
+				return nil, exc
+			}
+			sb.WriteString(vals.ToString(v))
+		}
+		sb.WriteString(text)
+	}
+	return []any{sb.String()}, nil
+}
+
+// Creates a port for reading text from a here-document or here-string. The
+// text is written to a pipe in the background, which stops when the port is
+// closed.
+func textRedirPort(text string) (*Port, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		w.WriteString(text)
+		w.Close()
+	}()
+	return fileRedirPort(parse.Read, r), nil
+}
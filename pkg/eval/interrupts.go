@@ -11,6 +11,28 @@ import (
 // ErrInterrupted is thrown when the execution is interrupted by a signal.
 var ErrInterrupted = errors.New("interrupted")
 
+// ErrCanceled is thrown when the execution is canceled, for example by
+// canceling the future running it.
+var ErrCanceled = errors.New("canceled")
+
+// Returns the error to throw when ctx has been canceled. If ctx was canceled
+// with a cause, like the Context of a canceled future, the cause is returned.
+// Otherwise, ctx was canceled by an interrupt, and ErrInterrupted is returned.
+func canceledError(ctx context.Context) error {
+	if cause := context.Cause(ctx); cause != nil && cause != context.Canceled {
+		return cause
+	}
+	return ErrInterrupted
+}
+
+// Reports whether external commands running with ctx should be terminated
+// because ctx has been canceled. This is not the case for interrupts, since
+// the signals are also received by the external commands themselves.
+func shouldTerminate(ctx context.Context) bool {
+	cause := context.Cause(ctx)
+	return cause != nil && cause != context.Canceled
+}
+
 // ListenInterrupts returns a Context that is canceled when SIGINT or SIGQUIT
 // has been received by the process. It also returns a function to cancel the
 // Context, which should be called when it is no longer needed.
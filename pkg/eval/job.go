@@ -0,0 +1,260 @@
+package eval
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Job control.
+//
+// A job is a pipeline whose external processes are managed as a unit. Every
+// background pipeline (one ending with "&") is a job. When job control is
+// enabled (see [EvalCfg]), every top-level foreground pipeline is also a job:
+// on Unix, its processes are put into a process group of their own that is
+// given the terminal, so that the job can be suspended with Ctrl-Z and later
+// resumed with the fg or bg builtin.
+//
+// Jobs are added to the job table of the [Evaler] when they are started in the
+// background or get stopped while in the foreground, and removed when they
+// finish or get disowned.
+
+// ErrNoCurrentJob is thrown by fg, bg and disown when they are called without
+// an argument and the job table is empty.
+var ErrNoCurrentJob = errors.New("no current job")
+
+// ErrJobNotStopped is thrown by bg when the job is not stopped.
+var ErrJobNotStopped = errors.New("job is not stopped")
+
+type job struct {
+	ev     *Evaler
+	source string
+	// Closed when all the forms of the pipeline have finished.
+	done chan struct{}
+	// Receives a value when the job gets stopped while in the foreground.
+	stopCh chan struct{}
+
+	mu sync.Mutex
+	// The following fields are guarded by mu.
+
+	// ID in the job table, or 0 if the job has never been added to it.
+	id int
+	// Whether the job is in the foreground.
+	fg bool
+	// Whether the processes of the job have been stopped.
+	stopped bool
+	// Whether all the forms of the pipeline have finished.
+	finished bool
+	// Whether the job has been removed from the job table by disown.
+	disowned bool
+	// Process group of the job, or 0 if no process of the job is alive. Only
+	// used on Unix.
+	pgid int
+	// Number of live processes.
+	nprocs int
+	// PIDs of the processes that have not been reaped. Only used on Unix.
+	pids map[int]bool
+	// Error of the pipeline, set when finished becomes true.
+	err error
+	// Whether the processes of the job should be given the terminal when they
+	// are in the foreground.
+	control bool
+}
+
+func newJob(ev *Evaler, source string, fg, control bool) *job {
+	return &job{ev: ev, source: source,
+		done: make(chan struct{}), stopCh: make(chan struct{}, 1),
+		fg: fg, control: control}
+}
+
+// Records that all the forms of the job have finished with the given error.
+// For a background job, this also removes it from the job table and notifies
+// the user; for a foreground job, this is the responsibility of whoever is
+// waiting for the job in waitFg.
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	j.finished = true
+	j.err = err
+	fg, disowned := j.fg, j.disowned
+	j.mu.Unlock()
+	close(j.done)
+	if fg {
+		return
+	}
+
+	ev := j.ev
+	ev.removeJob(j)
+	ev.addNumBgJobs(-1)
+	if notify := ev.BgJobNotify; notify != nil && !disowned {
+		msg := "job " + j.source + " finished"
+		if err != nil {
+			msg += ", errors = " + err.Error()
+		}
+		if ev.getNotifyBgJobSuccess() || err != nil {
+			notify(msg)
+		}
+	}
+}
+
+// Waits for a foreground job to finish or get stopped, and returns whether it
+// has been stopped. When the job gets stopped, it is moved to the background
+// and added to the job table; when it finishes, it is removed from the job
+// table.
+func (j *job) waitFg() bool {
+	select {
+	case <-j.done:
+	case <-j.stopCh:
+		j.mu.Lock()
+		if !j.finished {
+			j.fg = false
+			j.mu.Unlock()
+			j.reclaimTerminal()
+			ev := j.ev
+			ev.addJob(j)
+			ev.addNumBgJobs(1)
+			if notify := ev.BgJobNotify; notify != nil {
+				notify("job " + j.source + " stopped")
+			}
+			return true
+		}
+		// The job finished right after getting stopped (for example, it was
+		// killed while stopped). Treat it as finished.
+		j.mu.Unlock()
+		<-j.done
+	}
+	j.ev.removeJob(j)
+	return false
+}
+
+// Moves a background job to the foreground, giving it the terminal and resuming
+// it if it is stopped. It returns false if the job has already finished.
+func (j *job) bringToFg() (bool, error) {
+	j.mu.Lock()
+	if j.finished {
+		j.mu.Unlock()
+		return false, nil
+	}
+	j.fg = true
+	wasStopped := j.stopped
+	j.stopped = false
+	j.mu.Unlock()
+	j.ev.addNumBgJobs(-1)
+
+	j.giveTerminal()
+	if wasStopped {
+		if err := j.resume(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Called when a process of the job has been stopped.
+func (j *job) processStopped() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stopped {
+		return
+	}
+	j.stopped = true
+	if j.fg {
+		select {
+		case j.stopCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Called when a process of the job has exited and been reaped.
+func (j *job) processExited(pid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nprocs--
+	delete(j.pids, pid)
+	if j.nprocs == 0 {
+		j.pgid = 0
+		if j.fg {
+			j.reclaimTerminal()
+		}
+	}
+}
+
+// Gives the terminal back to Elvish if the job is subject to job control.
+func (j *job) reclaimTerminal() {
+	if j.control {
+		putSelfInFg()
+	}
+}
+
+func (j *job) info() any {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state := "running"
+	if j.stopped {
+		state = "stopped"
+	}
+	return vals.MakeMap("id", j.id, "source", j.source, "state", state)
+}
+
+// Adds a job to the job table, assigning it an ID if it doesn't have one yet.
+func (ev *Evaler) addJob(j *job) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.id != 0 {
+		return
+	}
+	j.id = 1
+	if n := len(ev.jobs); n > 0 {
+		j.id = ev.jobs[n-1].id + 1
+	}
+	ev.jobs = append(ev.jobs, j)
+}
+
+// Removes a job from the job table. It is a no-op if the job is not in the
+// job table.
+func (ev *Evaler) removeJob(j *job) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for i, j2 := range ev.jobs {
+		if j2 == j {
+			ev.jobs = append(ev.jobs[:i:i], ev.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns a snapshot of the job table.
+func (ev *Evaler) getJobs() []*job {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return append([]*job(nil), ev.jobs...)
+}
+
+// Finds a job by its ID in the job table. If ids is empty, it returns the
+// current job, which is the job most recently added to the job table.
+func (ev *Evaler) findJob(ids []int) (*job, error) {
+	if len(ids) > 1 {
+		return nil, errs.ArityMismatch{What: "arguments",
+			ValidLow: 0, ValidHigh: 1, Actual: len(ids)}
+	}
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	if len(ids) == 0 {
+		if len(ev.jobs) == 0 {
+			return nil, ErrNoCurrentJob
+		}
+		return ev.jobs[len(ev.jobs)-1], nil
+	}
+	for _, j := range ev.jobs {
+		if j.id == ids[0] {
+			return j, nil
+		}
+	}
+	return nil, errs.BadValue{What: "job ID",
+		Valid: "ID of an existing job", Actual: strconv.Itoa(ids[0])}
+}
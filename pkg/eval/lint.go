@@ -0,0 +1,200 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// Linting.
+//
+// The linter piggybacks on the compiler, which already keeps track of the
+// scopes and resolves every variable reference. When linting, the compiler
+// records each variable declared in a scope, marks it as used when a reference
+// resolves to it, and reports the unused ones when the scope is popped. The
+// following issues are reported:
+//
+//   - Variables, parameters, options and functions declared in a function body
+//     that are never referenced.
+//
+//   - Modules imported with use that are never referenced.
+//
+//   - Declarations that shadow a variable of the same name in an outer scope.
+//
+//   - Code following a call to return, fail, break or continue in the same
+//     chunk, which can never be executed.
+//
+//   - Uses of deprecated builtins, subject to the deprecation level.
+//
+// A variable is considered used when it is referenced anywhere other than its
+// declaration, including assignments. Variables named "_" are never reported.
+
+// LintWarning is a warning found by the linter.
+type LintWarning = diag.Error[LintWarningTag]
+
+// LintWarningTag parameterizes [diag.Error] to define [LintWarning].
+type LintWarningTag struct{}
+
+func (LintWarningTag) ErrorTag() string { return "warning" }
+
+// Lint checks the given parsed source tree for compilation errors, and for
+// issues that are not errors but likely to be mistakes. The warnings are sorted
+// by their positions.
+func (ev *Evaler) Lint(tree parse.Tree) ([]*LintWarning, error) {
+	ev.mu.RLock()
+	b, g, m := ev.builtin, ev.global, ev.modules
+	ev.mu.RUnlock()
+	global := g.static().clone()
+	cp := newCompiler(b, global, m, tree, nil)
+	cp.lint = &linter{decls: make(map[*staticNs]map[int]*declInfo)}
+	cp.chunkOp(tree.Root)
+	// Variables in the global scope may be used by code that is evaluated
+	// later, so only imported modules are checked.
+	cp.lintUnused(global, true)
+	warnings := cp.lint.warnings
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Context.From < warnings[j].Context.From
+	})
+	return warnings, diag.PackErrors(cp.errors)
+}
+
+type linter struct {
+	// Declarations in each scope, keyed by their indices in the scope.
+	decls    map[*staticNs]map[int]*declInfo
+	warnings []*LintWarning
+}
+
+type declInfo struct {
+	name string
+	kind declKind
+	r    diag.Ranging
+	used bool
+}
+
+type declKind int
+
+const (
+	varDecl declKind = iota
+	paramDecl
+	optDecl
+	fnDecl
+	moduleDecl
+)
+
+// Describes a declaration in messages, like "variable $x" or "function f".
+func (d *declInfo) describe() string {
+	switch d.kind {
+	case paramDecl:
+		return "parameter $" + parse.Quote(d.name)
+	case optDecl:
+		return "option $" + parse.Quote(d.name)
+	case fnDecl:
+		return "function " + parse.Quote(d.name[:len(d.name)-len(FnSuffix)])
+	case moduleDecl:
+		return "module " + parse.Quote(d.name[:len(d.name)-len(NsSuffix)])
+	default:
+		return "variable $" + parse.Quote(d.name)
+	}
+}
+
+// Adds a variable to the current scope and returns its index. When linting,
+// this also records the declaration and checks whether it shadows a variable in
+// an outer scope.
+func (cp *compiler) declare(name string, kind declKind, r diag.Ranger) int {
+	sc := cp.thisScope()
+	index := sc.add(name)
+	if cp.lint == nil || name == "_" || name == "" {
+		return index
+	}
+	d := &declInfo{name, kind, r.Range(), false}
+	for i := len(cp.scopes) - 2; i >= 0; i-- {
+		if _, j := cp.scopes[i].lookup(name); j != -1 {
+			cp.lintWarnf(r, "%s shadows a variable in an outer scope", d.describe())
+			break
+		}
+	}
+	if cp.lint.decls[sc] == nil {
+		cp.lint.decls[sc] = make(map[int]*declInfo)
+	}
+	cp.lint.decls[sc][index] = d
+	return index
+}
+
+// Marks a variable as used.
+func (cp *compiler) markUsed(sc *staticNs, index int) {
+	if cp.lint == nil {
+		return
+	}
+	if d := cp.lint.decls[sc][index]; d != nil {
+		d.used = true
+	}
+}
+
+// Reports unused declarations in a scope.
+func (cp *compiler) lintUnused(sc *staticNs, modulesOnly bool) {
+	decls := cp.lint.decls[sc]
+	delete(cp.lint.decls, sc)
+	for _, d := range decls {
+		if d.used || (modulesOnly && d.kind != moduleDecl) {
+			continue
+		}
+		switch d.kind {
+		case moduleDecl:
+			cp.lintWarnf(d.r, "%s is imported but not used", d.describe())
+		case fnDecl:
+			cp.lintWarnf(d.r, "%s is defined but not used", d.describe())
+		default:
+			cp.lintWarnf(d.r, "%s is declared but not used", d.describe())
+		}
+	}
+}
+
+// Commands after which the rest of the chunk is never executed.
+var terminatingCommands = map[string]bool{
+	"return": true, "fail": true, "break": true, "continue": true,
+}
+
+// Reports pipelines that follow a pipeline that always terminates the chunk.
+func (cp *compiler) lintUnreachable(n *parse.Chunk) {
+	for i, p := range n.Pipelines[:max(len(n.Pipelines)-1, 0)] {
+		if cp.isTerminating(p) {
+			last := n.Pipelines[len(n.Pipelines)-1]
+			cp.lintWarnf(diag.Ranging{From: n.Pipelines[i+1].From, To: last.To},
+				"unreachable code")
+			return
+		}
+	}
+}
+
+func (cp *compiler) isTerminating(p *parse.Pipeline) bool {
+	if p.Background || len(p.Forms) != 1 {
+		return false
+	}
+	head, ok := cmpd.StringLiteral(p.Forms[0].Head)
+	if !ok || !terminatingCommands[head] {
+		return false
+	}
+	// The command must resolve to the builtin.
+	for _, sc := range cp.scopes {
+		if _, index := sc.lookup(head + FnSuffix); index != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (cp *compiler) lintWarnf(r diag.Ranger, format string, args ...any) {
+	// Ranges of forms and pipelines include trailing whitespaces; exclude them
+	// from the highlighted part.
+	rg := r.Range()
+	rg.To = rg.From + len(strings.TrimRightFunc(cp.src.Code[rg.From:rg.To], unicode.IsSpace))
+	cp.lint.warnings = append(cp.lint.warnings, &LintWarning{
+		Message: fmt.Sprintf(format, args...),
+		Context: *diag.NewContext(cp.src.Name, cp.src.Code, rg),
+	})
+}
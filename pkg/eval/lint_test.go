@@ -0,0 +1,116 @@
+package eval_test
+
+import (
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+
+	"src.elv.sh/pkg/mods/str"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/tt"
+)
+
+// Lints the code and returns each warning as its message followed by the
+// source text it covers.
+func lint(ev *Evaler, code string) ([]string, error) {
+	tree, err := parse.Parse(parse.Source{Name: "[test]", Code: code}, parse.Config{})
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := ev.Lint(tree)
+	var strs []string
+	for _, w := range warnings {
+		strs = append(strs, w.Message+": "+code[w.Context.From:w.Context.To])
+	}
+	return strs, err
+}
+
+func TestLint(t *testing.T) {
+	ev := NewEvaler()
+	ev.AddModule("str", str.Ns)
+	lint := func(code string) ([]string, error) { return lint(ev, code) }
+
+	tt.Test(t, tt.Fn(lint).Named("lint"),
+		// No warnings.
+		Args("var x = foo; echo $x").Rets([]string(nil), nil),
+		Args("fn f {|a &o=x| echo $a $o }").Rets([]string(nil), nil),
+
+		// Unused variables, parameters, options and functions in function
+		// bodies.
+		Args("fn f { var x = foo }").Rets([]string{
+			"variable $x is declared but not used: x"}, nil),
+		Args("fn f {|a @b &o=x| }").Rets([]string{
+			"parameter $a is declared but not used: a",
+			"parameter $b is declared but not used: @b",
+			"option $o is declared but not used: o"}, nil),
+		Args("fn f { fn g { } }").Rets([]string{
+			"function g is defined but not used: g"}, nil),
+		// Variables in destructuring patterns, including in parameters.
+		Args("fn f {|[a b]| var [c [&d]] = [x [&d=y]]; echo $a $c }").Rets([]string{
+			"variable $b is declared but not used: b",
+			"variable $d is declared but not used: d"}, nil),
+		// Variables named _ are not reported.
+		Args("fn f {|_| var _ = foo }").Rets([]string(nil), nil),
+		// Variables in the global scope are not reported.
+		Args("var x = foo; fn f { }").Rets([]string(nil), nil),
+		// Captured variables are used.
+		Args("fn f { var x = foo; put { echo $x } }").Rets([]string(nil), nil),
+
+		// Unused modules, both in the global scope and function bodies.
+		Args("use str").Rets([]string{
+			"module str is imported but not used: use str"}, nil),
+		Args("use str; str:join , [a b]").Rets([]string(nil), nil),
+		Args("fn f { use str }").Rets([]string{
+			"module str is imported but not used: use str"}, nil),
+
+		// Shadowing.
+		Args("var x = foo; fn f {|x| echo $x }").Rets([]string{
+			"parameter $x shadows a variable in an outer scope: x"}, nil),
+		Args("fn f {|x| put { var x = foo; echo $x } $x }").Rets([]string{
+			"variable $x shadows a variable in an outer scope: x"}, nil),
+		// Redeclaring a variable in the same scope is not shadowing.
+		Args("var x = foo; var x = bar; echo $x").Rets([]string(nil), nil),
+
+		// Unreachable code.
+		Args("fn f { return; echo foo\necho bar }").Rets([]string{
+			"unreachable code: echo foo\necho bar"}, nil),
+		Args("for x [] { echo $x; continue; echo $x }").Rets([]string{
+			"unreachable code: echo $x"}, nil),
+		Args("fail foo; echo bar").Rets([]string{
+			"unreachable code: echo bar"}, nil),
+		// Only pipelines consisting of just the terminating command count.
+		Args("fail foo | echo bar; echo bar").Rets([]string(nil), nil),
+		Args("return &; echo bar").Rets([]string(nil), nil),
+		// Only the builtin commands count.
+		Args("fn fail {|_| }; fail foo; echo bar").Rets([]string(nil), nil),
+
+		// Calls that don't match the signature of the function.
+		Args("use str; str:join , a b").Rets([]string{
+			"arity mismatch: arguments must be 1 to 2 values, but is 3 values: str:join , a b"}, nil),
+		Args("fn f {|a| echo $a }; f a &x=y").Rets([]string{
+			"unknown option: x: &x=y"}, nil),
+
+		// Warnings are sorted by position.
+		Args("fn f {|a| var b = foo }; use str").Rets([]string{
+			"parameter $a is declared but not used: a",
+			"variable $b is declared but not used: b",
+			"module str is imported but not used: use str"}, nil),
+
+		// Compilation errors are returned along with warnings.
+		Args("fn f { var x = foo }; echo $y").Rets(
+			[]string{"variable $x is declared but not used: x"}, tt.Any),
+	)
+}
+
+func TestLint_DeprecatedBuiltin(t *testing.T) {
+	testutil.Set(t, &prog.DeprecationLevel, 22)
+	ev := NewEvaler()
+	ev.ExtendBuiltin(BuildNs().AddGoFn("foo", func() {}))
+
+	tt.Test(t, tt.Fn(func(code string) ([]string, error) { return lint(ev, code) }).Named("lint"),
+		Args("foo").Rets([]string{
+			`the "foo" command is deprecated; use "bar" instead: foo`}, nil),
+	)
+}
@@ -42,6 +42,12 @@ type staticVarInfo struct {
 	// reference to them in a closure. Shadowed variables are also considered
 	// deleted.
 	deleted bool
+	// Signature of the function stored in the variable, if it is known at
+	// compile time. Only used for function variables.
+	sig *fnSig
+	// The namespace stored in the variable, if it is known at compile time.
+	// Only used for namespace variables.
+	mod *Ns
 }
 
 // CombineNs returns an *Ns that contains all the bindings from both ns1 and
@@ -234,7 +240,7 @@ func (nb NsBuilder) Ns() *Ns {
 	i := 0
 	for name, variable := range nb.m {
 		ns.slots[i] = variable
-		ns.infos[i] = staticVarInfo{name, vars.IsReadOnly(variable), false}
+		ns.infos[i] = staticVarInfo{name: name, readOnly: vars.IsReadOnly(variable)}
 		i++
 	}
 	return ns
@@ -263,7 +269,7 @@ func (ns *staticNs) del(k string) {
 // name.
 func (ns *staticNs) add(k string) int {
 	ns.del(k)
-	ns.infos = append(ns.infos, staticVarInfo{k, false, false})
+	ns.infos = append(ns.infos, staticVarInfo{name: k})
 	return len(ns.infos) - 1
 }
 
@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+
+	"src.elv.sh/pkg/diag"
+)
+
+// ErrProcessSubstitutionUnsupported is thrown when using process substitution
+// on a platform without /dev/fd.
+var ErrProcessSubstitutionUnsupported = errors.New("process substitution is not supported on " + runtime.GOOS)
+
+// A process substitution, either <(...) or >(...). It runs the chunk in the
+// background, connected to a pipe, and evaluates to a /dev/fd path of the
+// other end of the pipe.
+type processSubstOp struct {
+	diag.Ranging
+	// Whether this is a <(...), whose output is read from the path.
+	read  bool
+	subop effectOp
+}
+
+// A running process substitution, owned by the form whose arguments started
+// it.
+type procSub struct {
+	// The end of the pipe used by the form, and its file descriptor.
+	file *os.File
+	fd   int
+	// Only set for <(...). Set to true when the form has finished, so that
+	// the pipeline is not considered to have failed when it gets SIGPIPE.
+	readerGone *atomic.Bool
+	// Closed when the pipeline has finished, after exc is set.
+	done chan struct{}
+	exc  Exception
+}
+
+func (op processSubstOp) exec(fm *Frame) ([]any, Exception) {
+	if runtime.GOOS == "windows" {
+		return nil, fm.errorp(op, ErrProcessSubstitutionUnsupported)
+	}
+	// os.Pipe sets O_CLOEXEC, so neither end is inherited by external
+	// commands by accident; see externalCmd.Call for how the end used by the
+	// form is passed on.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fm.errorpf(op, "failed to create pipe: %s", err)
+	}
+	sub := &procSub{done: make(chan struct{})}
+	newFm := fm.Fork()
+	// The pipeline doesn't get the files of sibling process substitutions;
+	// holding the write end of a >(...) would prevent it from ever seeing EOF.
+	newFm.procSubs = nil
+	var own *os.File
+	if op.read {
+		sub.file, own = r, w
+		sub.readerGone = new(atomic.Bool)
+		newFm.ports[1] = &Port{File: w, Chan: BlackholeChan, readerGone: sub.readerGone}
+	} else {
+		sub.file, own = w, r
+		newFm.ports[0] = &Port{File: r, Chan: ClosedChan}
+	}
+	sub.fd = int(sub.file.Fd())
+	fm.procSubs = append(fm.procSubs, sub)
+
+	go func() {
+		exc := op.subop.exec(newFm)
+		own.Close()
+		if exc != nil && !(op.read && isReaderGone(exc)) {
+			sub.exc = exc
+		}
+		close(sub.done)
+	}()
+	return []any{"/dev/fd/" + strconv.Itoa(sub.fd)}, nil
+}
+
+// Closes the files of process substitutions started by a form that has
+// finished with exc, waits for their pipelines to finish, and returns exc
+// combined with their exceptions.
+func waitProcSubs(fm *Frame, r diag.Ranger, subs []*procSub, exc Exception) Exception {
+	for _, sub := range subs {
+		if sub.readerGone != nil {
+			sub.readerGone.Store(true)
+		}
+		sub.file.Close()
+	}
+	excs := []Exception{exc}
+	for _, sub := range subs {
+		<-sub.done
+		excs = append(excs, sub.exc)
+	}
+	return fm.errorp(r, MakePipelineError(excs))
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"src.elv.sh/pkg/sys"
 	"src.elv.sh/pkg/sys/eunix"
@@ -28,3 +29,113 @@ func putSelfInFg() error {
 func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{Setpgid: bg}
 }
+
+// Starts a process that belongs to the job. All the processes of a job share
+// one process group, which is created by the first process. If the job is in
+// the foreground and subject to job control, the process group is also given
+// the terminal.
+func (j *job) startProcess(path string, args []string, files []*os.File) (*os.Process, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	attr := &syscall.SysProcAttr{Setpgid: true, Pgid: j.pgid}
+	if j.pgid == 0 && j.fg && j.control {
+		// The Ctty field refers to a file descriptor in the child process.
+		for i, f := range files {
+			if f != nil && sys.IsATTY(f.Fd()) {
+				attr.Foreground = true
+				attr.Ctty = i
+				break
+			}
+		}
+	}
+	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: attr})
+	if err != nil {
+		return nil, err
+	}
+	if j.pgid == 0 {
+		j.pgid = proc.Pid
+	}
+	if j.pids == nil {
+		j.pids = make(map[int]bool)
+	}
+	j.pids[proc.Pid] = true
+	j.nprocs++
+	return proc, nil
+}
+
+// Waits for a process of the job to exit, recording any stops along the way.
+func (j *job) waitProcess(proc *os.Process) (syscall.WaitStatus, error) {
+	defer proc.Release()
+	defer j.processExited(proc.Pid)
+	for {
+		var ws syscall.WaitStatus
+		_, err := syscall.Wait4(proc.Pid, &ws, syscall.WUNTRACED, nil)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return ws, err
+		}
+		if ws.Stopped() {
+			j.processStopped()
+			continue
+		}
+		return ws, nil
+	}
+}
+
+// Gives the terminal to the process group of the job if the job is subject to
+// job control.
+func (j *job) giveTerminal() {
+	j.mu.Lock()
+	pgid := j.pgid
+	j.mu.Unlock()
+	if j.control && pgid != 0 && sys.IsATTY(os.Stdin.Fd()) {
+		eunix.Tcsetpgrp(0, pgid)
+	}
+}
+
+// Resumes the processes of the job.
+func (j *job) resume() error {
+	j.mu.Lock()
+	pgid := j.pgid
+	j.mu.Unlock()
+	if pgid == 0 {
+		return nil
+	}
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// Time to wait after asking a process to terminate before killing it.
+const terminateGracePeriod = 2 * time.Second
+
+// Asks a process to terminate with SIGTERM, and kills it if it hasn't exited
+// after a grace period.
+func terminateProcess(proc *os.Process, exited <-chan struct{}) {
+	proc.Signal(syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(terminateGracePeriod):
+		proc.Kill()
+	}
+}
+
+// Like terminateProcess, but for a process of the job, which is reaped by
+// waitProcess with wait4 instead of os.Process.Wait. Since the PID of a reaped
+// process may be reused, the signals are sent to the process group of the job,
+// and only if the process hasn't been reaped yet.
+func (j *job) terminateProcess(proc *os.Process, exited <-chan struct{}) {
+	j.signalProcess(proc.Pid, syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(terminateGracePeriod):
+		j.signalProcess(proc.Pid, syscall.SIGKILL)
+	}
+}
+
+func (j *job) signalProcess(pid int, sig syscall.Signal) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.pids[pid] && j.pgid != 0 {
+		syscall.Kill(-j.pgid, sig)
+	}
+}
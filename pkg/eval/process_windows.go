@@ -1,6 +1,10 @@
 package eval
 
-import "syscall"
+import (
+	"errors"
+	"os"
+	"syscall"
+)
 
 // Nop on Windows.
 func putSelfInFg() error { return nil }
@@ -15,3 +19,47 @@ func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
 	}
 	return &syscall.SysProcAttr{CreationFlags: flags}
 }
+
+var errJobControlUnsupported = errors.New("stopping and resuming jobs is not supported on Windows")
+
+// Starts a process that belongs to the job. Windows doesn't have process
+// groups, so processes of background jobs are just started detached.
+func (j *job) startProcess(path string, args []string, files []*os.File) (*os.Process, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	sys := makeSysProcAttr(!j.fg)
+	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	if err != nil {
+		return nil, err
+	}
+	j.nprocs++
+	return proc, nil
+}
+
+// Waits for a process of the job to exit.
+func (j *job) waitProcess(proc *os.Process) (syscall.WaitStatus, error) {
+	defer j.processExited(proc.Pid)
+	state, err := proc.Wait()
+	if err != nil {
+		return syscall.WaitStatus{}, err
+	}
+	return state.Sys().(syscall.WaitStatus), nil
+}
+
+// Nop on Windows.
+func (j *job) giveTerminal() {}
+
+// Processes can't be stopped on Windows, so this is only reachable if the job
+// table is in an inconsistent state.
+func (j *job) resume() error { return errJobControlUnsupported }
+
+// Kills a process. Windows doesn't support asking a process to terminate.
+func terminateProcess(proc *os.Process, _ <-chan struct{}) {
+	proc.Kill()
+}
+
+// Same as terminateProcess, since processes of jobs are waited for with
+// os.Process.Wait on Windows.
+func (j *job) terminateProcess(proc *os.Process, exited <-chan struct{}) {
+	terminateProcess(proc, exited)
+}
@@ -0,0 +1,300 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+)
+
+// DefaultProfileInterval is the sampling interval used by NewProfiler when the
+// given interval is not positive.
+const DefaultProfileInterval = 10 * time.Millisecond
+
+// Profiler is a sampling profiler for Elvish code. It attributes time and call
+// counts to the forms and closures being run.
+//
+// Code is profiled when it's evaluated with [EvalCfg.Profiler] or called with
+// a Frame returned by [Frame.WithProfiler].
+type Profiler struct {
+	interval time.Duration
+	start    time.Time
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	mu sync.Mutex
+	// Fields below are guarded by mu.
+
+	// Interned locations and their indices.
+	locs     []profLoc
+	locIndex map[profLocKey]int
+	// The call tree, where each node is identified by an index into stacks.
+	stacks     []profStack
+	stackIndex map[profStackKey]int
+	// Nodes for forms and closures that are currently running.
+	active map[*profNode]struct{}
+}
+
+// A form or closure definition.
+type profLoc struct {
+	src     parse.Source
+	r       diag.Ranging
+	closure bool
+}
+
+type profLocKey struct {
+	name     string
+	from, to int
+	closure  bool
+}
+
+// A node in the call tree. The root of the tree has parent -1.
+type profStack struct {
+	parent, loc    int
+	samples, calls int64
+}
+
+type profStackKey struct{ parent, loc int }
+
+// A running form or closure, or the root of the code being profiled.
+type profNode struct {
+	p      *Profiler
+	parent *profNode
+	stack  int
+	// Number of children that are running. Guarded by p.mu.
+	children int
+}
+
+// NewProfiler creates a Profiler and starts sampling every interval. If the
+// interval is not positive, DefaultProfileInterval is used.
+func NewProfiler(interval time.Duration) *Profiler {
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+	p := &Profiler{
+		interval: interval, start: time.Now(),
+		stop: make(chan struct{}), stopped: make(chan struct{}),
+		locIndex:   make(map[profLocKey]int),
+		stackIndex: make(map[profStackKey]int),
+		active:     make(map[*profNode]struct{})}
+	go p.loop()
+	return p
+}
+
+func (p *Profiler) loop() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.sample()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Attributes a sample to every running node that has no running children.
+func (p *Profiler) sample() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for node := range p.active {
+		if node.children == 0 {
+			p.stacks[node.stack].samples++
+		}
+	}
+}
+
+func (p *Profiler) root() *profNode {
+	if p == nil {
+		return nil
+	}
+	return &profNode{p: p, stack: -1}
+}
+
+func (p *Profiler) enter(parent *profNode, src parse.Source, r diag.Ranging, closure bool) *profNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	locKey := profLocKey{src.Name, r.From, r.To, closure}
+	loc, ok := p.locIndex[locKey]
+	if !ok {
+		loc = len(p.locs)
+		p.locs = append(p.locs, profLoc{src, r, closure})
+		p.locIndex[locKey] = loc
+	}
+	stackKey := profStackKey{parent.stack, loc}
+	stack, ok := p.stackIndex[stackKey]
+	if !ok {
+		stack = len(p.stacks)
+		p.stacks = append(p.stacks, profStack{parent: parent.stack, loc: loc})
+		p.stackIndex[stackKey] = stack
+	}
+	p.stacks[stack].calls++
+	node := &profNode{p: p, parent: parent, stack: stack}
+	parent.children++
+	p.active[node] = struct{}{}
+	return node
+}
+
+func (p *Profiler) exit(node *profNode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	node.parent.children--
+	delete(p.active, node)
+}
+
+// Records fm as running the given form or closure, and returns a function to
+// call when it finishes. Must only be called when fm.prof is not nil, and fm
+// can be modified in place.
+func (fm *Frame) enterProfile(r diag.Ranging, closure bool) func() {
+	node := fm.prof.p.enter(fm.prof, fm.src, r, closure)
+	fm.prof = node
+	return func() { node.p.exit(node) }
+}
+
+// WithProfiler returns a fork of fm whose code is profiled with p. If p is nil,
+// the code is not profiled.
+func (fm *Frame) WithProfiler(p *Profiler) *Frame {
+	newFm := fm.Fork()
+	newFm.prof = p.root()
+	return newFm
+}
+
+// Stop stops sampling and returns the profile collected so far. It must be
+// called exactly once.
+func (p *Profiler) Stop() *Profile {
+	close(p.stop)
+	<-p.stopped
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	locs := make([]ProfileLocation, len(p.locs))
+	for i, loc := range p.locs {
+		locs[i] = ProfileLocation{diag.NewContext(loc.src.Name, loc.src.Code, loc.r), loc.closure}
+	}
+	stacks := make([]profStack, len(p.stacks))
+	copy(stacks, p.stacks)
+	return &Profile{p.interval, p.start, time.Since(p.start), locs, stacks}
+}
+
+// Profile contains the result of profiling.
+type Profile struct {
+	Interval time.Duration
+	Start    time.Time
+	Duration time.Duration
+	// All the forms and closures that have been run.
+	Locations []ProfileLocation
+
+	stacks []profStack
+}
+
+// ProfileLocation is a form or the definition of a closure.
+type ProfileLocation struct {
+	*diag.Context
+	Closure bool
+}
+
+// FuncName returns a name to identify the form or closure in profiles,
+// derived from the first line of its source.
+func (loc ProfileLocation) FuncName() string {
+	const maxLen = 40
+	line, _, multiline := strings.Cut(strings.TrimSpace(loc.Body), "\n")
+	if len(line) > maxLen {
+		line, multiline = line[:maxLen], true
+	}
+	if multiline {
+		line += "…"
+	}
+	if loc.Closure {
+		return "fn " + line
+	}
+	return line
+}
+
+// ProfileEntry summarizes the time spent in a location and how many times it
+// was run.
+type ProfileEntry struct {
+	Location ProfileLocation
+	// Time spent in the location itself, excluding time spent in the forms
+	// and closures it runs.
+	Flat time.Duration
+	// Time spent in the location, including time spent in the forms and
+	// closures it runs.
+	Cum   time.Duration
+	Calls int64
+}
+
+// Entries returns a summary of each location, sorted by flat time, cumulative
+// time and number of calls, all in descending order.
+func (p *Profile) Entries() []ProfileEntry {
+	flat := make([]int64, len(p.Locations))
+	cum := make([]int64, len(p.Locations))
+	calls := make([]int64, len(p.Locations))
+	for i, s := range p.stacks {
+		calls[s.loc] += s.calls
+		if s.samples == 0 {
+			continue
+		}
+		flat[s.loc] += s.samples
+		// Count each location at most once for each stack, so that recursive
+		// calls are not counted multiple times.
+		seen := make(map[int]bool)
+		for j := i; j != -1; j = p.stacks[j].parent {
+			if loc := p.stacks[j].loc; !seen[loc] {
+				seen[loc] = true
+				cum[loc] += s.samples
+			}
+		}
+	}
+	entries := make([]ProfileEntry, len(p.Locations))
+	for i, loc := range p.Locations {
+		entries[i] = ProfileEntry{loc,
+			time.Duration(flat[i]) * p.Interval, time.Duration(cum[i]) * p.Interval, calls[i]}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		return a.Calls > b.Calls
+	})
+	return entries
+}
+
+// WriteReport writes a human-readable report of the top n entries of the
+// profile. If n is not positive, all entries are written.
+func (p *Profile) WriteReport(w io.Writer, n int) error {
+	var total int64
+	for _, s := range p.stacks {
+		total += s.samples
+	}
+	entries := p.Entries()
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Duration: %v, sampled: %v (%d samples every %v)\n",
+		p.Duration.Round(time.Millisecond), time.Duration(total)*p.Interval, total, p.Interval)
+	fmt.Fprintf(&sb, "%9s %6s %9s %6s %8s  %s\n", "flat", "flat%", "cum", "cum%", "calls", "location")
+	percent := func(d time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(d) / float64(time.Duration(total)*p.Interval)
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%9v %5.1f%% %9v %5.1f%% %8d  %s (%s:%d:%d)\n",
+			e.Flat, percent(e.Flat), e.Cum, percent(e.Cum), e.Calls,
+			e.Location.FuncName(), e.Location.Name, e.Location.StartLine, e.Location.StartCol)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
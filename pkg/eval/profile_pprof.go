@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// WritePprof writes the profile in the gzip-compressed protobuf format used by
+// pprof (https://github.com/google/pprof/blob/main/proto/profile.proto).
+//
+// Each sample is a path in the call tree, with the number of samples, the
+// sampled time and the number of calls as values. Each form or closure is both
+// a location and a function.
+func (p *Profile) WritePprof(w io.Writer) error {
+	var b pprofBuilder
+	b.strings = map[string]int64{"": 0}
+	b.stringTable = []string{""}
+
+	for _, t := range [][2]string{
+		{"samples", "count"}, {"time", "nanoseconds"}, {"calls", "count"}} {
+		b.message(1, b.valueType(t[0], t[1]))
+	}
+	for i, s := range p.stacks {
+		var sample pprofEncoder
+		var locIDs pprofEncoder
+		for j := i; j != -1; j = p.stacks[j].parent {
+			locIDs.varint(uint64(p.stacks[j].loc + 1))
+		}
+		sample.bytes(1, locIDs.buf)
+		var values pprofEncoder
+		values.varint(uint64(s.samples))
+		values.varint(uint64(s.samples * int64(p.Interval)))
+		values.varint(uint64(s.calls))
+		sample.bytes(2, values.buf)
+		b.message(2, sample)
+	}
+	for i, loc := range p.Locations {
+		id := uint64(i + 1)
+		var line pprofEncoder
+		line.int(1, id)
+		line.int(2, uint64(loc.StartLine))
+		var location pprofEncoder
+		location.int(1, id)
+		location.bytes(4, line.buf)
+		b.message(4, location)
+
+		var function pprofEncoder
+		function.int(1, id)
+		function.int(2, uint64(b.str(loc.FuncName())))
+		function.int(4, uint64(b.str(loc.Name)))
+		function.int(5, uint64(loc.StartLine))
+		b.message(5, function)
+	}
+	b.int(9, uint64(p.Start.UnixNano()))
+	b.int(10, uint64(p.Duration))
+	b.message(11, b.valueType("time", "nanoseconds"))
+	b.int(12, uint64(p.Interval))
+	b.int(14, uint64(b.str("time")))
+	// The string table must be written last, after all strings are interned.
+	for _, s := range b.stringTable {
+		b.bytes(6, []byte(s))
+	}
+
+	gw := gzip.NewWriter(w)
+	if _, err := gw.Write(b.buf); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+type pprofBuilder struct {
+	pprofEncoder
+	strings     map[string]int64
+	stringTable []string
+}
+
+// Returns the index of s in the string table, adding it if necessary.
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.stringTable))
+	b.strings[s] = i
+	b.stringTable = append(b.stringTable, s)
+	return i
+}
+
+func (b *pprofBuilder) valueType(typ, unit string) pprofEncoder {
+	var e pprofEncoder
+	e.int(1, uint64(b.str(typ)))
+	e.int(2, uint64(b.str(unit)))
+	return e
+}
+
+// A minimal encoder of protobuf messages.
+type pprofEncoder struct{ buf []byte }
+
+func (e *pprofEncoder) varint(x uint64) {
+	e.buf = binary.AppendUvarint(e.buf, x)
+}
+
+// Writes an integer field. Zero values are omitted, like proto3 does.
+func (e *pprofEncoder) int(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.varint(uint64(field)<<3 | 0)
+	e.varint(x)
+}
+
+// Writes a length-delimited field.
+func (e *pprofEncoder) bytes(field int, data []byte) {
+	e.varint(uint64(field)<<3 | 2)
+	e.varint(uint64(len(data)))
+	e.buf = append(e.buf, data...)
+}
+
+func (e *pprofEncoder) message(field int, m pprofEncoder) {
+	e.bytes(field, m.buf)
+}
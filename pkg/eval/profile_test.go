@@ -0,0 +1,126 @@
+package eval_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	. "src.elv.sh/pkg/eval"
+
+	"src.elv.sh/pkg/parse"
+)
+
+var profiledCode = `fn f { sleep 0.05 }
+f
+f
+`
+
+func TestProfiler(t *testing.T) {
+	prof := profile(t, profiledCode)
+
+	calls := make(map[string]int64)
+	for _, e := range prof.Entries() {
+		calls[e.Location.FuncName()] += e.Calls
+	}
+	wantCalls := map[string]int64{"fn f { sleep 0.05 }": 1, "f": 2,
+		"fn { sleep 0.05 }": 2, "sleep 0.05": 2}
+	for name, want := range wantCalls {
+		if calls[name] != want {
+			t.Errorf("got %d calls to %q, want %d", calls[name], name, want)
+		}
+	}
+
+	top := prof.Entries()[0]
+	if top.Location.FuncName() != "sleep 0.05" || top.Flat == 0 {
+		t.Errorf("got top entry %q with flat time %v, want %q with positive flat time",
+			top.Location.FuncName(), top.Flat, "sleep 0.05")
+	}
+	if top.Location.StartLine != 1 || top.Location.StartCol != 8 {
+		t.Errorf("got top entry at %d:%d, want 1:8",
+			top.Location.StartLine, top.Location.StartCol)
+	}
+
+	var report strings.Builder
+	prof.WriteReport(&report, 1)
+	lines := strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[2], "sleep 0.05 ([test]:1:8)") {
+		t.Errorf("got report %q, want 3 lines ending with the top entry", report.String())
+	}
+}
+
+func TestProfiler_Recursion(t *testing.T) {
+	prof := profile(t, "fn f {|n| if (> $n 0) { f (- $n 1) } else { sleep 0.05 } }; f 3")
+	for _, e := range prof.Entries() {
+		if e.Cum > prof.Duration {
+			t.Errorf("%q has cumulative time %v, more than the duration %v",
+				e.Location.FuncName(), e.Cum, prof.Duration)
+		}
+	}
+}
+
+func TestProfile_WritePprof(t *testing.T) {
+	prof := profile(t, profiledCode)
+	var buf bytes.Buffer
+	if err := prof.WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof: %v", err)
+	}
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+
+	counts := make(map[uint64]int)
+	var stringTable []string
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		field, wireType := tag>>3, tag&7
+		switch wireType {
+		case 0:
+			_, n = binary.Uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if field == 6 {
+				stringTable = append(stringTable, string(data[n:n+int(size)]))
+			}
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+		counts[field]++
+	}
+	// The call tree has 7 nodes: the definition of f, and for each of the 2
+	// calls of f, the call, the closure and the form in its body.
+	if counts[2] != 7 {
+		t.Errorf("got %d samples, want 7", counts[2])
+	}
+	if counts[4] != 5 || counts[5] != 5 {
+		t.Errorf("got %d locations and %d functions, want 5 each", counts[4], counts[5])
+	}
+	for _, s := range []string{"", "time", "nanoseconds", "sleep 0.05", "[test]"} {
+		if !slices.Contains(stringTable, s) {
+			t.Errorf("string table %q doesn't contain %q", stringTable, s)
+		}
+	}
+}
+
+func profile(t *testing.T, code string) *Profile {
+	t.Helper()
+	p := NewProfiler(time.Millisecond)
+	err := NewEvaler().Eval(parse.Source{Name: "[test]", Code: code}, EvalCfg{Profiler: p})
+	prof := p.Stop()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	return prof
+}
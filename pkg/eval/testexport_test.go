@@ -8,6 +8,7 @@ var (
 	TimeAfter     = &timeAfter
 	TimeNow       = &timeNow
 	NextEvalCount = &nextEvalCount
+	TerminalWidth = &terminalWidth
 
 	ExceptionCauseStartMarker = &exceptionCauseStartMarker
 	ExceptionCauseEndMarker   = &exceptionCauseEndMarker
@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// TimeoutError is thrown when the code run by with-timeout or with-deadline
+// doesn't finish in time.
+//
+// It is also the cause of the cancellation of the Context the code runs with,
+// so blocking operations that are interrupted when the time is up, like
+// sleep, throw it too. Each call to with-timeout or with-deadline uses a
+// distinct TimeoutError, so nested timeouts can be told apart.
+type TimeoutError struct {
+	// Whether the error is from with-timeout rather than with-deadline.
+	HasTimeout bool
+	// The timeout passed to with-timeout.
+	Timeout time.Duration
+	// The time when the code is canceled.
+	Deadline time.Time
+}
+
+var _ vals.PseudoMap = (*TimeoutError)(nil)
+
+// Error returns a message describing the timeout or deadline.
+func (e *TimeoutError) Error() string {
+	if e.HasTimeout {
+		return "timed out after " + e.Timeout.String()
+	}
+	return "deadline " + e.Deadline.Format(time.RFC3339Nano) + " exceeded"
+}
+
+// Kind returns "timeout-error".
+func (*TimeoutError) Kind() string { return "timeout-error" }
+
+// Fields returns a structmap for accessing fields from Elvish.
+func (e *TimeoutError) Fields() vals.MethodMap { return timeoutFields{e} }
+
+type timeoutFields struct{ e *TimeoutError }
+
+func (timeoutFields) Type() string { return "timeout" }
+
+// Timeout returns the timeout in seconds, or nil for with-deadline.
+func (f timeoutFields) Timeout() any {
+	if !f.e.HasTimeout {
+		return nil
+	}
+	return f.e.Timeout.Seconds()
+}
+
+func (f timeoutFields) Deadline() string { return f.e.Deadline.Format(time.RFC3339Nano) }
+
+func withTimeout(fm *Frame, timeout any, f Callable) error {
+	d, ok := parseDuration(timeout)
+	if !ok || d < 0 {
+		return errs.BadValue{What: "timeout",
+			Valid:  "non-negative number of seconds or duration string",
+			Actual: vals.ReprPlain(timeout)}
+	}
+	return runWithDeadline(fm, &TimeoutError{HasTimeout: true, Timeout: d, Deadline: timeNow().Add(d)}, f)
+}
+
+func withDeadline(fm *Frame, deadline any, f Callable) error {
+	t, ok := parseDeadline(deadline)
+	if !ok {
+		return errs.BadValue{What: "deadline",
+			Valid:  "number of seconds since the Unix epoch or RFC 3339 timestamp",
+			Actual: vals.ReprPlain(deadline)}
+	}
+	return runWithDeadline(fm, &TimeoutError{Deadline: t}, f)
+}
+
+// Parses a deadline, which is either a number of seconds since the Unix epoch,
+// an RFC 3339 timestamp string, or a timestamp value from the time: module.
+func parseDeadline(v any) (time.Time, bool) {
+	if t, ok := v.(interface{ GoTime() time.Time }); ok {
+		return t.GoTime(), true
+	}
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return time.Time{}, false
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), true
+	}
+	if s, ok := v.(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// Calls f with a Context that is canceled with cause at cause.Deadline.
+//
+// If the deadline has been reached when f returns, cause is thrown, unless f
+// has thrown an exception with cause as the reason. This makes sure that a
+// timeout is reported as such, even if f has thrown a different exception as a
+// result, like an external command terminated by a signal.
+func runWithDeadline(fm *Frame, cause *TimeoutError, f Callable) error {
+	ctx, cancel := context.WithDeadlineCause(fm.ctx, cause.Deadline, cause)
+	defer cancel()
+	newFm := fm.Fork()
+	newFm.ctx = ctx
+	err := f.Call(newFm, NoArgs, NoOpts)
+	if context.Cause(ctx) != error(cause) {
+		return err
+	}
+	if exc, ok := err.(Exception); ok && exc.Reason() == error(cause) {
+		return err
+	}
+	return cause
+}
@@ -0,0 +1,37 @@
+package eval_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "src.elv.sh/pkg/eval"
+
+	"src.elv.sh/pkg/parse"
+)
+
+func TestWithTimeout_InterruptsValueReads(t *testing.T) {
+	// Nothing is ever written to the input port.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	in := &Port{File: r, Chan: make(chan any)}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewEvaler().Eval(
+			parse.Source{Name: "[test]", Code: "with-timeout 0.01 { each {|x| } }"},
+			EvalCfg{Ports: []*Port{in, DummyOutputPort, DummyOutputPort}})
+	}()
+	select {
+	case err := <-errCh:
+		if _, ok := Reason(err).(*TimeoutError); !ok {
+			t.Errorf("got error %v, want *TimeoutError", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("each is not interrupted by the timeout")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
@@ -136,6 +137,10 @@ func TestTranscripts(t *testing.T) {
 				return time.Unix(v, 0)
 			})
 		},
+		"mock-terminal-width", func(t *testing.T, arg string) {
+			width := must.OK1(strconv.Atoi(arg))
+			testutil.Set(t, eval.TerminalWidth, func(*os.File) int { return width })
+		},
 		"inject-time-after-with-sigint-or-skip", injectTimeAfterWithSIGINTOrSkip,
 		"mock-getwd-error", func(t *testing.T, msg string) {
 			err := errors.New(msg)
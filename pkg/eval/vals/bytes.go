@@ -0,0 +1,81 @@
+package vals
+
+import (
+	"src.elv.sh/pkg/parse"
+)
+
+// Bytes is a sequence of bytes that is not assumed to be text.
+//
+// Unlike strings, the length of a Bytes value is the number of bytes, indexing
+// it with an integer yields the byte at that position as a number, and slicing
+// it is allowed at any position. Iterating over a Bytes value yields the bytes
+// as numbers.
+//
+// Converting a Bytes value to a string with [ToString] yields the bytes
+// unmodified, so writing it to a byte output is lossless.
+type Bytes string
+
+var _ interface {
+	Kinder
+	Lener
+	ErrIndexer
+	Iterator
+	Concatter
+	RConcatter
+	Stringer
+	Reprer
+} = Bytes("")
+
+func (Bytes) Kind() string { return "bytes" }
+
+func (b Bytes) Len() int { return len(b) }
+
+// Index supports integer indices, which yield the byte at the index as a
+// number, and slice indices, which yield another Bytes value.
+func (b Bytes) Index(k any) (any, error) {
+	index, err := ConvertListIndex(k, len(b))
+	if err != nil {
+		return nil, err
+	}
+	if index.Slice {
+		return b[index.Lower:index.Upper], nil
+	}
+	return int(b[index.Lower]), nil
+}
+
+// Iterate calls f with each byte as a number.
+func (b Bytes) Iterate(f func(any) bool) {
+	for i := 0; i < len(b); i++ {
+		if !f(int(b[i])) {
+			break
+		}
+	}
+}
+
+// Concat concatenates b with another Bytes value or a string, which
+// contributes its bytes. The result is a Bytes value.
+func (b Bytes) Concat(v any) (any, error) {
+	switch v := v.(type) {
+	case Bytes:
+		return b + v, nil
+	case string:
+		return b + Bytes(v), nil
+	}
+	return nil, ErrConcatNotImplemented
+}
+
+// RConcat concatenates a string with b. The result is a Bytes value.
+func (b Bytes) RConcat(v any) (any, error) {
+	if v, ok := v.(string); ok {
+		return Bytes(v) + b, nil
+	}
+	return nil, ErrConcatNotImplemented
+}
+
+// String returns the bytes unmodified.
+func (b Bytes) String() string { return string(b) }
+
+// Repr returns a representation like (to-bytes "\x89PNG").
+func (b Bytes) Repr(int) string {
+	return "(to-bytes " + parse.Quote(string(b)) + ")"
+}
@@ -0,0 +1,45 @@
+package vals
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/tt"
+)
+
+func TestBytes(t *testing.T) {
+	TestValue(t, Bytes("a\xffb")).
+		Kind("bytes").
+		Repr(`(to-bytes "a\xffb")`).
+		Len(3).
+		Equal(Bytes("a\xffb")).
+		NotEqual("a\xffb", Bytes("ab")).
+		Hash(Hash("a\xffb")).
+		Index("1", 0xff).
+		Index("1..", Bytes("\xffb")).
+		Index("..0", Bytes("")).
+		IndexError("3", errs.OutOfRange{What: "index",
+			ValidLow: "0", ValidHigh: "2", Actual: "3"})
+}
+
+func TestBytes_Iterate(t *testing.T) {
+	tt.Test(t, Collect,
+		Args(Bytes("a\xff")).Rets([]any{int('a'), 0xff}, nil),
+		Args(Bytes("")).Rets([]any{}, nil),
+	)
+}
+
+func TestBytes_Concat(t *testing.T) {
+	tt.Test(t, Concat,
+		Args(Bytes("a"), Bytes("b")).Rets(Bytes("ab"), nil),
+		Args(Bytes("a"), "b").Rets(Bytes("ab"), nil),
+		Args("a", Bytes("b")).Rets(Bytes("ab"), nil),
+		Args(Bytes("a"), 1).Rets(nil, cannotConcat{"bytes", "number"}),
+	)
+}
+
+func TestBytes_ToString(t *testing.T) {
+	tt.Test(t, ToString,
+		Args(Bytes("a\xff")).Rets("a\xff"),
+	)
+}
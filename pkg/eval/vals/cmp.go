@@ -17,6 +17,14 @@ const (
 	CmpUncomparable
 )
 
+// Comparer wraps the Compare method.
+type Comparer interface {
+	// Compare compares the receiver to another value. It should return
+	// [CmpUncomparable] if the other value can't be compared with the
+	// receiver, and [CmpEqual] iff the two values are equal.
+	Compare(other any) Ordering
+}
+
 // Cmp compares two Elvish values and returns the ordering relationship between
 // them. Cmp(a, b) returns CmpEqual iff Equal(a, b) is true or both a and b are
 // NaNs.
@@ -64,6 +72,10 @@ func cmpInner(a, b any, recurse func(a, b any) Ordering) Ordering {
 		if b, ok := b.(string); ok {
 			return compareBuiltin(a, b)
 		}
+	case Bytes:
+		if b, ok := b.(Bytes); ok {
+			return compareBuiltin(string(a), string(b))
+		}
 	case List:
 		if b, ok := b.(List); ok {
 			aIt := a.Iterator()
@@ -85,6 +97,8 @@ func cmpInner(a, b any, recurse func(a, b any) Ordering) Ordering {
 				return CmpMore
 			}
 		}
+	case Comparer:
+		return a.Compare(b)
 	default:
 		if Equal(a, b) {
 			return CmpEqual
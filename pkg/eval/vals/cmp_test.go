@@ -10,6 +10,17 @@ func TestCmp(t *testing.T) {
 	// Cmp is tested by tests of the Elvish compare command.
 }
 
+type customComparer struct{ ret Ordering }
+
+func (c customComparer) Compare(any) Ordering { return c.ret }
+
+func TestCmp_Comparer(t *testing.T) {
+	tt.Test(t, Cmp,
+		tt.Args(customComparer{CmpLess}, 2).Rets(CmpLess),
+		tt.Args(customComparer{CmpUncomparable}, 2).Rets(CmpUncomparable),
+	)
+}
+
 func TestCmpTotal_FieldMap(t *testing.T) {
 	// CmpTotal should pretend that field maps are maps too. Since maps don't
 	// have an internal ordering, comparing a field map to another field map or
@@ -40,6 +40,8 @@ func Equal(x, y any) bool {
 		return x == y
 	case string:
 		return x == y
+	case Bytes:
+		return x == y
 	case List:
 		if yy, ok := y.(List); ok {
 			return equalList(x, yy)
@@ -40,6 +40,8 @@ func Hash(v any) uint32 {
 		return hash.UInt64(math.Float64bits(v))
 	case string:
 		return hash.String(v)
+	case Bytes:
+		return hash.String(string(v))
 	case Hasher:
 		return v.Hash()
 	case File:
@@ -7,15 +7,26 @@ type Iterator interface {
 	Iterate(func(v any) bool)
 }
 
+// ErrIterator is like Iterator, but its Iterate method can return an error.
+// This is useful for values that compute their elements lazily, where
+// computing an element can fail.
+type ErrIterator interface {
+	// Iterate calls the passed function with each value within the receiver.
+	// The iteration is aborted if the function returns false. It returns any
+	// error encountered when computing the values.
+	Iterate(func(v any) bool) error
+}
+
 type cannotIterate struct{ kind string }
 
 func (err cannotIterate) Error() string { return "cannot iterate " + err.kind }
 
 // CanIterate returns whether the value can be iterated. If CanIterate(v) is
-// true, calling Iterate(v, f) will not result in an error.
+// true, calling Iterate(v, f) will not result in an error, unless v implements
+// ErrIterator and its Iterate method returns one.
 func CanIterate(v any) bool {
 	switch v.(type) {
-	case Iterator, string, List:
+	case Iterator, ErrIterator, string, List:
 		return true
 	}
 	return false
@@ -24,8 +35,10 @@ func CanIterate(v any) bool {
 // Iterate iterates the supplied value, and calls the supplied function in each
 // of its elements. The function can return false to break the iteration. It is
 // implemented for the builtin type string, the List type, and types satisfying
-// the Iterator interface. For these types, it always returns a nil error. For
-// other types, it doesn't do anything and returns an error.
+// the Iterator or ErrIterator interface. For types satisfying ErrIterator, it
+// returns the error from its Iterate method; for the other types, it always
+// returns a nil error. For other types, it doesn't do anything and returns an
+// error.
 func Iterate(v any, f func(any) bool) error {
 	switch v := v.(type) {
 	case string:
@@ -43,6 +56,8 @@ func Iterate(v any, f func(any) bool) error {
 		}
 	case Iterator:
 		v.Iterate(f)
+	case ErrIterator:
+		return v.Iterate(f)
 	default:
 		return cannotIterate{Kind(v)}
 	}
@@ -1,6 +1,7 @@
 package vals
 
 import (
+	"errors"
 	"testing"
 
 	"src.elv.sh/pkg/tt"
@@ -13,6 +14,19 @@ func (i iterator) Iterate(f func(any) bool) {
 	Feed(f, i.elements...)
 }
 
+// An implementation of ErrIterator that fails after outputting its elements.
+type errIterator struct {
+	elements []any
+	err      error
+}
+
+func (i errIterator) Iterate(f func(any) bool) error {
+	Feed(f, i.elements...)
+	return i.err
+}
+
+var errIteration = errors.New("iteration error")
+
 // A non-implementation of Iterator.
 type nonIterator struct{}
 
@@ -21,6 +35,7 @@ func TestCanIterate(t *testing.T) {
 		Args("foo").Rets(true),
 		Args(MakeList("foo", "bar")).Rets(true),
 		Args(iterator{vs("a", "b")}).Rets(true),
+		Args(errIterator{vs("a", "b"), nil}).Rets(true),
 		Args(nonIterator{}).Rets(false),
 	)
 }
@@ -30,6 +45,8 @@ func TestCollect(t *testing.T) {
 		Args("foo").Rets(vs("f", "o", "o"), nil),
 		Args(MakeList("foo", "bar")).Rets(vs("foo", "bar"), nil),
 		Args(iterator{vs("a", "b")}).Rets(vs("a", "b"), nil),
+		Args(errIterator{vs("a", "b"), nil}).Rets(vs("a", "b"), nil),
+		Args(errIterator{vs("a"), errIteration}).Rets(vs("a"), errIteration),
 		Args(nonIterator{}).Rets(vs(), cannotIterate{"!!vals.nonIterator"}),
 	)
 }
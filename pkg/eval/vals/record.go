@@ -0,0 +1,216 @@
+package vals
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// RecordKind describes a user-defined kind of records, map-like values with a
+// fixed list of fields.
+//
+// Records behave like field maps: the field names are the keys, and the field
+// values are the values. Unlike field maps, records report the name of their
+// kind from [Kind], and only have the fields declared by their kind; trying to
+// construct or [Assoc] a record with an unknown field is an error.
+type RecordKind struct {
+	name   string
+	fields []string
+	// Default values of fields, indexed by field position. Fields without
+	// default values have nil entries in this slice and false entries in
+	// hasDefault.
+	defaults   []any
+	hasDefault []bool
+}
+
+// NewRecordKind creates a new record kind with the given name and fields. The
+// defaults map may contain default values for some of the fields.
+func NewRecordKind(name string, fields []string, defaults map[string]any) (*RecordKind, error) {
+	k := &RecordKind{name, fields,
+		make([]any, len(fields)), make([]bool, len(fields))}
+	for i, field := range fields {
+		if field == "" {
+			return nil, fmt.Errorf("field name must not be empty")
+		}
+		if k.fieldIndex(field) != i {
+			return nil, fmt.Errorf("duplicate field %s", field)
+		}
+	}
+	for field, v := range defaults {
+		i := k.fieldIndex(field)
+		if i == -1 {
+			return nil, fmt.Errorf("default value for unknown field %s", field)
+		}
+		k.defaults[i], k.hasDefault[i] = v, true
+	}
+	return k, nil
+}
+
+// Name returns the name of the record kind.
+func (k *RecordKind) Name() string { return k.name }
+
+// Fields returns the field names of the record kind. The caller must not
+// modify the returned slice.
+func (k *RecordKind) Fields() []string { return k.fields }
+
+func (k *RecordKind) fieldIndex(field string) int {
+	for i, f := range k.fields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// New constructs a record from field values. Fields with default values may be
+// omitted.
+func (k *RecordKind) New(values map[string]any) (*Record, error) {
+	r := &Record{k, make([]any, len(k.fields))}
+	for field, v := range values {
+		i := k.fieldIndex(field)
+		if i == -1 {
+			return nil, unknownField{k.name, field}
+		}
+		r.values[i] = v
+	}
+	for i, field := range k.fields {
+		if _, ok := values[field]; !ok {
+			if !k.hasDefault[i] {
+				return nil, fmt.Errorf("missing field %s of %s", field, k.name)
+			}
+			r.values[i] = k.defaults[i]
+		}
+	}
+	return r, nil
+}
+
+type unknownField struct {
+	kind  string
+	field any
+}
+
+func (err unknownField) Error() string {
+	return fmt.Sprintf("%s has no field %s", err.kind, ReprPlain(err.field))
+}
+
+// Record is an instance of a [RecordKind].
+type Record struct {
+	kind   *RecordKind
+	values []any
+}
+
+var _ interface {
+	Kinder
+	Indexer
+	HasKeyer
+	KeysIterator
+	Lener
+	Assocer
+	Dissocer
+	Equaler
+	Hasher
+	Reprer
+} = (*Record)(nil)
+
+// RecordKind returns the kind of the record.
+func (r *Record) RecordKind() *RecordKind { return r.kind }
+
+func (r *Record) Kind() string { return r.kind.name }
+
+func (r *Record) index(k any) int {
+	if field, ok := k.(string); ok {
+		return r.kind.fieldIndex(field)
+	}
+	return -1
+}
+
+func (r *Record) Index(k any) (any, bool) {
+	if i := r.index(k); i != -1 {
+		return r.values[i], true
+	}
+	return nil, false
+}
+
+func (r *Record) HasKey(k any) bool { return r.index(k) != -1 }
+
+func (r *Record) IterateKeys(f func(any) bool) {
+	iterateKeysFieldOrMethodMap(r.kind.fields, f)
+}
+
+func (r *Record) Len() int { return len(r.values) }
+
+// Assoc returns a copy of the record with a field changed. It is an error if
+// the record doesn't have the field.
+func (r *Record) Assoc(k, v any) (any, error) {
+	i := r.index(k)
+	if i == -1 {
+		return nil, unknownField{r.kind.name, k}
+	}
+	values := append([]any(nil), r.values...)
+	values[i] = v
+	return &Record{r.kind, values}, nil
+}
+
+// Dissoc returns nil, since fields of a record can't be removed.
+func (r *Record) Dissoc(any) any { return nil }
+
+// Equal returns whether other is a record of the same kind with equal field
+// values. A record is never equal to a map.
+func (r *Record) Equal(other any) bool {
+	r2, ok := other.(*Record)
+	if !ok || r.kind != r2.kind {
+		return false
+	}
+	for i, v := range r.values {
+		if !Equal(v, r2.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Record) Hash() uint32 {
+	h := hash.DJBCombine(hash.DJBInit, hash.String(r.kind.name))
+	for _, v := range r.values {
+		h = hash.DJBCombine(h, Hash(v))
+	}
+	return h
+}
+
+// Repr returns a representation like a map with a tag, like
+// [^point &x=(num 1) &y=(num 2)]. The fields are written in the order they are
+// declared.
+func (r *Record) Repr(indent int) string {
+	builder := NewMapReprBuilder(indent)
+	for i, field := range r.kind.fields {
+		builder.WritePair(Repr(field, indent+1), indent+2, Repr(r.values[i], indent+2))
+	}
+	return "[^" + r.kind.name + " " + builder.String()[1:]
+}
+
+// MarshalJSON encodes the record as a JSON object, with the fields in the order
+// they are declared.
+func (r *Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r.kind.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kBytes, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		vBytes, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kBytes)
+		buf.WriteByte(':')
+		buf.Write(vBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
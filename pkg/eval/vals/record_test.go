@@ -0,0 +1,86 @@
+package vals
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var pointKind = mustNewRecordKind("point", []string{"x", "y"}, map[string]any{"y": "0"})
+
+func mustNewRecordKind(name string, fields []string, defaults map[string]any) *RecordKind {
+	k, err := NewRecordKind(name, fields, defaults)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func mustNewRecord(k *RecordKind, values map[string]any) *Record {
+	r, err := k.New(values)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestRecord(t *testing.T) {
+	r := mustNewRecord(pointKind, map[string]any{"x": "1"})
+	TestValue(t, r).
+		Kind("point").
+		Repr("[^point &x=1 &y=0]").
+		Len(2).
+		Equal(mustNewRecord(pointKind, map[string]any{"x": "1", "y": "0"})).
+		NotEqual(
+			mustNewRecord(pointKind, map[string]any{"x": "2"}),
+			// Records are nominally typed, so they are not equal to maps or
+			// records of another kind with the same pairs.
+			MakeMap("x", "1", "y", "0"),
+			mustNewRecord(mustNewRecordKind("point", []string{"x", "y"}, nil),
+				map[string]any{"x": "1", "y": "0"}),
+		).
+		HasKey("x", "y").
+		HasNoKey("z", 1.0).
+		AllKeys("x", "y").
+		Index("x", "1").
+		Index("y", "0").
+		IndexError("z", NoSuchKey("z")).
+		Assoc("y", "2", mustNewRecord(pointKind, map[string]any{"x": "1", "y": "2"})).
+		AssocError("z", "2", unknownField{"point", "z"})
+
+	if Dissoc(r, "x") != nil {
+		t.Errorf("Dissoc on record should return nil")
+	}
+	if Hash(r) != Hash(mustNewRecord(pointKind, map[string]any{"x": "1"})) {
+		t.Errorf("equal records have different hashes")
+	}
+}
+
+func TestRecord_MarshalJSON(t *testing.T) {
+	r := mustNewRecord(pointKind, map[string]any{"x": "1"})
+	tt.Test(t, tt.Fn(json.Marshal).Named("json.Marshal"),
+		Args(r).Rets([]byte(`{"x":"1","y":"0"}`), nil),
+	)
+}
+
+func TestNewRecordKind_Errors(t *testing.T) {
+	tt.Test(t, NewRecordKind,
+		Args("k", []string{""}, map[string]any(nil)).
+			Rets((*RecordKind)(nil), errors.New("field name must not be empty")),
+		Args("k", []string{"x", "x"}, map[string]any(nil)).
+			Rets((*RecordKind)(nil), errors.New("duplicate field x")),
+		Args("k", []string{"x"}, map[string]any{"y": "0"}).
+			Rets((*RecordKind)(nil), errors.New("default value for unknown field y")),
+	)
+}
+
+func TestRecordKind_New_Errors(t *testing.T) {
+	tt.Test(t, (*RecordKind).New,
+		Args(pointKind, map[string]any{"y": "0"}).
+			Rets((*Record)(nil), errors.New("missing field x of point")),
+		Args(pointKind, map[string]any{"x": "0", "z": "0"}).
+			Rets((*Record)(nil), unknownField{"point", "z"}),
+	)
+}
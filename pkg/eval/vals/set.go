@@ -0,0 +1,125 @@
+package vals
+
+import (
+	"encoding/json"
+	"sort"
+
+	"src.elv.sh/pkg/persistent/hashmap"
+)
+
+// Set is an immutable set of values, backed by a persistent hash map whose keys
+// are the elements. Elements are compared with [Equal] and hashed with [Hash].
+//
+// The zero value is not a valid set; use [EmptySet] or [MakeSet] instead.
+type Set struct{ m hashmap.Map }
+
+// EmptySet is an empty set.
+var EmptySet = Set{EmptyMap}
+
+// MakeSet creates a set from the arguments.
+func MakeSet(elems ...any) Set {
+	s := EmptySet
+	for _, elem := range elems {
+		s = s.Add(elem)
+	}
+	return s
+}
+
+var _ interface {
+	Kinder
+	Lener
+	Iterator
+	HasKeyer
+	Equaler
+	Hasher
+	Reprer
+} = Set{}
+
+// Add returns a set with v added.
+func (s Set) Add(v any) Set { return Set{s.m.Assoc(v, nil)} }
+
+// Remove returns a set with v removed.
+func (s Set) Remove(v any) Set { return Set{s.m.Dissoc(v)} }
+
+// Has returns whether v is an element of the set.
+func (s Set) Has(v any) bool {
+	_, ok := s.m.Index(v)
+	return ok
+}
+
+func (Set) Kind() string { return "set" }
+
+func (s Set) Len() int { return s.m.Len() }
+
+// Iterate calls f with each element of the set. The order is unspecified.
+func (s Set) Iterate(f func(any) bool) {
+	for it := s.m.Iterator(); it.HasElem(); it.Next() {
+		k, _ := it.Elem()
+		if !f(k) {
+			break
+		}
+	}
+}
+
+// HasKey returns whether v is an element of the set.
+func (s Set) HasKey(v any) bool { return s.Has(v) }
+
+// IsSubset returns whether every element of s is also an element of other.
+func (s Set) IsSubset(other Set) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	subset := true
+	s.Iterate(func(v any) bool {
+		subset = other.Has(v)
+		return subset
+	})
+	return subset
+}
+
+func (s Set) Equal(other any) bool {
+	s2, ok := other.(Set)
+	return ok && s.Len() == s2.Len() && s.IsSubset(s2)
+}
+
+func (s Set) Hash() uint32 {
+	// Like hashMap, combine the hashes by summing so that the iteration order
+	// doesn't matter.
+	var h uint32
+	s.Iterate(func(v any) bool {
+		h += Hash(v)
+		return true
+	})
+	return h
+}
+
+// Returns the elements of the set, sorted with CmpTotal.
+func (s Set) sorted() []any {
+	elems := make([]any, 0, s.Len())
+	s.Iterate(func(v any) bool {
+		elems = append(elems, v)
+		return true
+	})
+	sort.Slice(elems, func(i, j int) bool {
+		return CmpTotal(elems[i], elems[j]) == CmpLess
+	})
+	return elems
+}
+
+// Repr returns a representation like a list with a tag, like [^set a b c]. The
+// elements are sorted.
+func (s Set) Repr(indent int) string {
+	if s.Len() == 0 {
+		return "[^set]"
+	}
+	b := NewListReprBuilder(indent)
+	for _, v := range s.sorted() {
+		b.WriteElem(Repr(v, indent+1))
+	}
+	return "[^set " + b.String()[1:]
+}
+
+// MarshalJSON encodes the set as a JSON array of the sorted elements.
+func (s Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.sorted())
+}
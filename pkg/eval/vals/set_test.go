@@ -0,0 +1,64 @@
+package vals
+
+import (
+	"encoding/json"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+func TestSet(t *testing.T) {
+	TestValue(t, MakeSet("b", "a", 1)).
+		Kind("set").
+		Repr("[^set a b (num 1)]").
+		Len(3).
+		Equal(MakeSet(1, "a", "b", "a")).
+		NotEqual(
+			MakeSet("a", "b"),
+			MakeSet("a", "b", 1, 2),
+			MakeList(1, "a", "b"),
+			MakeMap(1, nil, "a", nil, "b", nil),
+		).
+		Hash(Hash(1)+Hash("a")+Hash("b")).
+		HasKey(1, "a", "b").
+		HasNoKey("c", 2)
+
+	TestValue(t, EmptySet).Repr("[^set]").Len(0)
+}
+
+func TestSet_AddRemove(t *testing.T) {
+	s := MakeSet("a")
+	if !Equal(s.Add("b"), MakeSet("a", "b")) {
+		t.Errorf("Add didn't add element")
+	}
+	if !Equal(s.Remove("a"), EmptySet) {
+		t.Errorf("Remove didn't remove element")
+	}
+	if !Equal(s, MakeSet("a")) {
+		t.Errorf("Add or Remove modified the original set")
+	}
+}
+
+func TestSet_Iterate(t *testing.T) {
+	elems, err := Collect(MakeSet("a", "b"))
+	if err != nil || len(elems) != 2 || !MakeSet(elems...).Equal(MakeSet("a", "b")) {
+		t.Errorf("Collect returns %v, %v", elems, err)
+	}
+}
+
+func TestSet_IsSubset(t *testing.T) {
+	tt.Test(t, Set.IsSubset,
+		Args(EmptySet, MakeSet("a")).Rets(true),
+		Args(MakeSet("a"), MakeSet("a", "b")).Rets(true),
+		Args(MakeSet("a", "b"), MakeSet("a", "b")).Rets(true),
+		Args(MakeSet("a", "c"), MakeSet("a", "b")).Rets(false),
+		Args(MakeSet("a", "b"), MakeSet("a")).Rets(false),
+	)
+}
+
+func TestSet_MarshalJSON(t *testing.T) {
+	tt.Test(t, tt.Fn(json.Marshal).Named("json.Marshal"),
+		Args(MakeSet("b", "a")).Rets([]byte(`["a","b"]`), nil),
+		Args(EmptySet).Rets([]byte(`[]`), nil),
+	)
+}
@@ -154,13 +154,18 @@ func derefBase(fm *Frame, ref *varRef) (vars.Var, []string) {
 }
 
 func (cp *compiler) searchLocal(k string) (staticVarInfo, int) {
-	return cp.thisScope().lookup(k)
+	info, index := cp.thisScope().lookup(k)
+	if index != -1 {
+		cp.markUsed(cp.thisScope(), index)
+	}
+	return info, index
 }
 
 func (cp *compiler) searchCapture(k string) (staticVarInfo, int) {
 	for i := len(cp.scopes) - 2; i >= 0; i-- {
 		info, index := cp.scopes[i].lookup(k)
 		if index != -1 {
+			cp.markUsed(cp.scopes[i], index)
 			// Record the capture from i+1 to len(cp.scopes)-1, and reuse the
 			// index to keep the index into the previous scope.
 			index = cp.captures[i+1].add(k, true, index)
@@ -0,0 +1,666 @@
+// Package format implements the canonical formatter of Elvish code, and the
+// -fmt subprogram that exposes it.
+//
+// The formatter works on the parse tree, so it has access to all the
+// whitespaces and comments in the source code. Most whitespaces are
+// normalized, subject to the following rules:
+//
+//   - Indentation uses 2 spaces per level.
+//
+//   - Elements on the same line are separated by one space. Pipelines on the
+//     same line are separated by "; ".
+//
+//   - Line breaks in the source code are kept where they are significant (like
+//     between pipelines) or used for layout (like between elements of a list,
+//     or after a line continuation). Runs of empty lines are collapsed into
+//     one, and empty lines at the beginning and end of a block are removed.
+//
+//   - A lambda, output capture, list or map that spans multiple lines has its
+//     content indented on its own lines, with the closing delimiter on a line
+//     of its own.
+//
+//   - Comments are kept, either at the end of the line they are on or on lines
+//     of their own.
+//
+// String literals and the bodies of here-documents are kept verbatim.
+package format
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+// ErrChangesMeaning is returned by [Format] when the formatted code doesn't
+// parse to the same syntax tree as the original code. This is always a bug in
+// the formatter.
+var ErrChangesMeaning = errors.New("internal error: formatting changes the meaning of the code")
+
+// Format formats a parse tree, which must not contain parse errors.
+func Format(tree parse.Tree) (string, error) {
+	p := &printer{bol: true}
+	p.lines(tokensOf(parse.Children(tree.Root)), "; ", p.node)
+	if p.sb.Len() > 0 {
+		p.newline()
+	}
+	formatted := p.sb.String()
+
+	newTree, err := parse.Parse(parse.Source{Name: tree.Source.Name, Code: formatted}, parse.Config{})
+	if err != nil || !sameAST(tree.Root, newTree.Root) {
+		return "", ErrChangesMeaning
+	}
+	return formatted, nil
+}
+
+const indentUnit = "  "
+
+type printer struct {
+	sb     strings.Builder
+	indent int
+	// Whether nothing has been written on the current line.
+	bol bool
+	// Here-documents whose bodies are to be written after the next line break.
+	hereDocs []*parse.HereDoc
+}
+
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	if p.bol {
+		p.sb.WriteString(strings.Repeat(indentUnit, p.indent))
+		p.bol = false
+	}
+	p.sb.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.sb.WriteByte('\n')
+	p.bol = true
+	for _, hn := range p.hereDocs {
+		body := parse.SourceText(hn)
+		p.sb.WriteString(body)
+		if !strings.HasSuffix(body, "\n") {
+			p.sb.WriteByte('\n')
+		}
+	}
+	p.hereDocs = nil
+}
+
+// Writes one line break, or two if n > 1 to keep an empty line.
+func (p *printer) newlines(n int) {
+	p.newline()
+	if n > 1 {
+		p.newline()
+	}
+}
+
+type tokenType int
+
+const (
+	nodeToken tokenType = iota
+	newlineToken
+	commentToken
+	continuationToken
+)
+
+// A token is either a non-separator node, or something significant found in
+// separators. Whitespaces and punctuations in separators are not represented as
+// tokens; they are generated from the structure of the syntax tree.
+type token struct {
+	typ  tokenType
+	node parse.Node
+	text string
+}
+
+func tokensOf(nodes []parse.Node) []token {
+	var toks []token
+	for _, n := range nodes {
+		switch n.(type) {
+		case *parse.Sep:
+			toks = append(toks, scanSep(parse.SourceText(n))...)
+		case *parse.HereDoc:
+			// Written by redir and newline.
+		default:
+			toks = append(toks, token{typ: nodeToken, node: n})
+		}
+	}
+	return toks
+}
+
+func scanSep(s string) []token {
+	var toks []token
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			toks = append(toks, token{typ: newlineToken})
+		case '#':
+			j := strings.IndexAny(s[i:], "\r\n")
+			if j == -1 {
+				j = len(s) - i
+			}
+			toks = append(toks, token{typ: commentToken, text: s[i : i+j]})
+			i += j - 1
+		case '^':
+			if i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r') {
+				toks = append(toks, token{typ: continuationToken})
+				if s[i+1] == '\r' && i+2 < len(s) && s[i+2] == '\n' {
+					i++
+				}
+				i++
+			}
+		}
+	}
+	return toks
+}
+
+// Reports whether the tokens need to be laid out on multiple lines.
+func multiline(toks []token) bool {
+	hasNode, hasNewline := false, false
+	for _, t := range toks {
+		switch t.typ {
+		case nodeToken:
+			hasNode = true
+		case newlineToken, continuationToken:
+			hasNewline = true
+		case commentToken:
+			return true
+		}
+	}
+	return hasNode && hasNewline
+}
+
+func hasNode(toks []token) bool {
+	for _, t := range toks {
+		if t.typ == nodeToken {
+			return true
+		}
+	}
+	return false
+}
+
+// Prints the tokens, keeping line breaks between nodes and separating nodes on
+// the same line with sep. Leading and trailing line breaks are dropped.
+func (p *printer) lines(toks []token, sep string, item func(parse.Node)) {
+	started := false
+	nl := 0
+	for _, t := range toks {
+		switch t.typ {
+		case nodeToken:
+			if started {
+				if nl > 0 {
+					p.newlines(nl)
+				} else {
+					p.write(sep)
+				}
+			}
+			item(t.node)
+			started, nl = true, 0
+		case newlineToken, continuationToken:
+			nl++
+		case commentToken:
+			if started && nl == 0 {
+				p.write(" " + t.text)
+			} else {
+				if started {
+					p.newlines(nl)
+				}
+				p.write(t.text)
+				started, nl = true, 0
+			}
+		}
+	}
+}
+
+// Prints the tokens inside a pair of delimiters, either on one line or with the
+// content indented on its own lines.
+func (p *printer) block(open, close, pad string, toks []token, item func(parse.Node)) {
+	p.write(open)
+	if multiline(toks) {
+		p.indent++
+		p.newline()
+		p.lines(toks, pad, item)
+		p.indent--
+		p.newline()
+	} else if hasNode(toks) {
+		p.lines(toks, pad, item)
+	}
+	p.write(close)
+}
+
+func (p *printer) pipeline(n *parse.Pipeline) {
+	indented := false
+	first, nl := true, false
+	for _, ch := range parse.Children(n) {
+		switch ch := ch.(type) {
+		case *parse.Form:
+			if !first {
+				if nl {
+					if !indented {
+						p.indent++
+						indented = true
+					}
+					p.newline()
+				} else {
+					p.write(" ")
+				}
+			}
+			p.form(ch)
+			first, nl = false, false
+		case *parse.Sep:
+			switch text := parse.SourceText(ch); text {
+			case "|":
+				p.write(" |")
+			case "|&":
+				p.write(" |&")
+			case "&":
+				p.write(" &")
+			default:
+				for _, t := range scanSep(text) {
+					switch t.typ {
+					case newlineToken, continuationToken:
+						nl = true
+					case commentToken:
+						p.write(" " + t.text)
+					}
+				}
+			}
+		}
+	}
+	if indented {
+		p.indent--
+	}
+}
+
+func (p *printer) form(n *parse.Form) {
+	indented := false
+	first, cont := true, false
+	continueLine := func() {
+		p.write(" ^")
+		if !indented {
+			p.indent++
+			indented = true
+		}
+		p.newline()
+		cont = false
+	}
+	for _, ch := range parse.Children(n) {
+		if _, ok := ch.(*parse.HereDoc); ok {
+			continue
+		}
+		if _, ok := ch.(*parse.Sep); ok {
+			for _, t := range scanSep(parse.SourceText(ch)) {
+				switch t.typ {
+				case continuationToken:
+					cont = true
+				case commentToken:
+					if cont {
+						continueLine()
+						p.write(t.text)
+					} else {
+						p.write(" " + t.text)
+					}
+				}
+			}
+			continue
+		}
+		if !first {
+			if cont {
+				continueLine()
+			} else {
+				p.write(" ")
+			}
+		}
+		p.node(ch)
+		first = false
+	}
+	if indented {
+		p.indent--
+	}
+}
+
+func (p *printer) node(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.Compound:
+		p.compound(n)
+	case *parse.MapPair:
+		p.mapPair(n)
+	case *parse.Redir:
+		p.redir(n)
+	case *parse.Pipeline:
+		p.pipeline(n)
+	default:
+		// Other node types don't appear where node is called.
+		p.write(parse.SourceText(n))
+	}
+}
+
+func (p *printer) redir(n *parse.Redir) {
+	if n.Left != nil {
+		p.compound(n.Left)
+	}
+	p.write(redirSigns[n.Mode])
+	if n.Mode == parse.ReadHereDoc {
+		// The delimiter is the last child. The body of a here-document is
+		// significant, including the indentation, so it is kept as is and
+		// written after the next line break.
+		children := parse.Children(n)
+		p.write(parse.SourceText(children[len(children)-1]))
+		p.hereDocs = append(p.hereDocs, n.HereDoc)
+		return
+	}
+	if n.RightIsFd {
+		p.write("&")
+	} else {
+		p.write(" ")
+	}
+	p.compound(n.Right)
+}
+
+var redirSigns = map[parse.RedirMode]string{
+	parse.Read: "<", parse.Write: ">", parse.ReadWrite: "<>", parse.Append: ">>",
+	parse.ReadHereDoc: "<<", parse.ReadHereString: "<<<",
+}
+
+func (p *printer) mapPair(n *parse.MapPair) {
+	p.write("&")
+	p.compound(n.Key)
+	if n.Value != nil {
+		p.write("=")
+		p.mapPairValue(n)
+	}
+}
+
+func (p *printer) mapPairValue(n *parse.MapPair) {
+	indented := false
+	for _, ch := range parse.Children(n) {
+		if _, ok := ch.(*parse.Sep); !ok {
+			continue
+		}
+		for _, t := range scanSep(parse.SourceText(ch)) {
+			if t.typ == commentToken {
+				// A comment between "=" and the value; the value has to go on
+				// the next line.
+				p.write(" " + t.text)
+				if !indented {
+					p.indent++
+					indented = true
+				}
+				p.newline()
+			}
+		}
+	}
+	p.compound(n.Value)
+	if indented {
+		p.indent--
+	}
+}
+
+// A line in a map spanning multiple lines.
+type mapLine struct {
+	// Number of line breaks before this line.
+	before  int
+	pairs   []*parse.MapPair
+	comment string
+}
+
+// Prints a map spanning multiple lines. Pairs on lines of their own are
+// written with a space after "=", and the values of such pairs on adjacent
+// lines are aligned when they fit on one line.
+func (p *printer) multilineMap(toks []token) {
+	var lines []*mapLine
+	nl := 0
+	for _, t := range toks {
+		switch t.typ {
+		case nodeToken:
+			if len(lines) == 0 || nl > 0 || lines[len(lines)-1].comment != "" {
+				lines = append(lines, &mapLine{before: nl})
+			}
+			last := lines[len(lines)-1]
+			last.pairs = append(last.pairs, t.node.(*parse.MapPair))
+			nl = 0
+		case newlineToken, continuationToken:
+			nl++
+		case commentToken:
+			if len(lines) == 0 || nl > 0 {
+				lines = append(lines, &mapLine{before: nl})
+			}
+			lines[len(lines)-1].comment = t.text
+			nl = 0
+		}
+	}
+
+	p.write("[")
+	p.indent++
+	p.newline()
+	// Keys and values of lines that consist of exactly one pair with a value.
+	keys := make([]string, len(lines))
+	values := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line.pairs) == 1 && line.pairs[0].Value != nil {
+			pair := line.pairs[0]
+			keys[i] = p.render(func(p *printer) {
+				p.write("&")
+				p.compound(pair.Key)
+				p.write("=")
+			})
+			values[i] = p.render(func(p *printer) { p.mapPairValue(pair) })
+		}
+	}
+	aligned := func(i int) bool {
+		return keys[i] != "" && !strings.Contains(values[i], "\n")
+	}
+	width := 0
+	for i, line := range lines {
+		if i > 0 {
+			p.newlines(line.before)
+		}
+		if keys[i] != "" {
+			if aligned(i) && (i == 0 || line.before > 1 || !aligned(i-1)) {
+				// Start of a run of aligned lines; find the widest key.
+				width = 0
+				for j := i; j < len(lines) && aligned(j) && (j == i || lines[j].before == 1); j++ {
+					width = max(width, wcwidth.Of(keys[j]))
+				}
+			}
+			pad := 1
+			if aligned(i) {
+				pad += width - wcwidth.Of(keys[i])
+			}
+			p.write(keys[i] + strings.Repeat(" ", pad) + values[i])
+		} else {
+			for j, pair := range line.pairs {
+				if j > 0 {
+					p.write(" ")
+				}
+				p.mapPair(pair)
+			}
+		}
+		if line.comment != "" {
+			if len(line.pairs) > 0 {
+				p.write(" ")
+			}
+			p.write(line.comment)
+		}
+	}
+	p.indent--
+	p.newline()
+	p.write("]")
+}
+
+// Renders something with a new printer that shares the indentation level, and
+// returns the output.
+func (p *printer) render(f func(*printer)) string {
+	sub := &printer{indent: p.indent}
+	f(sub)
+	return sub.sb.String()
+}
+
+func (p *printer) compound(n *parse.Compound) {
+	for _, in := range n.Indexings {
+		p.primary(in.Head)
+		for _, index := range in.Indices {
+			p.block("[", "]", " ", tokensOf(parse.Children(index)), p.node)
+		}
+	}
+}
+
+func (p *printer) primary(n *parse.Primary) {
+	switch n.Type {
+	case parse.OutputCapture:
+		p.block("(", ")", "; ", tokensOf(parse.Children(n.Chunk)), p.node)
+	case parse.ExceptionCapture:
+		p.block("?(", ")", "; ", tokensOf(parse.Children(n.Chunk)), p.node)
+	case parse.ReadSubstitution:
+		p.block("<(", ")", "; ", tokensOf(parse.Children(n.Chunk)), p.node)
+	case parse.WriteSubstitution:
+		p.block(">(", ")", "; ", tokensOf(parse.Children(n.Chunk)), p.node)
+	case parse.List:
+		p.block("[", "]", " ", tokensOf(parse.Children(n)), p.node)
+	case parse.Map:
+		if toks := tokensOf(parse.Children(n)); len(n.MapPairs) == 0 {
+			p.write("[&]")
+		} else if multiline(toks) {
+			p.multilineMap(toks)
+		} else {
+			p.block("[", "]", " ", toks, p.node)
+		}
+	case parse.Lambda:
+		p.lambda(n)
+	case parse.Braced:
+		p.braced(n)
+	default:
+		// Bareword, SingleQuoted, DoubleQuoted, Variable, Wildcard and Tilde.
+		p.write(parse.SourceText(n))
+	}
+}
+
+func (p *printer) lambda(n *parse.Primary) {
+	var sigNodes, bodyNodes []parse.Node
+	// Number of "|" separators seen.
+	pipes := 0
+	for _, ch := range parse.Children(n) {
+		switch ch := ch.(type) {
+		case *parse.Chunk:
+			bodyNodes = append(bodyNodes, parse.Children(ch)...)
+		case *parse.Sep:
+			if parse.SourceText(ch) == "|" {
+				pipes++
+			} else if pipes == 1 {
+				sigNodes = append(sigNodes, ch)
+			} else {
+				// Comments and line breaks before the signature are moved to
+				// the body.
+				bodyNodes = append(bodyNodes, ch)
+			}
+		default:
+			sigNodes = append(sigNodes, ch)
+		}
+	}
+
+	p.write("{")
+	if pipes > 0 {
+		p.block("|", "|", " ", tokensOf(sigNodes), p.node)
+	}
+	bodyToks := tokensOf(bodyNodes)
+	if multiline(bodyToks) {
+		p.indent++
+		p.newline()
+		p.lines(bodyToks, "; ", p.node)
+		p.indent--
+		p.newline()
+	} else {
+		p.write(" ")
+		if hasNode(bodyToks) {
+			p.lines(bodyToks, "; ", p.node)
+			p.write(" ")
+		}
+	}
+	p.write("}")
+}
+
+func (p *printer) braced(n *parse.Primary) {
+	for _, t := range tokensOf(parse.Children(n)) {
+		if t.typ == commentToken {
+			// Comments inside braced lists are too rare to be worth handling.
+			p.write(parse.SourceText(n))
+			return
+		}
+	}
+	// Elements are separated with spaces, unless there are empty elements,
+	// which can only be written with commas.
+	sep := " "
+	for _, elem := range n.Braced {
+		if len(elem.Indexings) == 0 {
+			sep = ","
+		}
+	}
+	p.write("{")
+	for i, elem := range n.Braced {
+		if i > 0 {
+			p.write(sep)
+		}
+		p.compound(elem)
+	}
+	p.write("}")
+}
+
+// Reports whether two syntax trees are the same, ignoring separators.
+func sameAST(a, b parse.Node) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	switch a := a.(type) {
+	case *parse.Pipeline:
+		if a.Background != b.(*parse.Pipeline).Background {
+			return false
+		}
+	case *parse.Form:
+		if a.PipesStderr != b.(*parse.Form).PipesStderr {
+			return false
+		}
+	case *parse.Redir:
+		b := b.(*parse.Redir)
+		if a.Mode != b.Mode || a.RightIsFd != b.RightIsFd || (a.Left == nil) != (b.Left == nil) {
+			return false
+		}
+	case *parse.HereDoc:
+		b := b.(*parse.HereDoc)
+		if a.Quoted != b.Quoted || a.Dedent != b.Dedent || !slices.Equal(a.Texts, b.Texts) {
+			return false
+		}
+	case *parse.MapPair:
+		if (a.Value == nil) != (b.(*parse.MapPair).Value == nil) {
+			return false
+		}
+	case *parse.Primary:
+		b := b.(*parse.Primary)
+		if a.Type != b.Type || a.Value != b.Value {
+			return false
+		}
+	}
+	as, bs := nonSeps(parse.Children(a)), nonSeps(parse.Children(b))
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !sameAST(as[i], bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func nonSeps(nodes []parse.Node) []parse.Node {
+	var result []parse.Node
+	for _, n := range nodes {
+		if _, ok := n.(*parse.Sep); !ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
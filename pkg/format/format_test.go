@@ -0,0 +1,121 @@
+package format
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/tt"
+)
+
+func formatCode(code string) (string, error) {
+	tree, err := parse.Parse(parse.Source{Name: "[test]", Code: code}, parse.Config{})
+	if err != nil {
+		return "", err
+	}
+	return Format(tree)
+}
+
+var Args = tt.Args
+
+func TestFormat(t *testing.T) {
+	tt.Test(t, tt.Fn(formatCode).Named("format"),
+		// Empty code.
+		Args("").Rets("", nil),
+		Args("\n\n").Rets("", nil),
+
+		// Spaces between elements are normalized.
+		Args("echo   foo  bar").Rets("echo foo bar\n", nil),
+		// Pipelines on the same line are separated with "; ".
+		Args("echo a;echo b ;  echo c").Rets("echo a; echo b; echo c\n", nil),
+		// Runs of empty lines are collapsed.
+		Args("echo a\n\n\n\necho b\n").Rets("echo a\n\necho b\n", nil),
+		// Pipes and background.
+		Args("echo a|wc -l &").Rets("echo a | wc -l &\n", nil),
+		// Pipes at line ends cause the next form to be indented.
+		Args("echo a |\nwc -l").Rets("echo a |\n  wc -l\n", nil),
+		Args("make|&grep error").Rets("make |& grep error\n", nil),
+		// Redirections.
+		Args("echo a  >file 2>&1 <in").Rets("echo a > file 2>&1 < in\n", nil),
+		Args("cat <<<foo").Rets("cat <<< foo\n", nil),
+		// The bodies of here-documents are kept as is.
+		Args("if a {\ncat << ~EOF  >out\n  foo $x\n    EOF\n}").
+			Rets("if a {\n  cat <<~EOF > out\n  foo $x\n    EOF\n}\n", nil),
+		// Code after the delimiter continues the pipeline, and the body is
+		// written after the line break.
+		Args("cat <<EOF|grep x;echo\nx\nEOF\necho [(cat <<'E')\n$x\nE\n]").
+			Rets("cat <<EOF | grep x; echo\nx\nEOF\necho [\n  (cat <<'E')\n$x\nE\n]\n", nil),
+		// Line continuations.
+		Args("echo a ^\nb").Rets("echo a ^\n  b\n", nil),
+
+		// Lists.
+		Args("put [ a  b ]").Rets("put [a b]\n", nil),
+		Args("put [\na\nb]").Rets("put [\n  a\n  b\n]\n", nil),
+		// Maps.
+		Args("put [&a=b  &c= d]").Rets("put [&a=b &c=d]\n", nil),
+		Args("put [ & ]").Rets("put [&]\n", nil),
+		// Destructuring patterns, with map pairs without values.
+		Args("var [a  [&b  &c=d]] = $x").Rets("var [a [&b &c=d]] = $x\n", nil),
+		// Values of pairs on lines of their own are aligned.
+		Args("put [\n&a=b\n&foo=bar\n\n&lorem=ipsum]").
+			Rets("put [\n  &a=   b\n  &foo= bar\n\n  &lorem= ipsum\n]\n", nil),
+
+		// Lambdas.
+		Args("fn f {|a b|echo $a}").Rets("fn f {|a b| echo $a }\n", nil),
+		Args("fn f {  }").Rets("fn f { }\n", nil),
+		Args("fn f {\necho a\n\n\necho b\n}").
+			Rets("fn f {\n  echo a\n\n  echo b\n}\n", nil),
+		// Output captures.
+		Args("put ( echo a )").Rets("put (echo a)\n", nil),
+		Args("put (\necho a\necho b)").Rets("put (\n  echo a\n  echo b\n)\n", nil),
+		// Process substitutions.
+		Args("diff <( ls a ) >(  wc -l)").Rets("diff <(ls a) >(wc -l)\n", nil),
+		// Braced lists are separated with spaces, unless there are empty
+		// elements.
+		Args("echo {a,b}").Rets("echo {a b}\n", nil),
+		Args("echo {a,,b}").Rets("echo {a,,b}\n", nil),
+
+		// Comments.
+		Args("# comment\necho a   # trailing").Rets("# comment\necho a # trailing\n", nil),
+		Args("fn f {\n    # comment\n}").Rets("fn f {\n  # comment\n}\n", nil),
+		Args("put [\na # comment\nb]").Rets("put [\n  a # comment\n  b\n]\n", nil),
+
+		// String literals are kept verbatim.
+		Args(`echo 'a  b' "c\td"`).Rets(`echo 'a  b' "c\td"`+"\n", nil),
+	)
+}
+
+func TestFormat_IsIdempotentOnRepoCode(t *testing.T) {
+	root := filepath.Join("..", "..")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || filepath.Ext(path) != ".elv" {
+			return nil
+		}
+		code, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatCode(string(code))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			return nil
+		}
+		formatted2, err := formatCode(formatted)
+		if err != nil || formatted2 != formatted {
+			t.Errorf("%s: formatting is not idempotent", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
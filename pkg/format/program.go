@@ -0,0 +1,116 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the formatter subprogram.
+type Program struct {
+	run   bool
+	write bool
+	check bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "fmt", false, "Format Elvish code from files or stdin")
+	fs.BoolVar(&p.write, "w", false,
+		"Write the result to the source file (requires -fmt)")
+	fs.BoolVar(&p.check, "check", false,
+		"List files that are not formatted and exit with 1 if there are any (requires -fmt)")
+}
+
+func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if !p.run {
+		if p.write || p.check {
+			return prog.BadUsage("-w and -check require -fmt")
+		}
+		return prog.NextProgram()
+	}
+	if p.write && p.check {
+		return prog.BadUsage("-w and -check can't be used together")
+	}
+
+	if len(args) == 0 {
+		if p.write {
+			return prog.BadUsage("-w requires files")
+		}
+		code, err := io.ReadAll(fds[0])
+		if err != nil {
+			fmt.Fprintln(fds[2], "cannot read stdin:", err)
+			return prog.Exit(2)
+		}
+		formatted, ok := formatSource(fds[2], parse.Source{Name: "[stdin]", Code: string(code)})
+		if !ok {
+			return prog.Exit(2)
+		}
+		if p.check {
+			if formatted != string(code) {
+				fmt.Fprintln(fds[1], "[stdin]")
+				return prog.Exit(1)
+			}
+			return nil
+		}
+		fds[1].WriteString(formatted)
+		return nil
+	}
+
+	failed, unformatted := false, false
+	for _, name := range args {
+		code, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(fds[2], "cannot read %q: %v\n", name, err)
+			failed = true
+			continue
+		}
+		formatted, ok := formatSource(fds[2], parse.Source{Name: name, Code: string(code)})
+		if !ok {
+			failed = true
+			continue
+		}
+		switch {
+		case p.check:
+			if formatted != string(code) {
+				fmt.Fprintln(fds[1], name)
+				unformatted = true
+			}
+		case p.write:
+			if formatted != string(code) {
+				err := os.WriteFile(name, []byte(formatted), 0644)
+				if err != nil {
+					fmt.Fprintf(fds[2], "cannot write %q: %v\n", name, err)
+					failed = true
+				}
+			}
+		default:
+			fds[1].WriteString(formatted)
+		}
+	}
+	if failed {
+		return prog.Exit(2)
+	}
+	if unformatted {
+		return prog.Exit(1)
+	}
+	return nil
+}
+
+// Parses and formats the source, showing any error on w.
+func formatSource(w io.Writer, src parse.Source) (string, bool) {
+	tree, err := parse.Parse(src, parse.Config{})
+	if err != nil {
+		diag.ShowError(w, err)
+		return "", false
+	}
+	formatted, err := Format(tree)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return "", false
+	}
+	return formatted, true
+}
@@ -0,0 +1,131 @@
+// Package lint implements the -lint subprogram, which checks Elvish code for
+// likely mistakes without executing it.
+//
+// The checks themselves are implemented by [eval.Evaler.Lint].
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/mods"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the linter subprogram.
+type Program struct {
+	run  bool
+	json *bool
+}
+
+func (p *Program) RegisterFlags(fs *prog.FlagSet) {
+	fs.BoolVar(&p.run, "lint", false,
+		"Check Elvish code from files or stdin for likely mistakes")
+	p.json = fs.JSON()
+}
+
+func (p *Program) Run(fds [3]*os.File, args []string) error {
+	if !p.run {
+		return prog.NextProgram()
+	}
+	ev := eval.NewEvaler()
+	mods.AddTo(ev)
+
+	var srcs []parse.Source
+	if len(args) == 0 {
+		code, err := io.ReadAll(fds[0])
+		if err != nil {
+			fmt.Fprintln(fds[2], "cannot read stdin:", err)
+			return prog.Exit(2)
+		}
+		srcs = append(srcs, parse.Source{Name: "[stdin]", Code: string(code)})
+	} else {
+		for _, name := range args {
+			code, err := os.ReadFile(name)
+			if err != nil {
+				fmt.Fprintf(fds[2], "cannot read %q: %v\n", name, err)
+				return prog.Exit(2)
+			}
+			srcs = append(srcs, parse.Source{Name: name, Code: string(code), IsFile: true})
+		}
+	}
+
+	var results []result
+	hasErrors, hasWarnings := false, false
+	for _, src := range srcs {
+		tree, parseErr := parse.Parse(src, parse.Config{})
+		warnings, compileErr := ev.Lint(tree)
+		results = append(results, result{parseErr, compileErr, warnings})
+		hasErrors = hasErrors || parseErr != nil || compileErr != nil
+		hasWarnings = hasWarnings || len(warnings) > 0
+	}
+
+	if *p.json {
+		fmt.Fprintf(fds[1], "%s\n", resultsToJSON(results))
+	} else {
+		for _, r := range results {
+			if r.parseErr != nil {
+				diag.ShowError(fds[2], r.parseErr)
+			}
+			if r.compileErr != nil {
+				diag.ShowError(fds[2], r.compileErr)
+			}
+			for _, w := range r.warnings {
+				fmt.Fprintln(fds[2], w.Show(""))
+			}
+		}
+	}
+
+	switch {
+	case hasErrors:
+		return prog.Exit(2)
+	case hasWarnings:
+		return prog.Exit(1)
+	default:
+		return nil
+	}
+}
+
+// Result of linting one source.
+type result struct {
+	parseErr   error
+	compileErr error
+	warnings   []*eval.LintWarning
+}
+
+// An auxiliary struct for converting errors and warnings to JSON, in the same
+// format used by -compileonly.
+type errorInJSON struct {
+	FileName string `json:"fileName"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Message  string `json:"message"`
+}
+
+func resultsToJSON(results []result) []byte {
+	converted := []errorInJSON{}
+	add := func(c diag.Context, msg string) {
+		converted = append(converted, errorInJSON{c.Name, c.From, c.To, msg})
+	}
+	for _, r := range results {
+		for _, e := range parse.UnpackErrors(r.parseErr) {
+			add(e.Context, e.Message)
+		}
+		for _, e := range eval.UnpackCompilationErrors(r.compileErr) {
+			add(e.Context, e.Message)
+		}
+		for _, w := range r.warnings {
+			add(w.Context, w.Message)
+		}
+	}
+	jsonError, errMarshal := json.Marshal(converted)
+	if errMarshal != nil {
+		return []byte(`[{"message":"Unable to convert the errors to JSON"}]`)
+	}
+	return jsonError
+}
@@ -0,0 +1,370 @@
+// Package channel implements the chan: module, which provides channels for
+// passing values between code running concurrently.
+//
+// The package is not named chan, since that's a keyword in Go.
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Ns is the namespace for the chan: module.
+var Ns = eval.BuildNsNamed("chan").
+	AddGoFns(map[string]any{
+		"make":   makeChan,
+		"send":   send,
+		"recv":   recv,
+		"close":  closeChan,
+		"select": selectFn,
+	}).Ns()
+
+var (
+	// ErrClosed is thrown when sending to a closed channel, or receiving
+	// from a closed channel that has no more buffered values.
+	ErrClosed = errors.New("channel closed")
+	// ErrAlreadyClosed is thrown when closing a channel that has already been
+	// closed.
+	ErrAlreadyClosed = errors.New("channel already closed")
+)
+
+// Chan is a channel of values. It may be buffered or unbuffered.
+//
+// Closing a Chan doesn't close the underlying Go channel, so that sending to
+// a closed Chan fails with ErrClosed instead of panicking. Receivers use the
+// separate closed channel to learn that no more values will be sent.
+type Chan struct {
+	ch       chan any
+	closed   chan struct{}
+	closeMu  sync.Mutex
+	isClosed bool
+}
+
+var _ eval.FrameIterator = (*Chan)(nil)
+
+// Kind returns "chan".
+func (*Chan) Kind() string { return "chan" }
+
+// Equal compares by address.
+func (c *Chan) Equal(rhs any) bool { return c == rhs }
+
+// Hash returns the hash of the address of the channel.
+func (c *Chan) Hash() uint32 { return hash.Pointer(unsafe.Pointer(c)) }
+
+// Repr returns an opaque representation "<chan 0x23333333>".
+func (c *Chan) Repr(int) string { return fmt.Sprintf("<chan %p>", c) }
+
+// Iterate receives values from the channel and calls f with each of them,
+// until the channel is closed and drained or f returns false. Code iterating
+// the channel from Elvish uses IterateFrame, which can be interrupted.
+func (c *Chan) Iterate(f func(any) bool) error {
+	return c.iterate(nil, f)
+}
+
+// IterateFrame is like Iterate, but stops with an error when the Context of
+// fm is canceled.
+func (c *Chan) IterateFrame(fm *eval.Frame, f func(any) bool) error {
+	return c.iterate(fm, f)
+}
+
+func (c *Chan) iterate(fm *eval.Frame, f func(any) bool) error {
+	for {
+		v, err := c.recv(fm)
+		if err == ErrClosed {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !f(v) {
+			return nil
+		}
+	}
+}
+
+// Returns the done channel of the Context of fm, or nil if fm is nil.
+func done(fm *eval.Frame) <-chan struct{} {
+	if fm == nil {
+		return nil
+	}
+	return fm.Context().Done()
+}
+
+func (c *Chan) recv(fm *eval.Frame) (any, error) {
+	select {
+	case v := <-c.ch:
+		return v, nil
+	case <-c.closed:
+		return c.drain()
+	case <-done(fm):
+		return nil, fm.CanceledError()
+	}
+}
+
+// Receives a buffered value from a closed channel, if there is any.
+func (c *Chan) drain() (any, error) {
+	select {
+	case v := <-c.ch:
+		return v, nil
+	default:
+		return nil, ErrClosed
+	}
+}
+
+func (c *Chan) send(fm *eval.Frame, v any) error {
+	if c.closedNow() {
+		return ErrClosed
+	}
+	select {
+	case c.ch <- v:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-done(fm):
+		return fm.CanceledError()
+	}
+}
+
+func (c *Chan) closedNow() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+type makeOpts struct{ Size int }
+
+func (*makeOpts) SetDefaultOptions() {}
+
+func makeChan(opts makeOpts) (*Chan, error) {
+	if opts.Size < 0 {
+		return nil, errs.BadValue{What: "size",
+			Valid: "non-negative integer", Actual: strconv.Itoa(opts.Size)}
+	}
+	return &Chan{ch: make(chan any, opts.Size), closed: make(chan struct{})}, nil
+}
+
+func send(fm *eval.Frame, c *Chan, values ...any) error {
+	for _, v := range values {
+		if err := c.send(fm, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recv(fm *eval.Frame, c *Chan) (any, error) {
+	return c.recv(fm)
+}
+
+func closeChan(c *Chan) error {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.isClosed {
+		return ErrAlreadyClosed
+	}
+	c.isClosed = true
+	close(c.closed)
+	return nil
+}
+
+// A parsed case of chan:select.
+type selectCase struct {
+	kind     string
+	c        *Chan
+	value    any
+	timeout  time.Duration
+	f        eval.Callable
+	onClosed eval.Callable
+}
+
+func selectFn(fm *eval.Frame, args ...any) error {
+	cases := make([]selectCase, len(args))
+	hasTimeout, hasDefault := false, false
+	for i, arg := range args {
+		sc, err := parseSelectCase(arg)
+		if err != nil {
+			return err
+		}
+		switch sc.kind {
+		case "timeout":
+			if hasTimeout {
+				return errors.New("select can have at most one timeout case")
+			}
+			hasTimeout = true
+		case "default":
+			if hasDefault {
+				return errors.New("select can have at most one default case")
+			}
+			hasDefault = true
+		case "send":
+			if sc.c.closedNow() {
+				return ErrClosed
+			}
+		}
+		cases[i] = sc
+	}
+
+	// Build the cases for reflect.Select. Each of them is mapped to the index
+	// of the select case, and whether it receives from the closed channel of
+	// the Chan.
+	var rcases []reflect.SelectCase
+	type caseRef struct {
+		i      int
+		closed bool
+	}
+	var refs []caseRef
+	add := func(rc reflect.SelectCase, i int, closed bool) {
+		rcases = append(rcases, rc)
+		refs = append(refs, caseRef{i, closed})
+	}
+	recvFrom := func(ch any) reflect.SelectCase {
+		return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+	}
+	for i, sc := range cases {
+		switch sc.kind {
+		case "recv":
+			add(recvFrom(sc.c.ch), i, false)
+			if sc.onClosed != nil {
+				add(recvFrom(sc.c.closed), i, true)
+			}
+		case "send":
+			value := sc.value
+			add(reflect.SelectCase{Dir: reflect.SelectSend,
+				Chan: reflect.ValueOf(sc.c.ch), Send: reflect.ValueOf(&value).Elem()}, i, false)
+			add(recvFrom(sc.c.closed), i, true)
+		case "timeout":
+			add(recvFrom(time.After(sc.timeout)), i, false)
+		case "default":
+			add(reflect.SelectCase{Dir: reflect.SelectDefault}, i, false)
+		}
+	}
+	add(recvFrom(fm.Context().Done()), -1, false)
+
+	chosen, received, _ := reflect.Select(rcases)
+	ref := refs[chosen]
+	if ref.i == -1 {
+		return fm.CanceledError()
+	}
+	sc := cases[ref.i]
+	switch sc.kind {
+	case "recv":
+		if !ref.closed {
+			return call(fm, sc.f, received.Interface())
+		}
+		if v, err := sc.c.drain(); err == nil {
+			return call(fm, sc.f, v)
+		}
+		return call(fm, sc.onClosed)
+	case "send":
+		if ref.closed {
+			return ErrClosed
+		}
+	}
+	return call(fm, sc.f)
+}
+
+func call(fm *eval.Frame, f eval.Callable, args ...any) error {
+	if f == nil {
+		return nil
+	}
+	return f.Call(fm, args, eval.NoOpts)
+}
+
+const selectCaseForms = "[recv $ch $fn $on-closed?], [send $ch $value $fn?], " +
+	"[timeout $duration $fn?] or [default $fn?]"
+
+func parseSelectCase(arg any) (selectCase, error) {
+	bad := errs.BadValue{What: "select case", Valid: selectCaseForms, Actual: vals.ReprPlain(arg)}
+	list, ok := arg.(vals.List)
+	if !ok || list.Len() == 0 {
+		return selectCase{}, bad
+	}
+	elems, _ := vals.Collect(list)
+	kind, ok := elems[0].(string)
+	if !ok {
+		return selectCase{}, bad
+	}
+	sc := selectCase{kind: kind}
+	// Number of elements before the optional callables, and the maximum
+	// number of optional callables.
+	var nFixed, nCallables int
+	switch kind {
+	case "recv":
+		nFixed, nCallables = 2, 2
+	case "send":
+		nFixed, nCallables = 3, 1
+	case "timeout":
+		nFixed, nCallables = 2, 1
+	case "default":
+		nFixed, nCallables = 1, 1
+	default:
+		return selectCase{}, bad
+	}
+	if len(elems) < nFixed || len(elems) > nFixed+nCallables ||
+		(kind == "recv" && len(elems) == nFixed) {
+		return selectCase{}, bad
+	}
+	switch kind {
+	case "recv", "send":
+		if sc.c, ok = elems[1].(*Chan); !ok {
+			return selectCase{}, bad
+		}
+		if kind == "send" {
+			sc.value = elems[2]
+		}
+	case "timeout":
+		d, err := scanDuration(elems[1])
+		if err != nil {
+			return selectCase{}, err
+		}
+		sc.timeout = d
+	}
+	callables := make([]eval.Callable, len(elems)-nFixed)
+	for i, elem := range elems[nFixed:] {
+		if callables[i], ok = elem.(eval.Callable); !ok {
+			return selectCase{}, bad
+		}
+	}
+	if len(callables) > 0 {
+		sc.f = callables[0]
+	}
+	if len(callables) > 1 {
+		sc.onClosed = callables[1]
+	}
+	return sc, nil
+}
+
+// Scans a duration in the same format as the argument of sleep: either a
+// number of seconds, or a string accepted by Go's time.ParseDuration.
+func scanDuration(v any) (time.Duration, error) {
+	bad := errs.BadValue{What: "timeout",
+		Valid: "non-negative number of seconds or duration string", Actual: vals.ReprPlain(v)}
+	var d time.Duration
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		d = time.Duration(f * float64(time.Second))
+	} else if s, ok := v.(string); ok {
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, bad
+		}
+	} else {
+		return 0, bad
+	}
+	if d < 0 {
+		return 0, bad
+	}
+	return d, nil
+}
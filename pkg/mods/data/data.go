@@ -0,0 +1,332 @@
+// Package data implements the data: module, which works with nested data
+// structures like those from from-json.
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// Ns is the namespace for the data: module.
+var Ns = eval.BuildNsNamed("data").
+	AddGoFns(map[string]any{
+		"get":       get,
+		"get-in":    getIn,
+		"assoc-in":  assocIn,
+		"update-in": updateIn,
+		"dissoc-in": dissocIn,
+		"select":    selectFn,
+	}).Ns()
+
+type getOpts struct{ Default any }
+
+func (*getOpts) SetDefaultOptions() {}
+
+func get(opts getOpts, container, key any) any {
+	v, err := vals.Index(container, key)
+	if err != nil {
+		return opts.Default
+	}
+	return v
+}
+
+func getIn(opts getOpts, container any, path vals.List) any {
+	v := container
+	for it := path.Iterator(); it.HasElem(); it.Next() {
+		var err error
+		v, err = vals.Index(v, it.Elem())
+		if err != nil {
+			return opts.Default
+		}
+	}
+	return v
+}
+
+func assocIn(container any, path vals.List, value any) (any, error) {
+	return updateInFn(container, listToSlice(path), func(any) (any, error) {
+		return value, nil
+	})
+}
+
+func updateIn(fm *eval.Frame, container any, path vals.List, f eval.Callable) (any, error) {
+	return updateInFn(container, listToSlice(path), func(old any) (any, error) {
+		outputs, err := fm.CaptureOutput(func(fm *eval.Frame) error {
+			return f.Call(fm, []any{old}, eval.NoOpts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(outputs) != 1 {
+			return nil, errs.ArityMismatch{What: "number of callback outputs",
+				ValidLow: 1, ValidHigh: 1, Actual: len(outputs)}
+		}
+		return outputs[0], nil
+	})
+}
+
+// Replaces the value at path within container with the result of calling f
+// with the old value, or nil if there is no value at the path. Missing
+// containers along the path are created as empty maps.
+func updateInFn(container any, path []any, f func(any) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return f(container)
+	}
+	child, err := vals.Index(container, path[0])
+	if err != nil {
+		if vals.HasKey(container, path[0]) {
+			return nil, err
+		}
+		child = nil
+		if len(path) > 1 {
+			child = vals.EmptyMap
+		}
+	}
+	newChild, err := updateInFn(child, path[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	return vals.Assoc(container, path[0], newChild)
+}
+
+func dissocIn(container any, path vals.List) (any, error) {
+	if path.Len() == 0 {
+		return nil, errs.BadValue{What: "path", Valid: "non-empty list", Actual: "[]"}
+	}
+	return dissocInSlice(container, listToSlice(path))
+}
+
+func dissocInSlice(container any, path []any) (any, error) {
+	if len(path) == 1 {
+		newContainer := vals.Dissoc(container, path[0])
+		if newContainer == nil {
+			return nil, errs.BadValue{
+				What:  "container to dissoc key " + vals.ReprPlain(path[0]) + " from",
+				Valid: "map", Actual: vals.Kind(container)}
+		}
+		return newContainer, nil
+	}
+	child, err := vals.Index(container, path[0])
+	if err != nil {
+		// Nothing to dissoc.
+		return container, nil
+	}
+	newChild, err := dissocInSlice(child, path[1:])
+	if err != nil {
+		return nil, err
+	}
+	return vals.Assoc(container, path[0], newChild)
+}
+
+func listToSlice(l vals.List) []any {
+	s := make([]any, 0, l.Len())
+	for it := l.Iterator(); it.HasElem(); it.Next() {
+		s = append(s, it.Elem())
+	}
+	return s
+}
+
+func selectFn(fm *eval.Frame, container any, expr string) error {
+	steps, err := parsePathExpr(expr)
+	if err != nil {
+		return err
+	}
+	values := []any{container}
+	for _, step := range steps {
+		var next []any
+		for _, v := range values {
+			next = step.apply(v, next)
+		}
+		values = next
+	}
+	out := fm.ValueOutput()
+	for _, v := range values {
+		err := out.Put(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A step in a path expression.
+type pathStep struct {
+	typ pathStepType
+	// The key to index with. Only used when typ is indexStep.
+	key string
+}
+
+type pathStepType int
+
+const (
+	// Indexes each value with a key, like ".foo" or "[0]".
+	indexStep pathStepType = iota
+	// Outputs all the elements of lists and values of maps, like "[]".
+	iterateStep
+	// Outputs each value and all the values nested in it, like "..".
+	recurseStep
+)
+
+// Appends the result of applying the step to v to results.
+func (s pathStep) apply(v any, results []any) []any {
+	switch s.typ {
+	case indexStep:
+		// Strings are indexable, but indexing them is not useful in path
+		// expressions.
+		if _, isString := v.(string); isString {
+			return results
+		}
+		if elem, err := vals.Index(v, s.key); err == nil {
+			results = append(results, elem)
+		}
+		return results
+	case iterateStep:
+		return appendChildren(v, results)
+	default: // recurseStep
+		results = append(results, v)
+		for _, child := range appendChildren(v, nil) {
+			results = s.apply(child, results)
+		}
+		return results
+	}
+}
+
+// Appends the elements of a list or the values of a map to results, sorting
+// map keys for a deterministic order. Other values have no children.
+func appendChildren(v any, results []any) []any {
+	switch v := v.(type) {
+	case string:
+		return results
+	case vals.List:
+		for it := v.Iterator(); it.HasElem(); it.Next() {
+			results = append(results, it.Elem())
+		}
+		return results
+	}
+	var keys []any
+	if vals.IterateKeys(v, func(k any) bool {
+		keys = append(keys, k)
+		return true
+	}) != nil {
+		return results
+	}
+	slices.SortFunc(keys, func(a, b any) int {
+		return int(vals.CmpTotal(a, b)) - int(vals.CmpEqual)
+	})
+	for _, k := range keys {
+		if elem, err := vals.Index(v, k); err == nil {
+			results = append(results, elem)
+		}
+	}
+	return results
+}
+
+// Parses a path expression into steps.
+//
+//	PathExpr = '.' | { '.' Key | '..' | '[' ( Number | QuotedKey )? ']' }
+func parsePathExpr(expr string) ([]pathStep, error) {
+	if expr == "." {
+		return nil, nil
+	}
+	bad := func(i int, msg string) error {
+		return fmt.Errorf("bad path expression %s at position %d: %s",
+			parse.Quote(expr), i, msg)
+	}
+	if expr == "" || (expr[0] != '.' && expr[0] != '[') {
+		return nil, bad(0, "should start with '.' or '['")
+	}
+	var steps []pathStep
+	i := 0
+	for i < len(expr) {
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			steps = append(steps, pathStep{typ: recurseStep})
+			i += 2
+			if i < len(expr) && isKeyChar(expr[i]) {
+				j := keyEnd(expr, i)
+				steps = append(steps, pathStep{typ: indexStep, key: expr[i:j]})
+				i = j
+			}
+		case expr[i] == '.':
+			j := keyEnd(expr, i+1)
+			if j == i+1 {
+				return nil, bad(i+1, "should be key")
+			}
+			steps = append(steps, pathStep{typ: indexStep, key: expr[i+1 : j]})
+			i = j
+		case expr[i] == '[':
+			j, step, err := parseBracket(expr, i)
+			if err != nil {
+				return nil, bad(j, err.Error())
+			}
+			steps = append(steps, step)
+			i = j
+		default:
+			return nil, bad(i, "should be '.' or '['")
+		}
+	}
+	return steps, nil
+}
+
+func isKeyChar(b byte) bool {
+	return b == '_' || b == '-' || b == ':' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// Returns the index of the end of the key starting at i.
+func keyEnd(expr string, i int) int {
+	for i < len(expr) && isKeyChar(expr[i]) {
+		i++
+	}
+	return i
+}
+
+// Parses a bracketed step starting at expr[i], which is '['. Returns the index
+// after the closing ']', or the index of the error.
+func parseBracket(expr string, i int) (int, pathStep, error) {
+	i++
+	var step pathStep
+	switch {
+	case i < len(expr) && expr[i] == ']':
+		step = pathStep{typ: iterateStep}
+	case i < len(expr) && expr[i] == '\'':
+		end := strings.IndexByte(expr[i+1:], '\'')
+		if end == -1 {
+			return i, step, errors.New("unterminated quoted key")
+		}
+		step = pathStep{typ: indexStep, key: expr[i+1 : i+1+end]}
+		i += end + 2
+	case i < len(expr) && expr[i] == '"':
+		quoted, err := strconv.QuotedPrefix(expr[i:])
+		if err != nil {
+			return i, step, errors.New("bad quoted key")
+		}
+		key, _ := strconv.Unquote(quoted)
+		step = pathStep{typ: indexStep, key: key}
+		i += len(quoted)
+	default:
+		j := i
+		if j < len(expr) && expr[j] == '-' {
+			j++
+		}
+		for j < len(expr) && '0' <= expr[j] && expr[j] <= '9' {
+			j++
+		}
+		if j == i || expr[j-1] == '-' {
+			return i, step, errors.New("should be integer, quoted key or ']'")
+		}
+		step = pathStep{typ: indexStep, key: expr[i:j]}
+		i = j
+	}
+	if i >= len(expr) || expr[i] != ']' {
+		return i, step, errors.New("should be ']'")
+	}
+	return i + 1, step, nil
+}
@@ -52,7 +52,23 @@ func isTTYPort(fm *eval.Frame, portNum int) bool {
 	return p != nil && sys.IsATTY(p.File.Fd())
 }
 
-func open(name string) (vals.File, error) {
+// Converts a file name argument, which may be a string or a bytes value, to a
+// string. Accepting bytes values allows using file names that are not valid
+// UTF-8.
+func fileName(v any) (string, error) {
+	if b, ok := v.(vals.Bytes); ok {
+		return string(b), nil
+	}
+	var name string
+	err := vals.ScanToGo(v, &name)
+	return name, err
+}
+
+func open(rawName any) (vals.File, error) {
+	name, err := fileName(rawName)
+	if err != nil {
+		return nil, err
+	}
 	return os.Open(name)
 }
 
@@ -71,7 +87,11 @@ func (opts *openOutputOpts) SetDefaultOptions() {
 
 var errIfNotExistsAndIfExistsBothError = errors.New("both &if-not-exists and &if-exists are error")
 
-func openOutput(opts openOutputOpts, name string) (vals.File, error) {
+func openOutput(opts openOutputOpts, rawName any) (vals.File, error) {
+	name, err := fileName(rawName)
+	if err != nil {
+		return nil, err
+	}
 	perm := opts.CreatePerm
 	if perm < 0 || perm > 0o777 {
 		return nil, errs.OutOfRange{What: "create-perm option",
@@ -155,7 +175,11 @@ func tell(f vals.File) (vals.Num, error) {
 	return vals.Int64ToNum(offset), nil
 }
 
-func truncate(name string, rawSize vals.Num) error {
+func truncate(rawName any, rawSize vals.Num) error {
+	name, err := fileName(rawName)
+	if err != nil {
+		return err
+	}
 	size, err := toInt64(rawSize, "size", 0, "0")
 	if err != nil {
 		return err
@@ -3,6 +3,8 @@ package mods
 
 import (
 	"src.elv.sh/pkg/eval"
+	channel "src.elv.sh/pkg/mods/chan"
+	"src.elv.sh/pkg/mods/data"
 	"src.elv.sh/pkg/mods/doc"
 	"src.elv.sh/pkg/mods/epm"
 	"src.elv.sh/pkg/mods/file"
@@ -15,7 +17,9 @@ import (
 	"src.elv.sh/pkg/mods/re"
 	readline_binding "src.elv.sh/pkg/mods/readline-binding"
 	"src.elv.sh/pkg/mods/runtime"
+	"src.elv.sh/pkg/mods/set"
 	"src.elv.sh/pkg/mods/str"
+	"src.elv.sh/pkg/mods/time"
 	"src.elv.sh/pkg/mods/unix"
 )
 
@@ -29,12 +33,16 @@ func AddTo(ev *eval.Evaler) {
 	ev.AddModule("path", path.Ns)
 	ev.AddModule("platform", platform.Ns)
 	ev.AddModule("re", re.Ns)
+	ev.AddModule("set", set.Ns)
 	ev.AddModule("str", str.Ns)
 	ev.AddModule("file", file.Ns)
 	ev.AddModule("flag", flag.Ns)
 	ev.AddModule("doc", doc.Ns)
 	ev.AddModule("os", os.Ns)
 	ev.AddModule("md", md.Ns)
+	ev.AddModule("chan", channel.Ns)
+	ev.AddModule("time", time.Ns)
+	ev.AddModule("data", data.Ns)
 	if unix.ExposeUnixNs {
 		ev.AddModule("unix", unix.Ns)
 	}
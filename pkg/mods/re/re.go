@@ -163,22 +163,19 @@ func (o *awkOpt) SetDefaultOptions() {
 	o.Sep = "[ \t]+"
 }
 
-func awk(fm *eval.Frame, opts awkOpt, f eval.Callable, inputs eval.Inputs) error {
+func awk(fm *eval.Frame, opts awkOpt, f eval.Callable, inputs eval.StoppableInputs) error {
 	wordSep, err := makePattern(opts.Sep, opts.SepPosix, opts.SepLongest)
 	if err != nil {
 		return err
 	}
 
 	broken := false
-	inputs(func(v any) {
-		if broken {
-			return
-		}
+	inputs(func(v any) bool {
 		line, ok := v.(string)
 		if !ok {
 			broken = true
 			err = ErrInputOfAwkMustBeString
-			return
+			return false
 		}
 		args := []any{line}
 		for _, field := range wordSep.Split(strings.Trim(line, " \t"), -1) {
@@ -200,6 +197,7 @@ func awk(fm *eval.Frame, opts awkOpt, f eval.Callable, inputs eval.Inputs) error
 				err = ex
 			}
 		}
+		return !broken
 	})
 	return err
 }
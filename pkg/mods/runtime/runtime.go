@@ -27,9 +27,48 @@ func Ns(ev *eval.Evaler) *eval.Ns {
 			"lib-dirs":          vars.NewReadOnly(vals.MakeListSlice(ev.LibDirs)),
 			"rc-path":           vars.NewReadOnly(nonEmptyOrNil(ev.RcPath)),
 			"effective-rc-path": vars.NewReadOnly(nonEmptyOrNil(ev.EffectiveRcPath)),
+		}).
+		AddGoFns(map[string]any{
+			"profile": profile,
 		}).Ns()
 }
 
+type profileOpts struct {
+	Pprof string
+	Top   int
+}
+
+func (o *profileOpts) SetDefaultOptions() { o.Top = 10 }
+
+func profile(fm *eval.Frame, opts profileOpts, f eval.Callable) error {
+	p := eval.NewProfiler(0)
+	err := f.Call(fm.WithProfiler(p), eval.NoArgs, eval.NoOpts)
+	prof := p.Stop()
+	if opts.Pprof != "" {
+		if errWrite := writePprof(opts.Pprof, prof); err == nil {
+			err = errWrite
+		}
+	}
+	if opts.Top > 0 {
+		if errWrite := prof.WriteReport(fm.ErrorFile(), opts.Top); err == nil {
+			err = errWrite
+		}
+	}
+	return err
+}
+
+func writePprof(name string, prof *eval.Profile) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = prof.WritePprof(file)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
 func nonEmptyOrNil(s string) any {
 	if s == "" {
 		return nil
@@ -0,0 +1,90 @@
+// Package set implements the set: module, which works with set values.
+package set
+
+import (
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+var Ns = eval.BuildNsNamed("set").
+	AddGoFns(map[string]any{
+		"of":     of,
+		"add":    add,
+		"remove": remove,
+
+		"union":        union,
+		"intersection": intersection,
+		"difference":   difference,
+
+		"is-subset":   isSubset,
+		"is-superset": isSuperset,
+		"is-disjoint": isDisjoint,
+	}).Ns()
+
+func of(elems ...any) vals.Set { return vals.MakeSet(elems...) }
+
+func add(s vals.Set, elems ...any) vals.Set {
+	for _, elem := range elems {
+		s = s.Add(elem)
+	}
+	return s
+}
+
+func remove(s vals.Set, elems ...any) vals.Set {
+	for _, elem := range elems {
+		s = s.Remove(elem)
+	}
+	return s
+}
+
+func union(sets ...vals.Set) vals.Set {
+	if len(sets) == 0 {
+		return vals.EmptySet
+	}
+	result := sets[0]
+	for _, s := range sets[1:] {
+		s.Iterate(func(v any) bool {
+			result = result.Add(v)
+			return true
+		})
+	}
+	return result
+}
+
+func intersection(first vals.Set, more ...vals.Set) vals.Set {
+	result := first
+	first.Iterate(func(v any) bool {
+		for _, s := range more {
+			if !s.Has(v) {
+				result = result.Remove(v)
+				break
+			}
+		}
+		return true
+	})
+	return result
+}
+
+func difference(first vals.Set, more ...vals.Set) vals.Set {
+	result := first
+	for _, s := range more {
+		s.Iterate(func(v any) bool {
+			result = result.Remove(v)
+			return true
+		})
+	}
+	return result
+}
+
+func isSubset(a, b vals.Set) bool { return a.IsSubset(b) }
+
+func isSuperset(a, b vals.Set) bool { return b.IsSubset(a) }
+
+func isDisjoint(a, b vals.Set) bool {
+	disjoint := true
+	a.Iterate(func(v any) bool {
+		disjoint = !b.Has(v)
+		return disjoint
+	})
+	return disjoint
+}
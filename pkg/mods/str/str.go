@@ -105,14 +105,11 @@ func fromUtf8Bytes(nums ...int) (string, error) {
 	return b.String(), nil
 }
 
-func join(sep string, inputs eval.Inputs) (string, error) {
+func join(sep string, inputs eval.StoppableInputs) (string, error) {
 	var buf bytes.Buffer
 	var errJoin error
 	first := true
-	inputs(func(v any) {
-		if errJoin != nil {
-			return
-		}
+	inputs(func(v any) bool {
 		if s, ok := v.(string); ok {
 			if first {
 				first = false
@@ -124,6 +121,7 @@ func join(sep string, inputs eval.Inputs) (string, error) {
 			errJoin = errs.BadValue{
 				What: "input to str:join", Valid: "string", Actual: vals.Kind(v)}
 		}
+		return errJoin == nil
 	})
 	return buf.String(), errJoin
 }
@@ -158,7 +156,17 @@ func split(fm *eval.Frame, opts maxOpt, sep, s string) error {
 	return nil
 }
 
-func toCodepoints(fm *eval.Frame, s string) error {
+func toCodepoints(fm *eval.Frame, v any) error {
+	var s string
+	if b, ok := v.(vals.Bytes); ok {
+		if !utf8.ValidString(string(b)) {
+			return errs.BadValue{What: "bytes argument to str:to-codepoints",
+				Valid: "valid UTF-8", Actual: vals.ReprPlain(b)}
+		}
+		s = string(b)
+	} else if err := vals.ScanToGo(v, &s); err != nil {
+		return err
+	}
 	out := fm.ValueOutput()
 	for _, r := range s {
 		err := out.Put("0x" + strconv.FormatInt(int64(r), 16))
@@ -0,0 +1,3 @@
+package time
+
+var TimeNow = &timeNow
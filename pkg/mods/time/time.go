@@ -0,0 +1,289 @@
+// Package time implements the time: module, which works with timestamps and
+// durations.
+package time
+
+import (
+	"cmp"
+	"strconv"
+	"time"
+	// Embed the time zone database, so that time zone conversion works on
+	// systems without one, like Windows.
+	_ "time/tzdata"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Ns is the namespace for the time: module.
+var Ns = eval.BuildNsNamed("time").
+	AddGoFns(map[string]any{
+		"now":    now,
+		"parse":  parseFn,
+		"format": format,
+		"in":     in,
+
+		"duration": duration,
+		"seconds":  seconds,
+
+		"add":   add,
+		"sub":   sub,
+		"since": since,
+	}).Ns()
+
+// Reference to [time.Now] that can be overridden in tests.
+var timeNow = time.Now
+
+// Timestamp is an instant in time with a time zone.
+type Timestamp struct{ t time.Time }
+
+// Duration is an elapsed time between two instants.
+type Duration struct{ d time.Duration }
+
+var (
+	_ vals.Comparer = Timestamp{}
+	_ vals.Comparer = Duration{}
+)
+
+// Kind returns "time:timestamp".
+func (Timestamp) Kind() string { return "time:timestamp" }
+
+// Equal returns whether rhs is a Timestamp of the same instant.
+func (ts Timestamp) Equal(rhs any) bool {
+	r, ok := rhs.(Timestamp)
+	return ok && ts.t.Equal(r.t)
+}
+
+// Hash returns the hash of the instant.
+func (ts Timestamp) Hash() uint32 { return hash.UInt64(uint64(ts.t.UnixNano())) }
+
+// Compare compares chronologically with another Timestamp.
+func (ts Timestamp) Compare(rhs any) vals.Ordering {
+	if r, ok := rhs.(Timestamp); ok {
+		return ordering(ts.t.Compare(r.t))
+	}
+	return vals.CmpUncomparable
+}
+
+// String formats the timestamp using the RFC 3339 format, with fractional
+// seconds if they are not zero.
+func (ts Timestamp) String() string { return ts.t.Format(time.RFC3339Nano) }
+
+// Repr returns a representation that evaluates to an equivalent timestamp,
+// like "(time:parse 2024-01-02T03:04:05Z)".
+func (ts Timestamp) Repr(int) string {
+	return "(time:parse " + parse.Quote(ts.String()) + ")"
+}
+
+// GoTime returns the underlying [time.Time]. It is used by builtin commands
+// that accept timestamps, like with-deadline.
+func (ts Timestamp) GoTime() time.Time { return ts.t }
+
+// Index supports accessing the components of the timestamp, like $t[year].
+func (ts Timestamp) Index(k any) (any, bool) {
+	t := ts.t
+	switch k {
+	case "year":
+		return t.Year(), true
+	case "month":
+		return int(t.Month()), true
+	case "day":
+		return t.Day(), true
+	case "hour":
+		return t.Hour(), true
+	case "minute":
+		return t.Minute(), true
+	case "second":
+		return t.Second(), true
+	case "nanosecond":
+		return t.Nanosecond(), true
+	case "weekday":
+		return t.Weekday().String(), true
+	case "zone":
+		return t.Location().String(), true
+	}
+	return nil, false
+}
+
+// Kind returns "time:duration".
+func (Duration) Kind() string { return "time:duration" }
+
+// Equal returns whether rhs is a Duration of the same length.
+func (d Duration) Equal(rhs any) bool { return d == rhs }
+
+// Hash returns the hash of the length of the duration.
+func (d Duration) Hash() uint32 { return hash.UInt64(uint64(d.d)) }
+
+// Compare compares the length with another Duration.
+func (d Duration) Compare(rhs any) vals.Ordering {
+	if r, ok := rhs.(Duration); ok {
+		return ordering(cmp.Compare(d.d, r.d))
+	}
+	return vals.CmpUncomparable
+}
+
+// String formats the duration like "1h30m0s".
+func (d Duration) String() string { return d.d.String() }
+
+// Repr returns a representation that evaluates to an equivalent duration, like
+// "(time:duration 1h30m0s)".
+func (d Duration) Repr(int) string { return "(time:duration " + d.String() + ")" }
+
+// GoDuration returns the underlying [time.Duration]. It is used by builtin
+// commands that accept durations, like sleep.
+func (d Duration) GoDuration() time.Duration { return d.d }
+
+func ordering(i int) vals.Ordering {
+	switch {
+	case i < 0:
+		return vals.CmpLess
+	case i > 0:
+		return vals.CmpMore
+	default:
+		return vals.CmpEqual
+	}
+}
+
+func now() Timestamp { return Timestamp{timeNow()} }
+
+// Named layouts supported by parse and format, in addition to layouts in the
+// format of Go's time package.
+var layouts = map[string]string{
+	"rfc3339":      time.RFC3339,
+	"rfc3339-nano": time.RFC3339Nano,
+	"rfc1123":      time.RFC1123,
+	"date":         time.DateOnly,
+	"date-time":    time.DateTime,
+}
+
+type parseOpts struct {
+	Layout string
+	TZ     string
+}
+
+func (o *parseOpts) SetDefaultOptions() {
+	o.Layout = "rfc3339-nano"
+	o.TZ = "Local"
+}
+
+func parseFn(opts parseOpts, s string) (Timestamp, error) {
+	loc, err := loadLocation(opts.TZ)
+	if err != nil {
+		return Timestamp{}, err
+	}
+	switch opts.Layout {
+	case "unix", "unix-milli":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Timestamp{}, errs.BadValue{What: "timestamp",
+				Valid: "integer", Actual: parse.Quote(s)}
+		}
+		if opts.Layout == "unix" {
+			return Timestamp{time.Unix(n, 0).In(loc)}, nil
+		}
+		return Timestamp{time.UnixMilli(n).In(loc)}, nil
+	}
+	layout := opts.Layout
+	if l, ok := layouts[layout]; ok {
+		layout = l
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return Timestamp{}, err
+	}
+	return Timestamp{t}, nil
+}
+
+type formatOpts struct{ Layout string }
+
+func (o *formatOpts) SetDefaultOptions() { o.Layout = "rfc3339-nano" }
+
+func format(opts formatOpts, ts Timestamp) string {
+	switch opts.Layout {
+	case "unix":
+		return strconv.FormatInt(ts.t.Unix(), 10)
+	case "unix-milli":
+		return strconv.FormatInt(ts.t.UnixMilli(), 10)
+	}
+	layout := opts.Layout
+	if l, ok := layouts[layout]; ok {
+		layout = l
+	}
+	return ts.t.Format(layout)
+}
+
+func in(ts Timestamp, tz string) (Timestamp, error) {
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return Timestamp{}, err
+	}
+	return Timestamp{ts.t.In(loc)}, nil
+}
+
+func loadLocation(tz string) (*time.Location, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errs.BadValue{What: "time zone",
+			Valid:  "UTC, Local or name in the IANA time zone database",
+			Actual: parse.Quote(tz)}
+	}
+	return loc, nil
+}
+
+func duration(v any) (Duration, error) {
+	switch v := v.(type) {
+	case Duration:
+		return v, nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return Duration{d}, nil
+		}
+	}
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		return Duration{time.Duration(f * float64(time.Second))}, nil
+	}
+	return Duration{}, errs.BadValue{What: "duration",
+		Valid: "number of seconds or duration string", Actual: vals.ReprPlain(v)}
+}
+
+func seconds(d Duration) float64 { return d.d.Seconds() }
+
+func add(a, b any) (any, error) {
+	d, err := duration(b)
+	if err != nil {
+		return nil, err
+	}
+	switch a := a.(type) {
+	case Timestamp:
+		return Timestamp{a.t.Add(d.d)}, nil
+	case Duration:
+		return Duration{a.d + d.d}, nil
+	}
+	return nil, errs.BadValue{What: "first argument to time:add",
+		Valid: "timestamp or duration", Actual: vals.Kind(a)}
+}
+
+func sub(a, b any) (any, error) {
+	if a, ok := a.(Timestamp); ok {
+		if b, ok := b.(Timestamp); ok {
+			return Duration{a.t.Sub(b.t)}, nil
+		}
+	}
+	d, err := duration(b)
+	if err != nil {
+		return nil, err
+	}
+	switch a := a.(type) {
+	case Timestamp:
+		return Timestamp{a.t.Add(-d.d)}, nil
+	case Duration:
+		return Duration{a.d - d.d}, nil
+	}
+	return nil, errs.BadValue{What: "first argument to time:sub",
+		Valid: "timestamp or duration", Actual: vals.Kind(a)}
+}
+
+func since(ts Timestamp) Duration { return Duration{timeNow().Sub(ts.t)} }
@@ -0,0 +1,26 @@
+package time_test
+
+import (
+	"embed"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/eval/evaltest"
+	elvtime "src.elv.sh/pkg/mods/time"
+	"src.elv.sh/pkg/testutil"
+)
+
+//go:embed *.elvts *.elv
+var transcripts embed.FS
+
+func TestTranscripts(t *testing.T) {
+	evaltest.TestTranscriptsInFS(t, transcripts,
+		"mock-now", func(t *testing.T, s string) {
+			now, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.Set(t, elvtime.TimeNow, func() time.Time { return now })
+		},
+	)
+}
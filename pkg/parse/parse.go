@@ -22,7 +22,9 @@ import (
 	"bytes"
 	"io"
 	"math"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
 )
@@ -53,6 +55,11 @@ func Parse(src Source, cfg Config) (Tree, error) {
 func ParseAs(src Source, n Node, cfg Config) error {
 	ps := &parser{srcName: src.Name, src: src.Code, warn: cfg.WarningWriter}
 	parse(ps, n)
+	if ps.pos == len(ps.src) {
+		// Here-documents whose bodies have not started when the source ends,
+		// which are unterminated.
+		parseHereDocBodies(n, ps)
+	}
 	ps.done()
 	return diag.PackErrors(ps.errors)
 }
@@ -60,9 +67,12 @@ func ParseAs(src Source, n Node, cfg Config) error {
 // Errors.
 var (
 	errShouldBeForm               = newError("", "form")
-	errBadRedirSign               = newError("bad redir sign", "'<'", "'>'", "'>>'", "'<>'")
+	errBadRedirSign               = newError("bad redir sign", "'<'", "'>'", "'>>'", "'<>'", "'<<'", "'<<<'")
 	errShouldBeFD                 = newError("", "a composite term representing fd")
 	errShouldBeFilename           = newError("", "a composite term representing filename")
+	errShouldBeHereString         = newError("", "a composite term representing here-string")
+	errShouldBeHereDocDelimiter   = newError("", "here-document delimiter")
+	errHereDocUnterminated        = newError("here-document not terminated")
 	errShouldBeArray              = newError("", "spaced")
 	errStringUnterminated         = newError("string not terminated")
 	errInvalidEscape              = newError("invalid escape sequence")
@@ -111,6 +121,9 @@ func (bn *Chunk) parseSeps(ps *parser) int {
 		if isPipelineSep(r) {
 			// parse as a Sep
 			parseSep(bn, ps, r)
+			if r == '\n' {
+				parseHereDocBodies(bn, ps)
+			}
 			nseps++
 		} else if IsInlineWhitespace(r) || r == '#' {
 			// parse a run of spaces as a Sep
@@ -122,7 +135,7 @@ func (bn *Chunk) parseSeps(ps *parser) int {
 	return nseps
 }
 
-// Pipeline = Form { '|' Form }
+// Pipeline = Form { ( '|' | '|&' ) Form }
 type Pipeline struct {
 	node
 	Forms      []*Form
@@ -131,7 +144,13 @@ type Pipeline struct {
 
 func (pn *Pipeline) parse(ps *parser) {
 	parse(ps, &Form{}).addTo(&pn.Forms, pn)
-	for parseSep(pn, ps, '|') {
+	for ps.peek() == '|' {
+		ps.next()
+		if ps.peek() == '&' {
+			ps.next()
+			pn.Forms[len(pn.Forms)-1].PipesStderr = true
+		}
+		addSep(pn, ps)
 		parseSpacesAndNewlines(pn, ps)
 		if !startsForm(ps.peek()) {
 			ps.error(errShouldBeForm)
@@ -159,6 +178,9 @@ type Form struct {
 	Args   []*Compound
 	Opts   []*MapPair
 	Redirs []*Redir
+	// Whether the form is followed by "|&" in a pipeline, so its stderr is
+	// piped to the next form along with its stdout. Set by Pipeline.
+	PipesStderr bool
 }
 
 func (fn *Form) parse(ps *parser) {
@@ -177,7 +199,7 @@ func (fn *Form) parse(ps *parser) {
 				return
 			}
 			parse(ps, &MapPair{}).addTo(&fn.Opts, fn)
-		case startsCompound(r, NormalExpr):
+		case startsCompound(r, NormalExpr) || startsProcessSubstitution(ps, NormalExpr):
 			cn := &Compound{}
 			parse(ps, cn)
 			if isRedirSign(ps.peek()) {
@@ -200,13 +222,17 @@ func startsForm(r rune) bool {
 	return IsInlineWhitespace(r) || startsCompound(r, CmdExpr)
 }
 
-// Redir = { Compound } { '<'|'>'|'<>'|'>>' } { Space } ( '&'? Compound )
+// Redir = { Compound } { '<'|'>'|'<>'|'>>'|'<<<' } { Space } ( '&'? Compound )
+// Redir = { Compound } '<<' { Space } [ '~' ] Delimiter
 type Redir struct {
 	node
 	Left      *Compound
 	Mode      RedirMode
 	RightIsFd bool
-	Right     *Compound
+	Right     *Compound // Valid when Mode != ReadHereDoc
+	// Valid when Mode == ReadHereDoc. The HereDoc node is not a child of the
+	// Redir node; see the documentation of HereDoc.
+	HereDoc *HereDoc
 }
 
 func (rn *Redir) parse(ps *parser) {
@@ -230,19 +256,38 @@ func (rn *Redir) parse(ps *parser) {
 		rn.Mode = Append
 	case "<>":
 		rn.Mode = ReadWrite
+	case "<<":
+		rn.Mode = ReadHereDoc
+	case "<<<":
+		rn.Mode = ReadHereString
 	default:
 		ps.error(errBadRedirSign)
 	}
 	addSep(rn, ps)
 	parseSpaces(rn, ps)
-	if parseSep(rn, ps, '&') {
+	if rn.Mode == ReadHereDoc {
+		rn.HereDoc = &HereDoc{}
+		if rn.HereDoc.parseDelimiter(ps) {
+			addSep(rn, ps)
+			ps.hereDocs = append(ps.hereDocs, rn.HereDoc)
+		}
+		return
+	}
+	if rn.Mode != ReadHereString && parseSep(rn, ps, '&') {
 		rn.RightIsFd = true
 	}
 	parse(ps, &Compound{}).addAs(&rn.Right, rn)
 	if len(rn.Right.Indexings) == 0 {
 		if rn.RightIsFd {
 			ps.error(errShouldBeFD)
+		} else if rn.Mode == ReadHereString {
+			ps.error(errShouldBeHereString)
 		} else {
+			if rest := ps.src[ps.pos:]; rest == "<" || rest == ">" {
+				// The incomplete start of a process substitution; consume it
+				// so that the error is at the end of the code.
+				ps.next()
+			}
 			ps.error(errShouldBeFilename)
 		}
 		return
@@ -263,8 +308,184 @@ const (
 	Write
 	ReadWrite
 	Append
+	// Redirection from a here-document, <<.
+	ReadHereDoc
+	// Redirection from a here-string, <<<.
+	ReadHereString
 )
 
+// HereDoc = { Line } { Space } Delimiter
+//
+// A HereDoc is the body of a here-document redirection, a Redir like "<<EOF".
+// The body consists of the lines after the line with the Redir, up to a line
+// consisting of the delimiter, optionally indented. The rest of the line with
+// the Redir is parsed as usual, so the form and the pipeline can continue
+// there. The delimiter is a bareword, single-quoted or double-quoted string.
+//
+// Since the body doesn't follow the Redir in the source, the HereDoc node is
+// not a child of the Redir node, but of the node that contains the line break
+// before the body. The delimiter is in a Sep child of the Redir node.
+type HereDoc struct {
+	node
+	// The delimiter that terminates the body.
+	Delimiter string
+	// Whether the delimiter is quoted. Variables are only interpolated in the
+	// body when it is not.
+	Quoted bool
+	// Whether the delimiter is preceded by "~". If it is, the common
+	// indentation of the non-blank lines is removed from the body.
+	Dedent bool
+	// The body is Texts[0], followed by the value of Vars[0], followed by
+	// Texts[1], and so on. There is always one more element in Texts than in
+	// Vars.
+	Texts []string
+	// Variables interpolated in the body, all of which have type Variable.
+	Vars []*Primary
+}
+
+// Parses the delimiter of a here-document after "<<", and reports whether it is
+// valid.
+func (hn *HereDoc) parseDelimiter(ps *parser) bool {
+	if ps.peek() == '~' {
+		ps.next()
+		hn.Dedent = true
+	}
+	switch r := ps.peek(); {
+	case r == '\'' || r == '"':
+		var pn Primary
+		if r == '\'' {
+			pn.singleQuoted(ps)
+		} else {
+			pn.doubleQuoted(ps)
+		}
+		hn.Delimiter, hn.Quoted = pn.Value, true
+	default:
+		begin := ps.pos
+		for allowedInBareword(ps.peek(), strictExpr) {
+			ps.next()
+		}
+		hn.Delimiter = ps.src[begin:ps.pos]
+	}
+	if hn.Delimiter == "" {
+		ps.error(errShouldBeHereDocDelimiter)
+		return false
+	}
+	return true
+}
+
+// Parses the bodies of here-documents whose delimiters have been parsed, called
+// after a line break has been parsed in n.
+func parseHereDocBodies(n Node, ps *parser) {
+	if len(ps.hereDocs) == 0 {
+		return
+	}
+	addSep(n, ps)
+	for _, hn := range ps.hereDocs {
+		addChild(n, parse(ps, hn).n)
+	}
+	ps.hereDocs = nil
+}
+
+func (hn *HereDoc) parse(ps *parser) {
+	indent := ""
+	if hn.Dedent {
+		indent = hereDocIndent(ps.src[ps.pos:], hn.Delimiter)
+	}
+	var text strings.Builder
+	for {
+		if ps.pos == len(ps.src) {
+			ps.error(errHereDocUnterminated)
+			break
+		}
+		if n, ok := hereDocEnd(ps.src[ps.pos:], hn.Delimiter); ok {
+			addSep(hn, ps)
+			ps.pos += n
+			addSep(hn, ps)
+			break
+		}
+		if rest := ps.src[ps.pos:]; strings.HasPrefix(rest, indent) {
+			ps.pos += len(indent)
+		} else if hn.Dedent {
+			// A blank line with less indentation.
+			ps.pos += len(rest) - len(strings.TrimLeft(rest, " \t"))
+		}
+		// Parse the rest of the line.
+		for {
+			r := ps.peek()
+			if r == eof {
+				break
+			}
+			if r == '$' && !hn.Quoted && startsInterpolation(ps.src[ps.pos+1:]) {
+				addSep(hn, ps)
+				hn.Texts = append(hn.Texts, text.String())
+				text.Reset()
+				parse(ps, &Primary{}).addTo(&hn.Vars, hn)
+				continue
+			}
+			ps.next()
+			text.WriteRune(r)
+			if r == '\n' {
+				break
+			}
+		}
+	}
+	hn.Texts = append(hn.Texts, text.String())
+}
+
+// Reports whether s starts with a line that terminates a here-document with
+// the given delimiter, which is a line consisting of the delimiter, optionally
+// indented. If it does, also returns the length of the line, including the
+// line break.
+func hereDocEnd(s, delim string) (int, bool) {
+	trimmed := strings.TrimLeft(s, " \t")
+	if !strings.HasPrefix(trimmed, delim) {
+		return 0, false
+	}
+	n := len(s) - len(trimmed) + len(delim)
+	switch rest := s[n:]; {
+	case rest == "":
+		return n, true
+	case rest[0] == '\n':
+		return n + 1, true
+	case strings.HasPrefix(rest, "\r\n"):
+		return n + 2, true
+	default:
+		return 0, false
+	}
+}
+
+// Returns the common indentation of the non-blank lines in the body of a
+// here-document, which starts at the beginning of s.
+func hereDocIndent(s, delim string) string {
+	indent, found := "", false
+	for s != "" {
+		if _, ok := hereDocEnd(s, delim); ok {
+			break
+		}
+		line, rest, _ := strings.Cut(s, "\n")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && trimmed != "\r" {
+			lineIndent := line[:len(line)-len(trimmed)]
+			if !found {
+				indent, found = lineIndent, true
+			} else {
+				for !strings.HasPrefix(lineIndent, indent) {
+					indent = indent[:len(indent)-1]
+				}
+			}
+		}
+		s = rest
+	}
+	return indent
+}
+
+// Reports whether a "$" followed by s starts a variable interpolated in a
+// here-document.
+func startsInterpolation(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return s != "" && (allowedInVariableName(r) || r == '\'' || r == '"')
+}
+
 // Filter is the Elvish filter DSL. It uses the same syntax as arguments and
 // options to a command.
 type Filter struct {
@@ -320,6 +541,11 @@ const (
 
 func (cn *Compound) parse(ps *parser) {
 	cn.tilde(ps)
+	if len(cn.Indexings) == 0 && startsProcessSubstitution(ps, cn.ExprCtx) {
+		// A process substitution can only appear as the first part of a
+		// Compound, since "<" and ">" elsewhere start redirections.
+		parse(ps, &Indexing{ExprCtx: cn.ExprCtx}).addTo(&cn.Indexings, cn)
+	}
 	for startsIndexing(ps.peek(), cn.ExprCtx) {
 		parse(ps, &Indexing{ExprCtx: cn.ExprCtx}).addTo(&cn.Indexings, cn)
 	}
@@ -407,7 +633,8 @@ type Primary struct {
 	// DoubleQuoted, Variable, Wildcard and Tilde.
 	Value    string
 	Elements []*Compound // Valid for List and Lambda
-	Chunk    *Chunk      // Valid for OutputCapture, ExitusCapture and Lambda
+	// Valid for OutputCapture, ExitusCapture, process substitutions and Lambda
+	Chunk    *Chunk
 	MapPairs []*MapPair  // Valid for Map and Lambda
 	Braced   []*Compound // Valid for Braced
 }
@@ -430,9 +657,18 @@ const (
 	Lambda
 	Map
 	Braced
+	// A process substitution <(...), whose output can be read from a file.
+	ReadSubstitution
+	// A process substitution >(...), whose input can be written to a file.
+	WriteSubstitution
 )
 
 func (pn *Primary) parse(ps *parser) {
+	if startsProcessSubstitution(ps, pn.ExprCtx) {
+		pn.processSubstitution(ps)
+		return
+	}
+
 	r := ps.peek()
 	if !startsPrimary(r, pn.ExprCtx) {
 		ps.error(errShouldBePrimary)
@@ -733,6 +969,29 @@ func (pn *Primary) outputCapture(ps *parser) {
 	}
 }
 
+// ProcessSubstitution = ( '<(' | '>(' ) Chunk ')'
+func (pn *Primary) processSubstitution(ps *parser) {
+	if ps.next() == '<' {
+		pn.Type = ReadSubstitution
+	} else {
+		pn.Type = WriteSubstitution
+	}
+	ps.next()
+	addSep(pn, ps)
+
+	parse(ps, &Chunk{}).addAs(&pn.Chunk, pn)
+
+	if !parseSep(pn, ps, ')') {
+		ps.error(errShouldBeRParen)
+	}
+}
+
+// Reports whether a process substitution starts at the current position. In
+// command position, "<" and ">" are bareword characters instead.
+func startsProcessSubstitution(ps *parser, ctx ExprCtx) bool {
+	return ctx != CmdExpr && (ps.hasPrefix("<(") || ps.hasPrefix(">("))
+}
+
 // List   = '[' { Space } { Compound } ']'
 //        = '[' { Space } { MapPair { Space } } ']'
 // Map    = '[' { Space } '&' { Space } ']'
@@ -954,6 +1213,9 @@ spaces:
 			ps.next()
 		case newlines && IsWhitespace(r):
 			ps.next()
+			if r == '\n' {
+				parseHereDocBodies(n, ps)
+			}
 		case r == '#':
 			// Comment is like inline whitespace as long as we don't include the
 			// trailing newline.
@@ -973,9 +1235,11 @@ spaces:
 				ps.next()
 				if ps.peek() == '\n' {
 					ps.next()
+					parseHereDocBodies(n, ps)
 				}
 			case '\n':
 				ps.next()
+				parseHereDocBodies(n, ps)
 			case eof:
 				ps.error(errShouldBeNewline)
 			default:
@@ -54,6 +54,17 @@ var testCases = []struct {
 		node: &Pipeline{},
 		want: ast{"Pipeline", fs{"Forms": []string{"a", "b"}}},
 	},
+	{
+		name: "pipeline with stderr piped",
+		code: "a|&b | c |& d",
+		node: &Pipeline{},
+		want: ast{"Pipeline", fs{"Forms": []ast{
+			{"Form", fs{"Head": "a", "PipesStderr": true}},
+			{"Form", fs{"Head": "b"}},
+			{"Form", fs{"Head": "c", "PipesStderr": true}},
+			{"Form", fs{"Head": "d"}},
+		}}},
+	},
 
 	{
 		name:         "no form after pipe",
@@ -62,6 +73,13 @@ var testCases = []struct {
 		wantErrAtEnd: true,
 		wantErrMsg:   "should be form",
 	},
+	{
+		name:         "no form after pipe with stderr",
+		code:         "a|&",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "should be form",
+	},
 
 	// Form
 	{
@@ -130,6 +148,138 @@ var testCases = []struct {
 		wantErrAtEnd: true,
 		wantErrMsg:   "should be a composite term representing fd",
 	},
+	{
+		name: "here-string",
+		code: "a <<< $b 3<<<c",
+		node: &Form{},
+		want: ast{"Form", fs{
+			"Head": "a",
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereString, "Right": "$b"}},
+				{"Redir", fs{"Left": "3", "Mode": ReadHereString, "Right": "c"}},
+			},
+		}},
+	},
+	{
+		name:         "no here-string",
+		code:         "a <<<",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "should be a composite term representing here-string",
+	},
+	{
+		name: "here-document",
+		code: "a x <<EOF > c\nfoo $x!\n$y:$'z'\nEOF",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []ast{{"Pipeline/Form", fs{
+			"Head": "a",
+			"Args": []string{"x"},
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "EOF", "Quoted": false, "Dedent": false,
+					"Texts": []string{"foo ", "!\n", "", "\n"},
+					"Vars":  []string{"$x", "$y:", "$'z'"},
+				}}}},
+				{"Redir", fs{"Mode": Write, "Right": "c"}},
+			},
+		}}}}},
+	},
+	{
+		name: "pipeline continuing after here-document delimiter",
+		code: "a <<EOF | b\nfoo\nEOF\nc",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []any{
+			ast{"Pipeline", fs{"Forms": []ast{
+				{"Form", fs{"Head": "a", "Redirs": []ast{
+					{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+						"Delimiter": "EOF", "Texts": []string{"foo\n"},
+					}}}},
+				}}},
+				{"Form", fs{"Head": "b"}},
+			}}},
+			"c",
+		}}},
+	},
+	{
+		name: "multiple here-documents on the same line",
+		code: "a <<A; b <<B\nfoo\nA\nbar\nB",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []ast{
+			{"Pipeline/Form", fs{"Head": "a", "Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "A", "Texts": []string{"foo\n"},
+				}}}},
+			}}},
+			{"Pipeline/Form", fs{"Head": "b", "Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "B", "Texts": []string{"bar\n"},
+				}}}},
+			}}},
+		}}},
+	},
+	{
+		name: "here-document with lines starting with the delimiter",
+		code: "a << EOF\nEOF is my delimiter\nEOF; EOF\n  EOF",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []ast{{"Pipeline/Form", fs{
+			"Head": "a",
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "EOF",
+					"Texts":     []string{"EOF is my delimiter\nEOF; EOF\n"},
+				}}}},
+			},
+		}}}}},
+	},
+	{
+		name: "here-document with quoted delimiter",
+		code: "a << 'E F'\n$x\n  E F",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []ast{{"Pipeline/Form", fs{
+			"Head": "a",
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "E F", "Quoted": true,
+					"Texts": []string{"$x\n"}, "Vars": []string{},
+				}}}},
+			},
+		}}}}},
+	},
+	{
+		name: "here-document with indentation removed",
+		code: "a <<~EOF\n    foo\n\n      bar $\n  EOFX\n  EOF",
+		node: &Chunk{},
+		want: ast{"Chunk", fs{"Pipelines": []ast{{"Pipeline/Form", fs{
+			"Head": "a",
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": ReadHereDoc, "HereDoc": ast{"HereDoc", fs{
+					"Delimiter": "EOF", "Dedent": true,
+					"Texts": []string{"  foo\n\n    bar $\nEOFX\n"},
+				}}}},
+			},
+		}}}}},
+	},
+	{
+		name:         "no here-document delimiter",
+		code:         "a <<",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "should be here-document delimiter",
+	},
+	{
+		name:         "unterminated here-document",
+		code:         "a <<EOF\nfoo\n",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "here-document not terminated",
+	},
+	{
+		name:         "here-document without body",
+		code:         "a <<EOF | b",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "here-document not terminated",
+	},
 
 	// Filter
 	{
@@ -382,6 +532,28 @@ var testCases = []struct {
 				"Type": ExceptionCapture, "Chunk": "b;c",
 			}}),
 	},
+	{
+		name: "process substitution",
+		code: "a <(b;c) >(d) < <(e)",
+		node: &Chunk{},
+		want: ast{"Chunk/Pipeline/Form", fs{
+			"Head": "a",
+			"Args": []ast{
+				{"Compound/Indexing/Primary", fs{
+					"Type": ReadSubstitution, "Chunk": "b;c"}},
+				{"Compound/Indexing/Primary", fs{
+					"Type": WriteSubstitution, "Chunk": "d"}},
+			},
+			"Redirs": []ast{
+				{"Redir", fs{"Mode": Read, "Right": "<(e)"}}},
+		}},
+	},
+	{
+		name: "process substitution in command position",
+		code: "<(a) b",
+		node: &Form{},
+		want: ast{"Form", fs{"Head": "<(a)", "Args": []string{"b"}}},
+	},
 	{
 		name: "braced list",
 		code: "{,a,c\ng\n}",
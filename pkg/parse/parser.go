@@ -21,6 +21,9 @@ type parser struct {
 	overEOF int
 	errors  []*Error
 	warn    io.Writer
+	// Here-documents whose delimiters have been parsed, but not their bodies,
+	// which start after the next line break.
+	hereDocs []*HereDoc
 }
 
 // Error is a parse error.
@@ -12,7 +12,7 @@ var n = mustParse("ls $x[0]$y[1];echo done >/redir-dest")
 var pprintASTTests = []*tt.Case{
 	Args(n).Rets(
 		`Chunk
-  Pipeline/Form
+  Pipeline/Form PipesStderr=false
     Compound/Indexing/Primary ExprCtx=CmdExpr Type=Bareword Value="ls"
     Compound ExprCtx=NormalExpr
       Indexing ExprCtx=NormalExpr
@@ -21,7 +21,7 @@ var pprintASTTests = []*tt.Case{
       Indexing ExprCtx=NormalExpr
         Primary ExprCtx=NormalExpr Type=Variable Value="y"
         Array/Compound/Indexing/Primary ExprCtx=NormalExpr Type=Bareword Value="1"
-  Pipeline/Form
+  Pipeline/Form PipesStderr=false
     Compound/Indexing/Primary ExprCtx=CmdExpr Type=Bareword Value="echo"
     Compound/Indexing/Primary ExprCtx=NormalExpr Type=Bareword Value="done"
     Redir Mode=Write RightIsFd=false
@@ -21,11 +21,13 @@ func _() {
 	_ = x[Lambda-10]
 	_ = x[Map-11]
 	_ = x[Braced-12]
+	_ = x[ReadSubstitution-13]
+	_ = x[WriteSubstitution-14]
 }
 
-const _PrimaryType_name = "BadPrimaryBarewordSingleQuotedDoubleQuotedVariableWildcardTildeExceptionCaptureOutputCaptureListLambdaMapBraced"
+const _PrimaryType_name = "BadPrimaryBarewordSingleQuotedDoubleQuotedVariableWildcardTildeExceptionCaptureOutputCaptureListLambdaMapBracedReadSubstitutionWriteSubstitution"
 
-var _PrimaryType_index = [...]uint8{0, 10, 18, 30, 42, 50, 58, 63, 79, 92, 96, 102, 105, 111}
+var _PrimaryType_index = [...]uint8{0, 10, 18, 30, 42, 50, 58, 63, 79, 92, 96, 102, 105, 111, 127, 144}
 
 func (i PrimaryType) String() string {
 	if i < 0 || i >= PrimaryType(len(_PrimaryType_index)-1) {
@@ -42,11 +44,13 @@ func _() {
 	_ = x[Write-2]
 	_ = x[ReadWrite-3]
 	_ = x[Append-4]
+	_ = x[ReadHereDoc-5]
+	_ = x[ReadHereString-6]
 }
 
-const _RedirMode_name = "BadRedirModeReadWriteReadWriteAppend"
+const _RedirMode_name = "BadRedirModeReadWriteReadWriteAppendReadHereDocReadHereString"
 
-var _RedirMode_index = [...]uint8{0, 12, 16, 21, 30, 36}
+var _RedirMode_index = [...]uint8{0, 12, 16, 21, 30, 36, 47, 61}
 
 func (i RedirMode) String() string {
 	if i < 0 || i >= RedirMode(len(_RedirMode_index)-1) {
@@ -34,7 +34,7 @@ func (fs *FlagSet) DaemonPaths() *DaemonPaths {
 func (fs *FlagSet) JSON() *bool {
 	if fs.json == nil {
 		fs.json = fs.Bool("json", false,
-			"Show the output from -buildinfo, -compileonly or -version in JSON")
+			"Show the output from -buildinfo, -compileonly, -lint or -version in JSON")
 	}
 	return fs.json
 }
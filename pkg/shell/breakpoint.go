@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+)
+
+var errBreakpointAborted = errors.New("aborted at breakpoint")
+
+// Returns a function to use as [eval.Evaler.OnBreakpoint], which runs a nested
+// REPL reading code with ed. The code is evaluated in the local and captured
+// variables of the Frame calling the breakpoint builtin, plus a bp: namespace
+// with commands to control the REPL. Breakpoints hit concurrently, like those in
+// multiple forms of a pipeline, run the REPL one at a time.
+func breakpointREPL(fds [3]*os.File, ev *eval.Evaler, ed editor) func(*eval.Frame) error {
+	var mu sync.Mutex
+	return func(fm *eval.Frame) error {
+		mu.Lock()
+		defer mu.Unlock()
+		var resume, abort bool
+		bpNs := eval.BuildNsNamed("bp").AddGoFns(map[string]any{
+			"stack": func(out *eval.Frame) error {
+				_, err := io.WriteString(out.ByteOutput(), showStack(fm.StackTrace()))
+				return err
+			},
+			"continue": func() { resume = true },
+			"abort":    func() { abort = true },
+		}).Ns()
+		ns := eval.CombineNs(eval.CombineNs(fm.Up(), fm.Local()),
+			eval.BuildNs().AddNs("bp", bpNs).Ns())
+
+		fmt.Fprint(fds[2], "Paused at breakpoint:"+showStackHead(fm.StackTrace())+"\n")
+		fmt.Fprintln(fds[2], "Use bp:continue or Ctrl-D to continue, bp:abort to abort, "+
+			"or bp:stack to show the stack.")
+		for i := 1; ; i++ {
+			code, err := ed.ReadCode()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if strings.TrimSpace(code) == "" {
+				continue
+			}
+			err = evalInBreakpoint(fds, ev, ns,
+				parse.Source{Name: fmt.Sprintf("[breakpoint %v]", i), Code: code})
+			if err != nil {
+				diag.ShowError(fds[2], err)
+			}
+			if abort {
+				return errBreakpointAborted
+			}
+			if resume {
+				return nil
+			}
+		}
+	}
+}
+
+// Like evalInTTY, but evaluates in the given namespace.
+func evalInBreakpoint(fds [3]*os.File, ev *eval.Evaler, ns *eval.Ns, src parse.Source) error {
+	ports, cleanup := eval.PortsFromFiles(fds, ev.ValuePrefix())
+	defer cleanup()
+	restore := term.SetupForEval(fds[0], fds[1])
+	defer restore()
+	ctx, done := eval.ListenInterrupts()
+	defer done()
+	return ev.Eval(src, eval.EvalCfg{Ports: ports, Interrupts: ctx, Global: ns})
+}
+
+func showStack(st *eval.StackTrace) string {
+	var sb strings.Builder
+	for ; st != nil; st = st.Next {
+		sb.WriteString("  " + st.Head.Show("  ") + "\n")
+	}
+	return sb.String()
+}
+
+func showStackHead(st *eval.StackTrace) string {
+	if st == nil {
+		return ""
+	}
+	return "\n  " + st.Head.Show("  ")
+}
@@ -0,0 +1,122 @@
+package shell
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/must"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+type fakeEditor struct{ lines []string }
+
+func (ed *fakeEditor) ReadCode() (string, error) {
+	if len(ed.lines) == 0 {
+		return "", io.EOF
+	}
+	line := ed.lines[0]
+	ed.lines = ed.lines[1:]
+	return line, nil
+}
+
+func (ed *fakeEditor) RunAfterCommandHooks(parse.Source, float64, error) {}
+
+var breakpointCode = `
+var x = old
+fn f {|y|
+  breakpoint
+  put $x $y
+}
+f arg
+`
+
+var breakpointTests = []struct {
+	name string
+	// Defaults to breakpointCode.
+	code       string
+	lines      []string
+	wantValues []any
+	wantErr    error
+	wantOutput []string
+}{
+	{
+		name:       "inspect and modify variables",
+		lines:      []string{"put $x $y", "set x = new", "set y = new-arg", "bp:continue", "unreachable"},
+		wantValues: []any{"new", "new-arg"},
+		wantOutput: []string{"▶ old\n▶ arg\n", "Paused at breakpoint", "breakpoint"},
+	},
+	{
+		name:       "show stack",
+		lines:      []string{"bp:stack"},
+		wantValues: []any{"old", "arg"},
+		wantOutput: []string{"[test]:4:3-12", "[test]:7:1-5"},
+	},
+	{
+		name:       "show errors",
+		lines:      []string{"fail bad"},
+		wantValues: []any{"old", "arg"},
+		wantOutput: []string{"[breakpoint 1]:1:1-8"},
+	},
+	{
+		name:       "abort",
+		lines:      []string{"bp:abort"},
+		wantValues: []any{},
+		wantErr:    errBreakpointAborted,
+	},
+	{
+		name:       "concurrent breakpoints",
+		code:       "{ breakpoint } | { breakpoint; put b }",
+		lines:      []string{"bp:continue", "bp:continue"},
+		wantValues: []any{"b"},
+	},
+	{
+		name:    "peach",
+		code:    "peach {|x| breakpoint } [1]",
+		wantErr: eval.ErrBreakpointNotInForeground,
+	},
+	{
+		name:    "spawn",
+		code:    "await (spawn { breakpoint })",
+		wantErr: eval.ErrBreakpointNotInForeground,
+	},
+}
+
+func TestBreakpointREPL(t *testing.T) {
+	for _, test := range breakpointTests {
+		t.Run(test.name, func(t *testing.T) {
+			out := must.OK1(os.Create(testutil.TempDir(t) + "/out"))
+			defer out.Close()
+			fds := [3]*os.File{eval.DevNull, out, out}
+
+			ev := eval.NewEvaler()
+			ev.OnBreakpoint = breakpointREPL(fds, ev, &fakeEditor{test.lines})
+			code := test.code
+			if code == "" {
+				code = breakpointCode
+			}
+			port, collect := must.OK2(eval.ValueCapturePort())
+			err := ev.Eval(parse.Source{Name: "[test]", Code: code},
+				eval.EvalCfg{Ports: []*eval.Port{nil, port}})
+			values := collect()
+
+			if !errors.Is(eval.Reason(err), test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if !slices.Equal(values, test.wantValues) {
+				t.Errorf("got values %v, want %v", values, test.wantValues)
+			}
+			output := string(must.OK1(os.ReadFile(out.Name())))
+			for _, want := range test.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("output %q doesn't contain %q", output, want)
+				}
+			}
+		})
+	}
+}
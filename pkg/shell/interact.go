@@ -34,6 +34,9 @@ type interactCfg struct {
 
 	ActivateDaemon daemondefs.ActivateFunc
 	SpawnConfig    *daemondefs.SpawnConfig
+
+	// Whether to enable job control when evaluating code.
+	JobControl bool
 }
 
 // Interface satisfied by the line editor. Used for swapping out the editor with
@@ -77,6 +80,7 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 		newed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, daemonClient)
 		ev.ExtendBuiltin(eval.BuildNs().AddNs("edit", newed))
 		ev.BgJobNotify = func(s string) { newed.Notify(ui.T(s)) }
+		ev.OnBreakpoint = breakpointREPL(fds, ev, newed)
 		ed = newed
 	} else {
 		ed = newMinEditor(fds[0], fds[2])
@@ -84,7 +88,7 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 
 	// Source rc.elv.
 	if cfg.RC != "" {
-		err := sourceRC(fds, ev, ed, cfg.RC)
+		err := sourceRC(fds, ev, ed, cfg.RC, cfg.JobControl)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
@@ -125,7 +129,8 @@ func interact(ev *eval.Evaler, fds [3]*os.File, cfg *interactCfg) {
 			continue
 		}
 		err = evalInTTY(fds, ev, ed,
-			parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line})
+			parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line},
+			eval.EvalCfg{JobControl: cfg.JobControl})
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
@@ -145,7 +150,7 @@ func handlePanic() {
 	}
 }
 
-func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string) error {
+func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string, jobControl bool) error {
 	absPath, err := filepath.Abs(rcPath)
 	if err != nil {
 		return fmt.Errorf("cannot get full path of rc.elv: %v", err)
@@ -157,7 +162,9 @@ func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed editor, rcPath string) error
 		}
 		return err
 	}
-	return evalInTTY(fds, ev, ed, parse.Source{Name: absPath, Code: code, IsFile: true})
+	return evalInTTY(fds, ev, ed,
+		parse.Source{Name: absPath, Code: code, IsFile: true},
+		eval.EvalCfg{JobControl: jobControl})
 }
 
 type minEditor struct {
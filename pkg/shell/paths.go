@@ -20,7 +20,9 @@ func rcPath() (string, error) {
 	}
 }
 
-func libPaths() ([]string, error) {
+// LibPaths returns the directories to search for modules, which are used as
+// [eval.Evaler.LibDirs].
+func LibPaths() ([]string, error) {
 	var paths []string
 
 	if configHome := os.Getenv(env.XDG_CONFIG_HOME); configHome != "" {
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
@@ -19,6 +22,13 @@ type scriptCfg struct {
 	Cmd         bool
 	CompileOnly bool
 	JSON        bool
+	// If not empty, the script is profiled, and the profile is written to
+	// this path in pprof format.
+	Profile string
+	// If not empty, the coverage of the script is written to this path, in
+	// the format specified by CoverageFormat.
+	Coverage       string
+	CoverageFormat string
 }
 
 // Executes a shell script.
@@ -62,9 +72,35 @@ func script(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *scriptCfg) int
 			return 2
 		}
 	} else {
-		err := evalInTTY(fds, ev, nil, src)
+		if cfg.Coverage != "" {
+			if !slices.Contains(eval.CoverageFormats, cfg.CoverageFormat) {
+				fmt.Fprintf(fds[2], "unknown coverage format %q, must be one of %s\n",
+					cfg.CoverageFormat, strings.Join(eval.CoverageFormats, ", "))
+				return 2
+			}
+			ev.Coverage = eval.NewCoverage()
+		}
+		var profiler *eval.Profiler
+		if cfg.Profile != "" {
+			profiler = eval.NewProfiler(0)
+		}
+		err := evalInTTY(fds, ev, nil, src, eval.EvalCfg{Profiler: profiler})
 		if err != nil {
 			diag.ShowError(fds[2], err)
+		}
+		if profiler != nil {
+			if errProfile := writeProfile(fds[2], cfg.Profile, profiler.Stop()); errProfile != nil {
+				fmt.Fprintf(fds[2], "cannot write profile: %v\n", errProfile)
+				return 2
+			}
+		}
+		if ev.Coverage != nil {
+			if errCoverage := writeCoverage(cfg.Coverage, cfg.CoverageFormat, ev.Coverage); errCoverage != nil {
+				fmt.Fprintf(fds[2], "cannot write coverage: %v\n", errCoverage)
+				return 2
+			}
+		}
+		if err != nil {
 			return 2
 		}
 	}
@@ -72,6 +108,38 @@ func script(ev *eval.Evaler, fds [3]*os.File, args []string, cfg *scriptCfg) int
 	return 0
 }
 
+// Writes the profile to the named file in pprof format, and a report of the
+// top entries to w.
+func writeProfile(w io.Writer, name string, prof *eval.Profile) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = prof.WritePprof(file)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return prof.WriteReport(w, profileReportTop)
+}
+
+func writeCoverage(name, format string, c *eval.Coverage) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = c.Write(file, format)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// Number of entries in the report written by the -profile flag.
+const profileReportTop = 10
+
 var errSourceNotUTF8 = errors.New("source is not UTF-8")
 
 func readFileUTF8(fname string) (string, error) {
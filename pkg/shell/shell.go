@@ -50,6 +50,9 @@ type Program struct {
 	rc          string
 	json        *bool
 	daemonPaths *prog.DaemonPaths
+	profile     string
+	coverage    string
+	coverageFmt string
 }
 
 func (p *Program) RegisterFlags(fs *prog.FlagSet) {
@@ -67,6 +70,12 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 		"Don't read the RC file when running interactively")
 	fs.StringVar(&p.rc, "rc", "",
 		"Path to the RC file when running interactively")
+	fs.StringVar(&p.profile, "profile", "",
+		"Profile the Elvish code when running a script, writing a pprof profile to the file")
+	fs.StringVar(&p.coverage, "coverage", "",
+		"Record the line coverage of Elvish code when running a script, writing it to the file")
+	fs.StringVar(&p.coverageFmt, "coverage-format", "lcov",
+		"Format of the file written by -coverage: lcov, summary or annotated")
 
 	p.json = fs.JSON()
 	if p.ActivateDaemon != nil {
@@ -75,21 +84,25 @@ func (p *Program) RegisterFlags(fs *prog.FlagSet) {
 }
 
 func (p *Program) Run(fds [3]*os.File, args []string) error {
+	interactive := len(args) == 0
+	// Job control is only enabled when running interactively in a terminal.
+	jobControl := interactive && sys.IsATTY(fds[0].Fd())
+
 	cleanup1 := incSHLVL()
 	defer cleanup1()
-	cleanup2 := initSignal(fds)
+	cleanup2 := initSignal(fds, jobControl)
 	defer cleanup2()
 
 	// https://no-color.org
 	ui.NoColor = os.Getenv(env.NO_COLOR) != ""
-	interactive := len(args) == 0
 	ev := p.makeEvaler(fds[2], interactive)
 	defer ev.PreExit()
 
 	if !interactive {
 		exit := script(
 			ev, fds, args, &scriptCfg{
-				Cmd: p.codeInArg, CompileOnly: p.compileOnly, JSON: *p.json})
+				Cmd: p.codeInArg, CompileOnly: p.compileOnly, JSON: *p.json,
+				Profile: p.profile, Coverage: p.coverage, CoverageFormat: p.coverageFmt})
 		return prog.Exit(exit)
 	}
 
@@ -105,7 +118,8 @@ func (p *Program) Run(fds [3]*os.File, args []string) error {
 
 	interact(ev, fds, &interactCfg{
 		RC:             ev.EffectiveRcPath,
-		ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg})
+		ActivateDaemon: p.ActivateDaemon, SpawnConfig: spawnCfg,
+		JobControl: jobControl})
 	return nil
 }
 
@@ -138,7 +152,7 @@ func (p *Program) makeEvaler(stderr io.Writer, interactive bool) *eval.Evaler {
 		}
 	}
 
-	libs, err := libPaths()
+	libs, err := LibPaths()
 	if err != nil {
 		fmt.Fprintln(stderr, "Warning: resolving lib paths:", err)
 	} else {
@@ -166,8 +180,11 @@ func incSHLVL() func() {
 	}
 }
 
-func initSignal(fds [3]*os.File) func() {
+func initSignal(fds [3]*os.File, jobControl bool) func() {
 	sigCh := sys.NotifySignals()
+	if !jobControl {
+		ignoreSIGTSTP()
+	}
 	go func() {
 		for sig := range sigCh {
 			logger.Println("signal", sig)
@@ -181,15 +198,17 @@ func initSignal(fds [3]*os.File) func() {
 	}
 }
 
-func evalInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source) error {
+// Evaluates code in the terminal. The Ports, Interrupts and PutInFg fields of
+// cfg are filled in by this function.
+func evalInTTY(fds [3]*os.File, ev *eval.Evaler, ed editor, src parse.Source, cfg eval.EvalCfg) error {
 	start := time.Now()
 	ports, cleanup := eval.PortsFromFiles(fds, ev.ValuePrefix())
 	defer cleanup()
 	restore := term.SetupForEval(fds[0], fds[1])
 	defer restore()
 	ctx, done := eval.ListenInterrupts()
-	err := ev.Eval(src, eval.EvalCfg{
-		Ports: ports, Interrupts: ctx, PutInFg: true})
+	cfg.Ports, cfg.Interrupts, cfg.PutInFg = ports, ctx, true
+	err := ev.Eval(src, cfg)
 	done()
 	if ed != nil {
 		ed.RunAfterCommandHooks(src, time.Since(start).Seconds(), err)
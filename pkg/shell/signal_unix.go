@@ -6,11 +6,21 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"syscall"
 
 	"src.elv.sh/pkg/sys"
 )
 
+// Ignores SIGTSTP when job control is not enabled. The ignore status is
+// inherited by external commands; otherwise, pressing Ctrl-Z would stop an
+// external command that Elvish is waiting for, with no way to resume it.
+//
+// See https://b.elv.sh/988.
+func ignoreSIGTSTP() {
+	signal.Ignore(syscall.SIGTSTP)
+}
+
 func handleSignal(sig os.Signal, stderr io.Writer) {
 	switch sig {
 	case syscall.SIGHUP:
@@ -6,6 +6,9 @@ import (
 	"syscall"
 )
 
+// Nop on Windows, which doesn't have SIGTSTP.
+func ignoreSIGTSTP() {}
+
 func handleSignal(sig os.Signal, stderr io.Writer) {
 	switch sig {
 	// See https://pkg.go.dev/os/signal#hdr-Windows for the semantics of SIGTERM
@@ -15,11 +15,11 @@ func notifySignals() chan os.Signal {
 	// Calling signal.Notify will reset the signal ignore status, so we need to
 	// call signal.Ignore every time we call signal.Notify.
 	//
-	// TODO: Remove this if, and when, job control is implemented. This
-	// handles the case of running an external command from an interactive
-	// prompt.
+	// SIGTSTP is not ignored here, so that external commands can be suspended
+	// when job control is enabled. When it is not, the shell ignores SIGTSTP
+	// itself.
 	//
 	// See https://b.elv.sh/988.
-	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGTSTP)
+	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU)
 	return sigCh
 }
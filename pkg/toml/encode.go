@@ -0,0 +1,215 @@
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errNilInArray = errors.New("toml: cannot encode array with nil element")
+
+// Encode writes m as a TOML document to w.
+//
+// Values can be nil, bool, string, int, int64, float64, [Datetime], []any or
+// map[string]any. Map entries whose values are nil are omitted. Lists whose
+// elements are all maps are written as arrays of tables; other maps are written
+// as tables, or as inline tables inside arrays. Keys are written in sorted
+// order.
+func Encode(w io.Writer, m map[string]any) error {
+	var sb strings.Builder
+	if err := encodeTable(&sb, nil, m); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func encodeTable(sb *strings.Builder, path []string, m map[string]any) error {
+	keys := sortedKeys(m)
+	// Key/value pairs must be written before sub-tables, since they would
+	// otherwise belong to the last sub-table.
+	for _, k := range keys {
+		v := m[k]
+		if v == nil || isTable(v) || isTableArray(v) {
+			continue
+		}
+		sb.WriteString(quoteKey(k) + " = ")
+		if err := encodeValue(sb, v); err != nil {
+			return err
+		}
+		sb.WriteByte('\n')
+	}
+	for _, k := range keys {
+		subpath := append(slices.Clip(path), k)
+		switch v := m[k].(type) {
+		case map[string]any:
+			writeHeader(sb, "["+joinKeys(subpath)+"]")
+			if err := encodeTable(sb, subpath, v); err != nil {
+				return err
+			}
+		case []any:
+			if !isTableArray(v) {
+				continue
+			}
+			for _, elem := range v {
+				writeHeader(sb, "[["+joinKeys(subpath)+"]]")
+				if err := encodeTable(sb, subpath, elem.(map[string]any)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeHeader(sb *strings.Builder, header string) {
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(header + "\n")
+}
+
+func isTable(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+func isTableArray(v any) bool {
+	s, ok := v.([]any)
+	if !ok || len(s) == 0 {
+		return false
+	}
+	for _, elem := range s {
+		if !isTable(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeValue(sb *strings.Builder, v any) error {
+	switch v := v.(type) {
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case string:
+		sb.WriteString(quoteString(v))
+	case int:
+		sb.WriteString(strconv.Itoa(v))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		sb.WriteString(formatFloat(v))
+	case Datetime:
+		sb.WriteString(string(v))
+	case []any:
+		sb.WriteByte('[')
+		for i, elem := range v {
+			if elem == nil {
+				return errNilInArray
+			}
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := encodeValue(sb, elem); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(']')
+	case map[string]any:
+		sb.WriteByte('{')
+		first := true
+		for _, k := range sortedKeys(v) {
+			if v[k] == nil {
+				continue
+			}
+			if !first {
+				sb.WriteString(",")
+			}
+			first = false
+			sb.WriteString(" " + quoteKey(k) + " = ")
+			if err := encodeValue(sb, v[k]); err != nil {
+				return err
+			}
+		}
+		if !first {
+			sb.WriteByte(' ')
+		}
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("toml: cannot encode value of type %T", v)
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Make sure that the value is read back as a float.
+		s += ".0"
+	}
+	return s
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func quoteKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return quoteString(k)
+		}
+	}
+	return k
+}
+
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
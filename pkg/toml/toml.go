@@ -0,0 +1,714 @@
+// Package toml implements a parser and an encoder for [TOML] v1.0.0.
+//
+// This package is used to implement the from-toml and to-toml builtin commands.
+// It only supports converting between TOML documents and generic Go values,
+// which is all those commands need; this keeps it much smaller than
+// general-purpose TOML libraries, which also map documents to Go structs, and
+// avoids adding a dependency to Elvish.
+//
+// [TOML]: https://toml.io/en/v1.0.0
+package toml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Datetime is a TOML offset date-time, local date-time, local date or local
+// time. It is stored in the format of RFC 3339, using "T" as the separator
+// between the date and the time, and without trailing zeros in fractional
+// seconds.
+type Datetime string
+
+// Error is an error encountered when parsing TOML.
+type Error struct {
+	// 1-based line number.
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("toml: line %d: %s", e.Line, e.Message)
+}
+
+// Parse parses a TOML document. Tables are returned as map[string]any, arrays
+// as []any, and other values as string, int64, float64, bool or [Datetime].
+func Parse(src string) (m map[string]any, err error) {
+	p := &parser{src: src, root: &table{entries: map[string]any{}}}
+	p.current = p.root
+	defer func() {
+		if r := recover(); r != nil {
+			if perr, ok := r.(*Error); ok {
+				m, err = nil, perr
+				return
+			}
+			panic(r)
+		}
+	}()
+	p.parse()
+	return p.root.toGo(), nil
+}
+
+// A table being built. Values in entries are *table, *tableArray, []any or
+// scalar values.
+type table struct {
+	entries map[string]any
+	// Whether the table is defined by a [table] header, dotted keys or an
+	// inline table. Tables that are only created implicitly as the parents of
+	// other tables have neither of these set.
+	header, dotted, inline bool
+}
+
+// An array of tables, created with [[table]] headers.
+type tableArray struct{ tables []*table }
+
+func (t *table) toGo() map[string]any {
+	m := make(map[string]any, len(t.entries))
+	for k, v := range t.entries {
+		m[k] = toGo(v)
+	}
+	return m
+}
+
+func toGo(v any) any {
+	switch v := v.(type) {
+	case *table:
+		return v.toGo()
+	case *tableArray:
+		s := make([]any, len(v.tables))
+		for i, t := range v.tables {
+			s[i] = t.toGo()
+		}
+		return s
+	case []any:
+		s := make([]any, len(v))
+		for i, elem := range v {
+			s[i] = toGo(elem)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+type parser struct {
+	src     string
+	pos     int
+	root    *table
+	current *table
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	panic(&Error{line, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) hasPrefix(s string) bool { return strings.HasPrefix(p.src[p.pos:], s) }
+
+// Describes the next character in error messages.
+func (p *parser) found() string {
+	switch {
+	case p.eof():
+		return "end of file"
+	case p.hasPrefix("\n"), p.hasPrefix("\r\n"):
+		return "newline"
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return strconv.QuoteRune(r)
+	}
+}
+
+func (p *parser) expect(s string) {
+	if !p.hasPrefix(s) {
+		p.errorf("expected %q, found %s", s, p.found())
+	}
+	p.pos += len(s)
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// Skips a comment if there is one at the current position.
+func (p *parser) skipComment() {
+	if p.peek() != '#' {
+		return
+	}
+	for !p.eof() && !p.hasPrefix("\n") && !p.hasPrefix("\r\n") {
+		if isControl(p.src[p.pos]) {
+			p.errorf("control character %s in comment", p.found())
+		}
+		p.pos++
+	}
+}
+
+// Skips a newline and returns true if there is one at the current position.
+func (p *parser) skipNewline() bool {
+	switch {
+	case p.hasPrefix("\n"):
+		p.pos++
+	case p.hasPrefix("\r\n"):
+		p.pos += 2
+	default:
+		return false
+	}
+	return true
+}
+
+// Skips whitespaces, comments and newlines, as allowed inside arrays.
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		if !p.skipNewline() {
+			return
+		}
+	}
+}
+
+func (p *parser) parse() {
+	for i, r := range p.src {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(p.src[i:]); size == 1 {
+				p.pos = i
+				p.errorf("invalid UTF-8")
+			}
+		}
+	}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return
+		}
+		if p.peek() == '[' {
+			p.parseHeader()
+		} else {
+			p.parseKeyValue(p.current)
+		}
+		p.skipSpaces()
+		p.skipComment()
+		if !p.eof() && !p.skipNewline() {
+			p.errorf("expected newline, found %s", p.found())
+		}
+	}
+}
+
+func (p *parser) parseHeader() {
+	isArray := p.hasPrefix("[[")
+	if isArray {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.skipSpaces()
+	keys := p.parseKey()
+	p.skipSpaces()
+	if isArray {
+		p.expect("]]")
+	} else {
+		p.expect("]")
+	}
+
+	t := p.root
+	for i, key := range keys[:len(keys)-1] {
+		switch v := t.entries[key].(type) {
+		case nil:
+			child := &table{entries: map[string]any{}}
+			t.entries[key] = child
+			t = child
+		case *table:
+			if v.inline {
+				p.errorf("cannot extend inline table %s", joinKeys(keys[:i+1]))
+			}
+			t = v
+		case *tableArray:
+			t = v.tables[len(v.tables)-1]
+		default:
+			p.errorf("key %s is already defined as a value", joinKeys(keys[:i+1]))
+		}
+	}
+	last := keys[len(keys)-1]
+	if isArray {
+		switch v := t.entries[last].(type) {
+		case nil:
+			p.current = &table{entries: map[string]any{}, header: true}
+			t.entries[last] = &tableArray{[]*table{p.current}}
+		case *tableArray:
+			p.current = &table{entries: map[string]any{}, header: true}
+			v.tables = append(v.tables, p.current)
+		default:
+			p.errorf("key %s is already defined and is not an array of tables", joinKeys(keys))
+		}
+		return
+	}
+	switch v := t.entries[last].(type) {
+	case nil:
+		p.current = &table{entries: map[string]any{}, header: true}
+		t.entries[last] = p.current
+	case *table:
+		if v.header || v.dotted || v.inline {
+			p.errorf("table %s is defined more than once", joinKeys(keys))
+		}
+		v.header = true
+		p.current = v
+	default:
+		p.errorf("key %s is already defined and is not a table", joinKeys(keys))
+	}
+}
+
+func (p *parser) parseKeyValue(t *table) {
+	keys := p.parseKey()
+	p.skipSpaces()
+	p.expect("=")
+	p.skipSpaces()
+	for i, key := range keys[:len(keys)-1] {
+		switch v := t.entries[key].(type) {
+		case nil:
+			child := &table{entries: map[string]any{}, dotted: true, inline: t.inline}
+			t.entries[key] = child
+			t = child
+		case *table:
+			if !v.dotted || v.inline != t.inline {
+				p.errorf("key %s is already defined", joinKeys(keys[:i+1]))
+			}
+			t = v
+		default:
+			p.errorf("key %s is already defined", joinKeys(keys[:i+1]))
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, exists := t.entries[last]; exists {
+		p.errorf("key %s is already defined", joinKeys(keys))
+	}
+	t.entries[last] = p.parseValue()
+}
+
+// Parses a possibly dotted key.
+func (p *parser) parseKey() []string {
+	var keys []string
+	for {
+		keys = append(keys, p.parseSimpleKey())
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys
+		}
+		p.pos++
+		p.skipSpaces()
+	}
+}
+
+func (p *parser) parseSimpleKey() string {
+	switch p.peek() {
+	case '"':
+		if p.hasPrefix(`"""`) {
+			p.errorf("multi-line strings can't be used as keys")
+		}
+		return p.parseBasicString()
+	case '\'':
+		if p.hasPrefix("'''") {
+			p.errorf("multi-line strings can't be used as keys")
+		}
+		return p.parseLiteralString()
+	}
+	start := p.pos
+	for !p.eof() && isBareKeyChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.errorf("expected key, found %s", p.found())
+	}
+	return p.src[start:p.pos]
+}
+
+func isBareKeyChar(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '_' || b == '-'
+}
+
+func (p *parser) parseValue() any {
+	switch {
+	case p.hasPrefix(`"""`):
+		return p.parseMultiLineBasicString()
+	case p.hasPrefix("'''"):
+		return p.parseMultiLineLiteralString()
+	case p.peek() == '"':
+		return p.parseBasicString()
+	case p.peek() == '\'':
+		return p.parseLiteralString()
+	case p.peek() == '[':
+		return p.parseArray()
+	case p.peek() == '{':
+		return p.parseInlineTable()
+	case p.hasPrefix("true"):
+		p.pos += len("true")
+		return true
+	case p.hasPrefix("false"):
+		p.pos += len("false")
+		return false
+	}
+	start := p.pos
+	for !p.eof() && isValueChar(p.src[p.pos]) {
+		p.pos++
+	}
+	// Allow a space between the date and the time.
+	if p.hasPrefix(" ") && isDate(p.src[start:p.pos]) &&
+		p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		for !p.eof() && isValueChar(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+	s := p.src[start:p.pos]
+	if s == "" {
+		p.errorf("expected value, found %s", p.found())
+	}
+	if v, ok := parseDatetime(s); ok {
+		return v
+	}
+	if v, ok := parseNumber(s); ok {
+		return v
+	}
+	p.pos = start
+	p.errorf("invalid value %q", s)
+	return nil
+}
+
+func isValueChar(b byte) bool {
+	return isBareKeyChar(b) || b == '+' || b == '.' || b == ':'
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
+
+func isControl(b byte) bool { return b < 0x20 && b != '\t' || b == 0x7f }
+
+func (p *parser) parseArray() []any {
+	p.pos++
+	arr := []any{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return arr
+		}
+		arr = append(arr, p.parseValue())
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return arr
+		default:
+			p.errorf("expected ',' or ']' in array, found %s", p.found())
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() *table {
+	p.pos++
+	t := &table{entries: map[string]any{}, inline: true}
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		return t
+	}
+	for {
+		p.skipSpaces()
+		p.parseKeyValue(t)
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t
+		default:
+			p.errorf("expected ',' or '}' in inline table, found %s", p.found())
+		}
+	}
+}
+
+func (p *parser) parseBasicString() string {
+	p.pos++
+	var sb strings.Builder
+	for {
+		switch {
+		case p.eof(), p.hasPrefix("\n"), p.hasPrefix("\r\n"):
+			p.errorf("unterminated string")
+		case p.peek() == '"':
+			p.pos++
+			return sb.String()
+		case p.peek() == '\\':
+			p.parseEscape(&sb)
+		case isControl(p.peek()):
+			p.errorf("control character %s in string", p.found())
+		default:
+			sb.WriteByte(p.src[p.pos])
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) parseMultiLineBasicString() string {
+	p.pos += 3
+	p.skipNewline()
+	var sb strings.Builder
+	for {
+		switch {
+		case p.eof():
+			p.errorf("unterminated string")
+		case p.hasPrefix(`"""`):
+			return p.endMultiLineString(&sb, '"')
+		case p.peek() == '\\':
+			// A backslash at the end of a line trims all the whitespaces
+			// and newlines that follow.
+			i := p.pos + 1
+			for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+				i++
+			}
+			if strings.HasPrefix(p.src[i:], "\n") || strings.HasPrefix(p.src[i:], "\r\n") {
+				p.pos = i
+				for p.skipNewline() {
+					p.skipSpaces()
+				}
+				continue
+			}
+			p.parseEscape(&sb)
+		case p.skipNewline():
+			sb.WriteByte('\n')
+		case isControl(p.peek()):
+			p.errorf("control character %s in string", p.found())
+		default:
+			sb.WriteByte(p.src[p.pos])
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) parseLiteralString() string {
+	p.pos++
+	start := p.pos
+	for {
+		switch {
+		case p.eof(), p.hasPrefix("\n"), p.hasPrefix("\r\n"):
+			p.errorf("unterminated string")
+		case p.peek() == '\'':
+			p.pos++
+			return p.src[start : p.pos-1]
+		case isControl(p.peek()):
+			p.errorf("control character %s in string", p.found())
+		default:
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) parseMultiLineLiteralString() string {
+	p.pos += 3
+	p.skipNewline()
+	var sb strings.Builder
+	for {
+		switch {
+		case p.eof():
+			p.errorf("unterminated string")
+		case p.hasPrefix("'''"):
+			return p.endMultiLineString(&sb, '\'')
+		case p.skipNewline():
+			sb.WriteByte('\n')
+		case isControl(p.peek()):
+			p.errorf("control character %s in string", p.found())
+		default:
+			sb.WriteByte(p.src[p.pos])
+			p.pos++
+		}
+	}
+}
+
+// Finishes a multi-line string at a run of 3 or more quotes. Up to 2 quotes
+// before the closing delimiter are part of the string.
+func (p *parser) endMultiLineString(sb *strings.Builder, quote byte) string {
+	n := 0
+	for p.pos+n < len(p.src) && p.src[p.pos+n] == quote {
+		n++
+	}
+	if n > 5 {
+		p.errorf("too many quotes at the end of multi-line string")
+	}
+	sb.WriteString(strings.Repeat(string(quote), n-3))
+	p.pos += n
+	return sb.String()
+}
+
+func (p *parser) parseEscape(sb *strings.Builder) {
+	p.pos++
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			p.errorf("incomplete unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			p.errorf("invalid unicode escape %q", p.src[p.pos-2:p.pos+n])
+		}
+		sb.WriteRune(rune(code))
+		p.pos += n
+	default:
+		p.pos -= 2
+		p.errorf("invalid escape sequence %q", p.src[p.pos:min(p.pos+2, len(p.src))])
+	}
+}
+
+func parseNumber(s string) (any, bool) {
+	sign, body := "", s
+	if s[0] == '+' || s[0] == '-' {
+		sign, body = s[:1], s[1:]
+	}
+	switch body {
+	case "inf":
+		if sign == "-" {
+			return math.Inf(-1), true
+		}
+		return math.Inf(1), true
+	case "nan":
+		return math.NaN(), true
+	}
+	if len(body) > 2 && body[0] == '0' && sign == "" {
+		base := 0
+		var isValid func(byte) bool
+		switch body[1] {
+		case 'x':
+			base, isValid = 16, isHexDigit
+		case 'o':
+			base, isValid = 8, func(b byte) bool { return '0' <= b && b <= '7' }
+		case 'b':
+			base, isValid = 2, func(b byte) bool { return b == '0' || b == '1' }
+		}
+		if base != 0 {
+			if !validDigits(body[2:], isValid) {
+				return nil, false
+			}
+			i, err := strconv.ParseInt(strings.ReplaceAll(body[2:], "_", ""), base, 64)
+			return i, err == nil
+		}
+	}
+	intPart, frac, exp := body, "", ""
+	if i := strings.IndexAny(intPart, "eE"); i != -1 {
+		intPart, exp = intPart[:i], intPart[i+1:]
+		if exp == "" {
+			return nil, false
+		}
+		if exp[0] == '+' || exp[0] == '-' {
+			exp = exp[1:]
+		}
+		if !validDigits(exp, isDigit) {
+			return nil, false
+		}
+	}
+	if i := strings.IndexByte(intPart, '.'); i != -1 {
+		intPart, frac = intPart[:i], intPart[i+1:]
+		if !validDigits(frac, isDigit) {
+			return nil, false
+		}
+	}
+	if !validDigits(intPart, isDigit) || (len(intPart) > 1 && intPart[0] == '0') {
+		return nil, false
+	}
+	clean := strings.ReplaceAll(s, "_", "")
+	if !strings.ContainsAny(body, ".eE") {
+		i, err := strconv.ParseInt(clean, 10, 64)
+		return i, err == nil
+	}
+	f, err := strconv.ParseFloat(clean, 64)
+	return f, err == nil
+}
+
+// Reports whether s is a non-empty sequence of digits, optionally with single
+// underscores between them.
+func validDigits(s string, isValid func(byte) bool) bool {
+	if s == "" || s[0] == '_' || s[len(s)-1] == '_' || strings.Contains(s, "__") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' && !isValid(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(b byte) bool {
+	return isDigit(b) || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+// Reports whether s starts with a full date.
+func isDate(s string) bool {
+	return len(s) == len("2006-01-02") && s[4] == '-' && s[7] == '-'
+}
+
+const (
+	localDatetimeFormat = "2006-01-02T15:04:05.999999999"
+	localTimeFormat     = "15:04:05.999999999"
+)
+
+func parseDatetime(s string) (Datetime, bool) {
+	s = strings.ToUpper(s)
+	if len(s) > len("2006-01-02") && s[len("2006-01-02")] == ' ' {
+		s = s[:len("2006-01-02")] + "T" + s[len("2006-01-02")+1:]
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return Datetime(t.Format(time.RFC3339Nano)), true
+	}
+	if t, err := time.Parse(localDatetimeFormat, s); err == nil {
+		return Datetime(t.Format(localDatetimeFormat)), true
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return Datetime(t.Format(time.DateOnly)), true
+	}
+	if t, err := time.Parse(localTimeFormat, s); err == nil {
+		return Datetime(t.Format(localTimeFormat)), true
+	}
+	return "", false
+}
+
+func joinKeys(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = quoteKey(key)
+	}
+	return strings.Join(quoted, ".")
+}
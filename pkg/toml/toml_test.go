@@ -0,0 +1,140 @@
+package toml
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+type m = map[string]any
+type l = []any
+
+func TestParse(t *testing.T) {
+	tt.Test(t, Parse,
+		// Key/value pairs and comments.
+		Args("a = 1 # comment\n# comment\nb = 'x'").Rets(m{"a": int64(1), "b": "x"}, nil),
+		Args("").Rets(m{}, nil),
+		Args("a = 1\r\nb = 2\r\n").Rets(m{"a": int64(1), "b": int64(2)}, nil),
+		// Keys.
+		Args(`"a b" = 1`).Rets(m{"a b": int64(1)}, nil),
+		Args(`'a"b' = 1`).Rets(m{`a"b`: int64(1)}, nil),
+		Args(`a . "b" .c = 1`).Rets(m{"a": m{"b": m{"c": int64(1)}}}, nil),
+		Args("a.b = 1\na.c = 2").Rets(m{"a": m{"b": int64(1), "c": int64(2)}}, nil),
+		// Strings.
+		Args(`a = "\"\\\b\t\n\f\r\u00e9\U0001F600"`).Rets(m{"a": "\"\\\b\t\n\f\ré😀"}, nil),
+		Args(`a = 'C:\x'`).Rets(m{"a": `C:\x`}, nil),
+		Args("a = \"\"\"\nx\n  y\"\"\"").Rets(m{"a": "x\n  y"}, nil),
+		Args("a = \"\"\"x \\\n\n   y\"\"\"").Rets(m{"a": "x y"}, nil),
+		Args(`a = """x"""""`).Rets(m{"a": `x""`}, nil),
+		Args("a = '''\nx\\n\n'''").Rets(m{"a": "x\\n\n"}, nil),
+		// Numbers.
+		Args("a = [0, +1, -2, 1_000, 0xdead_BEEF, 0o17, 0b101]").Rets(m{"a": l{
+			int64(0), int64(1), int64(-2), int64(1000), int64(0xdeadbeef),
+			int64(0o17), int64(0b101)}}, nil),
+		Args("a = [1.5, -0.5, 1e3, 1E-2, 6.5_5e+0_1, inf, -inf]").Rets(m{"a": l{
+			1.5, -0.5, 1e3, 1e-2, 65.5, math.Inf(1), math.Inf(-1)}}, nil),
+		// Booleans.
+		Args("a = true\nb = false").Rets(m{"a": true, "b": false}, nil),
+		// Dates and times.
+		Args("a = 1979-05-27T07:32:00Z\nb = 1979-05-27 07:32:00.500-07:00\n"+
+			"c = 1979-05-27t07:32:00\nd = 1979-05-27\ne = 07:32:00.999").Rets(m{
+			"a": Datetime("1979-05-27T07:32:00Z"),
+			"b": Datetime("1979-05-27T07:32:00.5-07:00"),
+			"c": Datetime("1979-05-27T07:32:00"),
+			"d": Datetime("1979-05-27"),
+			"e": Datetime("07:32:00.999")}, nil),
+		// Arrays.
+		Args("a = [\n  1, # comment\n  'x',\n  [],\n]").Rets(m{"a": l{int64(1), "x", l{}}}, nil),
+		// Inline tables.
+		Args("a = {x = 1, y.z = 'w'}\nb = {}").Rets(m{
+			"a": m{"x": int64(1), "y": m{"z": "w"}}, "b": m{}}, nil),
+		// Tables.
+		Args("a = 1\n[b]\nc = 2\n[b.d]\ne = 3\n[f . g]").Rets(m{
+			"a": int64(1),
+			"b": m{"c": int64(2), "d": m{"e": int64(3)}},
+			"f": m{"g": m{}}}, nil),
+		// Implicitly created tables can be defined later.
+		Args("[a.b]\n[a]\nc = 1").Rets(m{"a": m{"b": m{}, "c": int64(1)}}, nil),
+		// Arrays of tables.
+		Args("[[a]]\nb = 1\n[a.c]\nd = 2\n[[a]]\nb = 3").Rets(m{"a": l{
+			m{"b": int64(1), "c": m{"d": int64(2)}}, m{"b": int64(3)}}}, nil),
+
+		// Errors.
+		Args("a = ").Rets(m(nil), &Error{1, "expected value, found end of file"}),
+		Args("a = \nb = 1").Rets(m(nil), &Error{1, "expected value, found newline"}),
+		Args("a = 1 2").Rets(m(nil), &Error{1, `expected newline, found '2'`}),
+		Args("a").Rets(m(nil), &Error{1, `expected "=", found end of file`}),
+		Args("= 1").Rets(m(nil), &Error{1, `expected key, found '='`}),
+		Args("a = 1\na = 2").Rets(m(nil), &Error{2, "key a is already defined"}),
+		Args("a.b = 1\na.b.c = 2").Rets(m(nil), &Error{2, "key a.b is already defined"}),
+		Args("a = {}\na.b = 1").Rets(m(nil), &Error{2, "key a is already defined"}),
+		Args("[a]\n[a]").Rets(m(nil), &Error{2, "table a is defined more than once"}),
+		Args("a.b = 1\n[a]").Rets(m(nil), &Error{2, "table a is defined more than once"}),
+		Args("a = {}\n[a.b]").Rets(m(nil), &Error{2, "cannot extend inline table a"}),
+		Args("a = 1\n[a.b]").Rets(m(nil), &Error{2, "key a is already defined as a value"}),
+		Args("a = []\n[[a]]").Rets(m(nil), &Error{2, "key a is already defined and is not an array of tables"}),
+		Args("[[a]]\n[a]").Rets(m(nil), &Error{2, "key a is already defined and is not a table"}),
+		Args("a = 01").Rets(m(nil), &Error{1, `invalid value "01"`}),
+		Args("a = 1__0").Rets(m(nil), &Error{1, `invalid value "1__0"`}),
+		Args("a = 9223372036854775808").Rets(m(nil), &Error{1, `invalid value "9223372036854775808"`}),
+		Args("a = 1.").Rets(m(nil), &Error{1, `invalid value "1."`}),
+		Args("a = 1979-02-30").Rets(m(nil), &Error{1, `invalid value "1979-02-30"`}),
+		Args(`a = "x`).Rets(m(nil), &Error{1, "unterminated string"}),
+		Args(`a = """x""""""`).Rets(m(nil), &Error{1, "too many quotes at the end of multi-line string"}),
+		Args(`a = "\x"`).Rets(m(nil), &Error{1, `invalid escape sequence "\\x"`}),
+		Args(`a = "\uD800"`).Rets(m(nil), &Error{1, `invalid unicode escape "\\uD800"`}),
+		Args("a = \"\x01\"").Rets(m(nil), &Error{1, `control character '\x01' in string`}),
+		Args("a = [1 2]").Rets(m(nil), &Error{1, `expected ',' or ']' in array, found '2'`}),
+		Args("a = {x = 1,}").Rets(m(nil), &Error{1, `expected key, found '}'`}),
+		Args(`"""a""" = 1`).Rets(m(nil), &Error{1, "multi-line strings can't be used as keys"}),
+		Args("a = 1\nb = '\xff'").Rets(m(nil), &Error{2, "invalid UTF-8"}),
+	)
+}
+
+func TestParse_NaN(t *testing.T) {
+	v, err := Parse("a = nan")
+	if err != nil || !math.IsNaN(v["a"].(float64)) {
+		t.Errorf("got %v, %v, want NaN", v, err)
+	}
+}
+
+func encode(v map[string]any) (string, error) {
+	var sb strings.Builder
+	err := Encode(&sb, v)
+	return sb.String(), err
+}
+
+func TestEncode(t *testing.T) {
+	tt.Test(t, encode,
+		Args(m{"b": "x", "a": int64(1), "c": nil}).Rets("a = 1\nb = \"x\"\n", nil),
+		Args(m{"a": l{1, 2.5, true, "x", l{}}}).Rets("a = [1, 2.5, true, \"x\", []]\n", nil),
+		Args(m{"a": "\"\\\n\x01é", "a b": 1, "": 2}).Rets(
+			"\"\" = 2\na = \"\\\"\\\\\\n\\u0001é\"\n\"a b\" = 1\n", nil),
+		Args(m{"a": l{1.0, 1e21, math.Inf(1), math.Inf(-1), math.NaN()}}).Rets(
+			"a = [1.0, 1e+21, inf, -inf, nan]\n", nil),
+		Args(m{"a": Datetime("1979-05-27")}).Rets("a = 1979-05-27\n", nil),
+		// Tables and arrays of tables.
+		Args(m{"z": 1, "a": m{"b": 2, "c": m{"d": 3}}, "e": l{m{"f": 4}, m{}}}).Rets(
+			"z = 1\n\n[a]\nb = 2\n\n[a.c]\nd = 3\n\n[[e]]\nf = 4\n\n[[e]]\n", nil),
+		// Maps in arrays that are not arrays of tables are written as inline
+		// tables.
+		Args(m{"a": l{m{"x": 1, "y": nil}, 2, m{}}}).Rets("a = [{ x = 1 }, 2, {}]\n", nil),
+
+		// Errors.
+		Args(m{"a": l{nil}}).Rets("", errNilInArray),
+		Args(m{"a": struct{}{}}).Rets("", tt.Any),
+	)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	v := m{"a": int64(1), "b": l{"x", m{"y": 1.5}}, "c": m{"d": l{m{"e": true}}}}
+	s, err := encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.Test(t, Parse, Args(s).Rets(v, nil))
+}
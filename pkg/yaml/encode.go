@@ -0,0 +1,242 @@
+package yaml
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Encode writes v as a YAML document to w.
+//
+// Values can be nil, bool, string, int, *big.Int, float64, []any or
+// map[string]any. Collections are written in block style with an indentation
+// of 2 spaces, except for empty ones. Strings are written as plain scalars when
+// that doesn't change their meaning, as literal block scalars when they span
+// multiple lines, and as double-quoted scalars otherwise. Keys are written in
+// sorted order.
+func Encode(w io.Writer, v any) error {
+	var sb strings.Builder
+	if err := encodeBlock(&sb, v, 0, false); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Writes a node in block context, followed by a line break. The node is
+// indented by indent spaces; if inline is true, the first line of the node has
+// already been indented, which is the case for entries of block sequences.
+func encodeBlock(sb *strings.Builder, v any, indent int, inline bool) error {
+	prefix := func(i int) string {
+		if i == 0 && inline {
+			return ""
+		}
+		return strings.Repeat(" ", indent)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			break
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for i, k := range keys {
+			sb.WriteString(prefix(i) + quoteKey(k) + ":")
+			if err := encodeValue(sb, v[k], indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if len(v) == 0 {
+			break
+		}
+		for i, elem := range v {
+			sb.WriteString(prefix(i) + "- ")
+			if err := encodeBlock(sb, elem, indent+2, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := formatScalar(v, indent+2)
+	if err != nil {
+		return err
+	}
+	sb.WriteString(prefix(0) + s + "\n")
+	return nil
+}
+
+// Writes the value of a block mapping entry after the ":".
+func encodeValue(sb *strings.Builder, v any, indent int) error {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) > 0 {
+			sb.WriteByte('\n')
+			return encodeBlock(sb, v, indent+2, false)
+		}
+	case []any:
+		if len(v) > 0 {
+			sb.WriteByte('\n')
+			return encodeBlock(sb, v, indent+2, false)
+		}
+	}
+	sb.WriteByte(' ')
+	return encodeBlock(sb, v, indent, true)
+}
+
+// Formats a scalar or an empty collection. Lines of literal block scalars are
+// indented by blockIndent spaces.
+func formatScalar(v any, blockIndent int) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case *big.Int:
+		return v.String(), nil
+	case float64:
+		return formatFloat(v), nil
+	case string:
+		if isPlainSafe(v) {
+			return v, nil
+		} else if canBeLiteral(v) {
+			return formatLiteral(v, blockIndent), nil
+		}
+		return quote(v), nil
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return "[]", nil
+		}
+	}
+	return "", fmt.Errorf("yaml: cannot encode value of type %T", v)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if resolve(s) != "!!float" {
+		// Make sure that the value is read back as a float.
+		s += ".0"
+	}
+	return s
+}
+
+func quoteKey(k string) string {
+	if isPlainSafe(k) {
+		return k
+	}
+	return quote(k)
+}
+
+// Words that YAML 1.1 resolves to booleans. They are quoted for
+// compatibility with implementations of YAML 1.1.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
+
+// Reports whether s can be written as a plain scalar and read back as the same
+// string.
+func isPlainSafe(s string) bool {
+	if s == "" || resolve(s) != "!!str" || yaml11Bools[s] ||
+		strings.ContainsRune("-?:,[]{}#&*!|>'\"%@` \t", rune(s[0])) ||
+		strings.HasPrefix(s, "...") ||
+		s[len(s)-1] == ' ' || s[len(s)-1] == ':' ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	for _, r := range s {
+		if r == '\t' || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether s can be written as a literal block scalar, which is the
+// case for multi-line strings without non-printable characters, whose first
+// non-empty line doesn't start with a space (which would be taken as
+// indentation).
+func canBeLiteral(s string) bool {
+	if !strings.Contains(s, "\n") ||
+		strings.HasPrefix(strings.TrimLeft(s, "\n"), " ") ||
+		strings.Trim(s, "\n") == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatLiteral(s string, indent int) string {
+	var sb strings.Builder
+	switch {
+	case !strings.HasSuffix(s, "\n"):
+		sb.WriteString("|-")
+	case strings.HasSuffix(s, "\n\n"):
+		sb.WriteString("|+")
+		s = s[:len(s)-1]
+	default:
+		sb.WriteString("|")
+		s = s[:len(s)-1]
+	}
+	for _, line := range strings.Split(s, "\n") {
+		sb.WriteByte('\n')
+		if line != "" {
+			sb.WriteString(strings.Repeat(" ", indent) + line)
+		}
+	}
+	return sb.String()
+}
+
+var quoteEscapes = map[rune]string{
+	'"': `\"`, '\\': `\\`, 0: `\0`, '\a': `\a`, '\b': `\b`, '\t': `\t`,
+	'\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`, 0x1b: `\e`,
+}
+
+// Quotes s as a double-quoted scalar.
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		if e, ok := quoteEscapes[r]; ok {
+			sb.WriteString(e)
+		} else if unicode.IsPrint(r) || r == ' ' {
+			sb.WriteRune(r)
+		} else if r <= 0xff {
+			fmt.Fprintf(&sb, `\x%02X`, r)
+		} else if r <= 0xffff {
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		} else {
+			fmt.Fprintf(&sb, `\U%08X`, r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
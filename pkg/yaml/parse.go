@@ -0,0 +1,864 @@
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parses a document, made up of the given lines. The first line has the line
+// number firstLine.
+func parseDocument(lines []string, firstLine int) (root *Node, err error) {
+	p := &parser{
+		src: strings.Join(lines, "\n"), firstLine: firstLine,
+		anchors: make(map[string]*Node)}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if e, ok := r.(*Error); ok {
+			root, err = nil, e
+			return
+		}
+		panic(r)
+	}()
+	if !utf8.ValidString(p.src) {
+		p.errorf("invalid UTF-8")
+	}
+	if !p.skipToContent() {
+		return p.null(), nil
+	}
+	root = p.parseInline(-1, true, false)
+	p.expectEOL()
+	if p.skipToContent() {
+		p.errorf("did not find expected <document start>")
+	}
+	return root, nil
+}
+
+// The parser for a single document. Errors are reported by panicking with an
+// *Error value, which is recovered in parseDocument.
+//
+// Most methods that parse nodes have a parameter n, the indentation of the
+// parent block node (-1 for the root node). Content of the node that continues
+// onto subsequent lines must be indented more than n.
+type parser struct {
+	src       string
+	pos       int
+	line      int // 0-based line of pos
+	lineStart int // position of the start of the current line
+	firstLine int
+	anchors   map[string]*Node
+}
+
+type parserState struct{ pos, line, lineStart int }
+
+func (p *parser) save() parserState { return parserState{p.pos, p.line, p.lineStart} }
+
+func (p *parser) restore(s parserState) { p.pos, p.line, p.lineStart = s.pos, s.line, s.lineStart }
+
+func (p *parser) errorf(format string, args ...any) {
+	panic(&Error{p.firstLine + p.line, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) col() int { return p.pos - p.lineStart }
+
+// Returns the byte at pos+i, or 0 if it is beyond the end.
+func (p *parser) peekAt(i int) byte {
+	if p.pos+i < len(p.src) {
+		return p.src[p.pos+i]
+	}
+	return 0
+}
+
+func (p *parser) peek() byte { return p.peekAt(0) }
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) atEOL() bool { return p.eof() || p.src[p.pos] == '\n' }
+
+// Whether the parser is at a comment or the end of the line.
+func (p *parser) atEOLOrComment() bool { return p.atEOL() || p.peek() == '#' }
+
+func (p *parser) nextLine() {
+	p.pos++
+	p.line++
+	p.lineStart = p.pos
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.atEOL() {
+			p.pos++
+		}
+	}
+}
+
+// Skips whitespace, comments and line breaks, and reports whether there is any
+// content left.
+func (p *parser) skipToContent() bool {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		if p.eof() {
+			return false
+		} else if p.peek() != '\n' {
+			return true
+		}
+		p.nextLine()
+	}
+}
+
+// Checks that there is nothing left on the current line except whitespace and
+// comments.
+func (p *parser) expectEOL() {
+	p.skipSpaces()
+	p.skipComment()
+	if !p.atEOL() {
+		if p.peek() == ':' {
+			p.errorf("mapping values are not allowed in this context")
+		}
+		p.errorf("unexpected %q", p.peek())
+	}
+}
+
+func (p *parser) null() *Node {
+	return &Node{Kind: ScalarNode, Tag: "!!null", Line: p.firstLine + p.line}
+}
+
+func (p *parser) scalar(tag, value string, line int) *Node {
+	return &Node{Kind: ScalarNode, Tag: tag, Value: value, Line: line}
+}
+
+func isBlank(b byte) bool { return b == ' ' || b == '\t' || b == '\n' || b == 0 }
+
+func isFlowIndicator(b byte) bool {
+	return b == ',' || b == '[' || b == ']' || b == '{' || b == '}'
+}
+
+func (p *parser) atSequenceIndicator() bool {
+	return p.peek() == '-' && isBlank(p.peekAt(1))
+}
+
+func (p *parser) checkExplicitKey() {
+	if p.peek() == '?' && isBlank(p.peekAt(1)) {
+		p.errorf("complex mapping keys are not supported")
+	}
+}
+
+// Parses a block node that starts on a subsequent line, called when the rest of
+// the current line is empty. If allowSeqAtN is true, a block sequence
+// indented by exactly n is also accepted; this is used for values of block
+// mappings. If there is no such node, the node is null.
+func (p *parser) parseBlockNode(n int, allowSeqAtN bool) *Node {
+	s := p.save()
+	if p.skipToContent() {
+		c := p.col()
+		if c > n || (allowSeqAtN && c == n && p.atSequenceIndicator()) {
+			return p.parseInline(n, true, allowSeqAtN)
+		}
+	}
+	p.restore(s)
+	return p.null()
+}
+
+// Parses a node that starts at the current position. If allowBlock is false,
+// the node may not be a block collection; this is the case for values that
+// start on the same line as their keys.
+func (p *parser) parseInline(n int, allowBlock, allowSeqAtN bool) *Node {
+	if p.atSequenceIndicator() {
+		if !allowBlock {
+			p.errorf("block sequence entries are not allowed in this context")
+		}
+		return p.parseBlockSequence(p.col())
+	}
+	p.checkExplicitKey()
+	if p.atImplicitKey() {
+		if !allowBlock {
+			p.errorf("mapping values are not allowed in this context")
+		}
+		return p.parseBlockMapping(p.col())
+	}
+	node := p.parseProperties()
+	var content *Node
+	if p.atEOLOrComment() {
+		content = p.parseBlockNode(n, allowSeqAtN)
+	} else {
+		content = p.parseBlockScalarOrFlow(n)
+	}
+	return p.fill(node, content)
+}
+
+// Parses anchors and tags, and returns a node with them.
+func (p *parser) parseProperties() *Node {
+	node := &Node{Line: p.firstLine + p.line}
+	for {
+		switch p.peek() {
+		case '&':
+			p.pos++
+			name := p.scanName()
+			if name == "" {
+				p.errorf("did not find expected alphabetic or numeric character")
+			}
+			node.Anchor = name
+			p.anchors[name] = node
+		case '!':
+			start := p.pos
+			if p.peekAt(1) == '<' {
+				// A verbatim tag, which may contain flow indicators.
+				for !isBlank(p.peek()) && p.peek() != '>' {
+					p.pos++
+				}
+				if p.peek() == '>' {
+					p.pos++
+				}
+			} else {
+				for !isBlank(p.peek()) && !isFlowIndicator(p.peek()) {
+					p.pos++
+				}
+			}
+			node.Tag = expandTag(p.src[start:p.pos])
+		default:
+			return node
+		}
+		p.skipSpaces()
+	}
+}
+
+// Scans the name of an anchor or an alias.
+func (p *parser) scanName() string {
+	start := p.pos
+	for !isBlank(p.peek()) && !isFlowIndicator(p.peek()) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func expandTag(tag string) string {
+	if strings.HasPrefix(tag, "!<") && strings.HasSuffix(tag, ">") {
+		tag = tag[2 : len(tag)-1]
+		if name, ok := strings.CutPrefix(tag, "tag:yaml.org,2002:"); ok {
+			return "!!" + name
+		}
+	}
+	return tag
+}
+
+// Fills the content of a node whose properties have been parsed. Anchors are
+// registered before the content is parsed, so aliases in the content refer to
+// node itself.
+func (p *parser) fill(node, content *Node) *Node {
+	if node.Anchor == "" && node.Tag == "" {
+		return content
+	}
+	if content.Kind == AliasNode {
+		p.errorf("aliases can't have anchors or tags")
+	}
+	anchor, tag := node.Anchor, node.Tag
+	*node = *content
+	node.Anchor = anchor
+	switch {
+	case tag == "":
+	case tag == "!":
+		// The non-specific tag makes scalars strings and leaves collections
+		// as is.
+		if node.Kind == ScalarNode {
+			node.Tag = "!!str"
+		}
+	default:
+		node.Tag = tag
+	}
+	return node
+}
+
+// Reports whether the current position is at an implicit key of a block
+// mapping, which must be followed by ":" on the same line.
+func (p *parser) atImplicitKey() bool {
+	i := p.pos
+	at := func(i int) byte {
+		if i < len(p.src) {
+			return p.src[i]
+		}
+		return 0
+	}
+	skipSpaces := func() {
+		for at(i) == ' ' || at(i) == '\t' {
+			i++
+		}
+	}
+	// Skip properties.
+	for at(i) == '&' || at(i) == '!' {
+		for !isBlank(at(i)) {
+			i++
+		}
+		skipSpaces()
+	}
+	switch at(i) {
+	case '"', '\'':
+		q := at(i)
+		for i++; ; i++ {
+			switch at(i) {
+			case 0, '\n':
+				return false
+			case '\\':
+				if q == '"' {
+					i++
+				}
+			case q:
+				if q == '\'' && at(i+1) == '\'' {
+					i++
+					continue
+				}
+				i++
+				skipSpaces()
+				return at(i) == ':' && isBlank(at(i+1))
+			}
+		}
+	case '*':
+		for !isBlank(at(i)) {
+			i++
+		}
+		skipSpaces()
+		return at(i) == ':' && isBlank(at(i+1))
+	case '[', '{', '|', '>', '#', '\n', 0:
+		return false
+	}
+	for ; at(i) != '\n' && at(i) != 0; i++ {
+		if at(i) == ':' && isBlank(at(i+1)) {
+			return true
+		}
+		if at(i) == '#' && (at(i-1) == ' ' || at(i-1) == '\t') {
+			return false
+		}
+	}
+	return false
+}
+
+// Parses a block mapping whose first key is at the current position, which is
+// at column c.
+func (p *parser) parseBlockMapping(c int) *Node {
+	m := &Node{Kind: MappingNode, Tag: "!!map", Line: p.firstLine + p.line}
+	for {
+		p.checkExplicitKey()
+		if p.atSequenceIndicator() {
+			p.errorf("did not find expected key")
+		}
+		key := p.fill(p.parseProperties(), p.parseKey())
+		p.skipSpaces()
+		if p.peek() != ':' {
+			p.errorf("could not find expected ':'")
+		}
+		p.pos++
+		p.skipSpaces()
+		var value *Node
+		if p.atEOLOrComment() {
+			value = p.parseBlockNode(c, true)
+		} else {
+			value = p.parseInline(c, false, true)
+		}
+		m.Content = append(m.Content, key, value)
+		p.expectEOL()
+
+		s := p.save()
+		if !p.skipToContent() {
+			break
+		}
+		if p.col() > c {
+			p.errorf("bad indentation of a mapping entry")
+		} else if p.col() < c {
+			p.restore(s)
+			break
+		}
+	}
+	return m
+}
+
+// Parses the key of a block mapping entry. Keys must fit in one line.
+func (p *parser) parseKey() *Node {
+	line := p.firstLine + p.line
+	switch p.peek() {
+	case '"':
+		return p.scalar("!!str", p.parseDoubleQuoted(), line)
+	case '\'':
+		return p.scalar("!!str", p.parseSingleQuoted(), line)
+	case '*':
+		return p.parseAlias()
+	}
+	v := p.scanPlainLine(false)
+	return p.scalar(resolve(v), v, line)
+}
+
+// Parses a block sequence whose first entry is at the current position, which
+// is at column c.
+func (p *parser) parseBlockSequence(c int) *Node {
+	seq := &Node{Kind: SequenceNode, Tag: "!!seq", Line: p.firstLine + p.line}
+	for {
+		p.pos++ // Skip "-"
+		p.skipSpaces()
+		var item *Node
+		if p.atEOLOrComment() {
+			item = p.parseBlockNode(c, false)
+		} else {
+			item = p.parseInline(c, true, false)
+		}
+		seq.Content = append(seq.Content, item)
+		p.expectEOL()
+
+		s := p.save()
+		if !p.skipToContent() {
+			break
+		}
+		if p.col() > c {
+			p.errorf("bad indentation of a sequence entry")
+		} else if p.col() < c || !p.atSequenceIndicator() {
+			p.restore(s)
+			break
+		}
+	}
+	return seq
+}
+
+// Parses a node that is not a block collection, and whose properties have been
+// parsed.
+func (p *parser) parseBlockScalarOrFlow(n int) *Node {
+	line := p.firstLine + p.line
+	switch p.peek() {
+	case '|', '>':
+		return p.scalar("!!str", p.parseBlockScalar(n), line)
+	}
+	return p.parseFlowNode(n, false)
+}
+
+// Parses a node in flow style, or a scalar. If inFlow is true, the node is
+// inside a flow collection.
+func (p *parser) parseFlowNode(n int, inFlow bool) *Node {
+	line := p.firstLine + p.line
+	switch b := p.peek(); b {
+	case '*':
+		return p.parseAlias()
+	case '[':
+		return p.parseFlowSequence(n)
+	case '{':
+		return p.parseFlowMapping(n)
+	case '"':
+		return p.scalar("!!str", p.parseDoubleQuoted(), line)
+	case '\'':
+		return p.scalar("!!str", p.parseSingleQuoted(), line)
+	case '|', '>':
+		if inFlow {
+			p.errorf("block scalars are not allowed in flow collections")
+		}
+	case '%', '@', '`', ',', ']', '}':
+		p.errorf("found character %q that cannot start any token", b)
+	}
+	v := p.parsePlain(n, inFlow)
+	return p.scalar(resolve(v), v, line)
+}
+
+func (p *parser) parseAlias() *Node {
+	line := p.firstLine + p.line
+	p.pos++ // Skip "*"
+	name := p.scanName()
+	target, ok := p.anchors[name]
+	if !ok {
+		p.errorf("unknown anchor '%s' referenced", name)
+	}
+	return &Node{Kind: AliasNode, Value: name, Alias: target, Line: line}
+}
+
+// Skips whitespace, line breaks and comments inside flow collections.
+func (p *parser) skipFlowSpaces() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		if p.peek() != '\n' {
+			return
+		}
+		p.nextLine()
+	}
+}
+
+// Parses a node inside a flow collection, which may be empty if it has
+// properties.
+func (p *parser) parseFlowEntry(n int) *Node {
+	node := p.parseProperties()
+	p.skipFlowSpaces()
+	var content *Node
+	if node.Anchor != "" || node.Tag != "" {
+		if b := p.peek(); b == ',' || b == ']' || b == '}' || b == ':' || b == 0 {
+			content = p.null()
+		}
+	}
+	if content == nil {
+		p.checkExplicitKey()
+		content = p.parseFlowNode(n, true)
+	}
+	return p.fill(node, content)
+}
+
+func (p *parser) parseFlowSequence(n int) *Node {
+	seq := &Node{Kind: SequenceNode, Tag: "!!seq", Line: p.firstLine + p.line}
+	p.pos++ // Skip "["
+	for {
+		p.skipFlowSpaces()
+		if p.peek() == ']' {
+			p.pos++
+			return seq
+		} else if p.eof() {
+			p.errorf("did not find expected ',' or ']'")
+		}
+		item := p.parseFlowEntry(n)
+		p.skipFlowSpaces()
+		if p.peek() == ':' {
+			// A single pair, like [a: b].
+			p.pos++
+			p.skipFlowSpaces()
+			var value *Node
+			if b := p.peek(); b == ',' || b == ']' {
+				value = p.null()
+			} else {
+				value = p.parseFlowEntry(n)
+			}
+			item = &Node{Kind: MappingNode, Tag: "!!map",
+				Content: []*Node{item, value}, Line: item.Line}
+			p.skipFlowSpaces()
+		}
+		seq.Content = append(seq.Content, item)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return seq
+		default:
+			p.errorf("did not find expected ',' or ']'")
+		}
+	}
+}
+
+func (p *parser) parseFlowMapping(n int) *Node {
+	m := &Node{Kind: MappingNode, Tag: "!!map", Line: p.firstLine + p.line}
+	p.pos++ // Skip "{"
+	for {
+		p.skipFlowSpaces()
+		if p.peek() == '}' {
+			p.pos++
+			return m
+		} else if p.eof() {
+			p.errorf("did not find expected ',' or '}'")
+		}
+		key := p.parseFlowEntry(n)
+		p.skipFlowSpaces()
+		var value *Node
+		if p.peek() == ':' {
+			p.pos++
+			p.skipFlowSpaces()
+			if b := p.peek(); b == ',' || b == '}' {
+				value = p.null()
+			} else {
+				value = p.parseFlowEntry(n)
+			}
+			p.skipFlowSpaces()
+		} else {
+			value = p.null()
+		}
+		m.Content = append(m.Content, key, value)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return m
+		default:
+			p.errorf("did not find expected ',' or '}'")
+		}
+	}
+}
+
+// Scans the part of a plain scalar on the current line, and leaves the
+// position after its last non-space character.
+func (p *parser) scanPlainLine(inFlow bool) string {
+	start, end := p.pos, p.pos
+	for !p.atEOL() {
+		b := p.peek()
+		if b == ':' && (isBlank(p.peekAt(1)) || inFlow && isFlowIndicator(p.peekAt(1))) {
+			break
+		}
+		if b == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		if inFlow && isFlowIndicator(b) {
+			break
+		}
+		p.pos++
+		if b != ' ' && b != '\t' {
+			end = p.pos
+		}
+	}
+	p.pos = end
+	return p.src[start:end]
+}
+
+// Parses a plain scalar, which may continue onto subsequent lines indented
+// more than n. Lines are folded: a single line break becomes a space, and
+// empty lines become line breaks.
+func (p *parser) parsePlain(n int, inFlow bool) string {
+	var sb strings.Builder
+	sb.WriteString(p.scanPlainLine(inFlow))
+	for {
+		s := p.save()
+		p.skipSpaces()
+		breaks := 0
+		for p.peek() == '\n' {
+			p.nextLine()
+			breaks++
+			p.skipSpaces()
+		}
+		if breaks == 0 || p.eof() || p.peek() == '#' || p.col() <= n {
+			p.restore(s)
+			break
+		}
+		text := p.scanPlainLine(inFlow)
+		if text == "" {
+			p.restore(s)
+			break
+		}
+		writeFold(&sb, breaks)
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// Writes the result of folding the given number of line breaks.
+func writeFold(sb *strings.Builder, breaks int) {
+	if breaks == 1 {
+		sb.WriteByte(' ')
+	} else {
+		sb.WriteString(strings.Repeat("\n", breaks-1))
+	}
+}
+
+// Folds a line break inside a quoted scalar. The position is at the line
+// break.
+func (p *parser) foldQuoted(buf []byte) []byte {
+	breaks := 0
+	for p.peek() == '\n' {
+		p.nextLine()
+		breaks++
+		p.skipSpaces()
+	}
+	if breaks == 1 {
+		return append(buf, ' ')
+	}
+	return append(buf, strings.Repeat("\n", breaks-1)...)
+}
+
+func (p *parser) parseSingleQuoted() string {
+	p.pos++ // Skip "'"
+	var buf []byte
+	// Length of buf without trailing whitespace, which is trimmed before line
+	// breaks.
+	keep := 0
+	for {
+		switch b := p.peek(); {
+		case p.eof():
+			p.errorf("found unexpected end of stream")
+		case b == '\'' && p.peekAt(1) == '\'':
+			buf = append(buf, '\'')
+			p.pos += 2
+			keep = len(buf)
+		case b == '\'':
+			p.pos++
+			return string(buf)
+		case b == '\n':
+			buf = p.foldQuoted(buf[:keep])
+			keep = len(buf)
+		default:
+			buf = append(buf, b)
+			p.pos++
+			if b != ' ' && b != '\t' {
+				keep = len(buf)
+			}
+		}
+	}
+}
+
+var simpleEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+var hexEscapeLengths = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+func (p *parser) parseDoubleQuoted() string {
+	p.pos++ // Skip '"'
+	var buf []byte
+	keep := 0
+	for {
+		switch b := p.peek(); {
+		case p.eof():
+			p.errorf("found unexpected end of stream")
+		case b == '"':
+			p.pos++
+			return string(buf)
+		case b == '\\':
+			e := p.peekAt(1)
+			p.pos += 2
+			if s, ok := simpleEscapes[e]; ok {
+				buf = append(buf, s...)
+			} else if n, ok := hexEscapeLengths[e]; ok {
+				if p.pos+n > len(p.src) {
+					p.errorf("found unexpected end of stream")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					p.errorf("invalid escape sequence %q", p.src[p.pos-2:p.pos+n])
+				}
+				buf = utf8.AppendRune(buf, rune(r))
+				p.pos += n
+			} else if e == '\n' {
+				// An escaped line break is removed, along with the leading
+				// whitespace of the next line.
+				p.pos--
+				p.nextLine()
+				p.skipSpaces()
+				for p.peek() == '\n' {
+					p.nextLine()
+					buf = append(buf, '\n')
+					p.skipSpaces()
+				}
+			} else {
+				p.pos -= 2
+				p.errorf("found unknown escape character while parsing a quoted scalar")
+			}
+			keep = len(buf)
+		case b == '\n':
+			buf = p.foldQuoted(buf[:keep])
+			keep = len(buf)
+		default:
+			buf = append(buf, b)
+			p.pos++
+			if b != ' ' && b != '\t' {
+				keep = len(buf)
+			}
+		}
+	}
+}
+
+// Chomping indicators of block scalars.
+const (
+	clip = iota
+	strip
+	keepTrailing
+)
+
+// Parses a literal ("|") or folded (">") block scalar.
+func (p *parser) parseBlockScalar(n int) string {
+	literal := p.peek() == '|'
+	p.pos++
+	chomping, indent := clip, -1
+	for i := 0; i < 2; i++ {
+		switch b := p.peek(); {
+		case b == '-':
+			chomping = strip
+		case b == '+':
+			chomping = keepTrailing
+		case '1' <= b && b <= '9':
+			indent = max(n, 0) + int(b-'0')
+		default:
+			continue
+		}
+		p.pos++
+	}
+	p.skipSpaces()
+	p.skipComment()
+	if !p.atEOL() {
+		p.errorf("did not find expected comment or line break")
+	}
+
+	// Collect content lines with the indentation removed; empty lines are
+	// kept as "".
+	var lines []string
+	for !p.eof() {
+		s := p.save()
+		p.nextLine()
+		spaces := 0
+		for p.peekAt(spaces) == ' ' {
+			spaces++
+		}
+		lineEnd := strings.IndexByte(p.src[p.pos:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(p.src) - p.pos
+		}
+		text := p.src[p.pos : p.pos+lineEnd]
+		if spaces == len(text) {
+			if indent != -1 && spaces > indent {
+				lines = append(lines, text[indent:])
+			} else {
+				lines = append(lines, "")
+			}
+		} else {
+			if indent == -1 {
+				indent = spaces
+			}
+			if spaces < indent || indent <= n {
+				p.restore(s)
+				break
+			}
+			lines = append(lines, text[indent:])
+		}
+		p.pos += lineEnd
+	}
+
+	last := len(lines) - 1
+	for last >= 0 && lines[last] == "" {
+		last--
+	}
+	var sb strings.Builder
+	if literal {
+		sb.WriteString(strings.Join(lines[:last+1], "\n"))
+	} else {
+		// Line breaks between two lines that are not more indented are
+		// folded.
+		breaks, prevMore := 0, false
+		for i, line := range lines[:last+1] {
+			if line == "" {
+				breaks++
+				continue
+			}
+			more := line[0] == ' ' || line[0] == '\t'
+			if i > breaks && !more && !prevMore {
+				writeFold(&sb, breaks+1)
+			} else {
+				sb.WriteString(strings.Repeat("\n", breaks))
+				if i > breaks {
+					sb.WriteByte('\n')
+				}
+			}
+			sb.WriteString(line)
+			breaks, prevMore = 0, more
+		}
+	}
+	switch {
+	case last == -1:
+		if chomping == keepTrailing {
+			return strings.Repeat("\n", len(lines))
+		}
+		return ""
+	case chomping == clip:
+		sb.WriteByte('\n')
+	case chomping == keepTrailing:
+		sb.WriteString(strings.Repeat("\n", len(lines)-last))
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	intPattern   = regexp.MustCompile(`^[-+]?([0-9][0-9_]*|0x[0-9a-fA-F_]+|0o[0-7_]+|0b[01_]+)$`)
+	floatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?$|^[-+]?\.(inf|Inf|INF)$|^\.(nan|NaN|NAN)$`)
+)
+
+// Resolves the tag of a plain scalar using the core schema. Like many other
+// YAML implementations, underscores in numbers and binary integers are also
+// accepted.
+func resolve(v string) string {
+	switch v {
+	case "", "~", "null", "Null", "NULL":
+		return "!!null"
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return "!!bool"
+	case "<<":
+		return "!!merge"
+	}
+	if intPattern.MatchString(v) {
+		return "!!int"
+	} else if floatPattern.MatchString(v) {
+		return "!!float"
+	}
+	return "!!str"
+}
+
+// ScalarValue returns the value of a scalar node according to its tag, which
+// is one of nil, bool, *big.Int (since YAML doesn't limit the size of
+// integers), float64 and string. Scalars with tags outside the core schema
+// have string values.
+func (n *Node) ScalarValue() (any, error) {
+	switch n.Tag {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		switch strings.ToLower(n.Value) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	case "!!int":
+		if i, ok := parseInt(n.Value); ok {
+			return i, nil
+		}
+	case "!!float":
+		if f, ok := parseFloat(n.Value); ok {
+			return f, nil
+		}
+	default:
+		return n.Value, nil
+	}
+	return nil, &Error{n.Line, fmt.Sprintf("cannot parse %q as %s", n.Value, n.Tag)}
+}
+
+func parseInt(s string) (*big.Int, bool) {
+	s = strings.ReplaceAll(s, "_", "")
+	neg := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		neg, s = s[0] == '-', s[1:]
+	}
+	base := 10
+	if len(s) > 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 10 {
+			s = s[2:]
+		}
+	}
+	i, ok := new(big.Int).SetString(s, base)
+	if ok && neg {
+		i.Neg(i)
+	}
+	return i, ok
+}
+
+func parseFloat(s string) (float64, bool) {
+	switch strings.ToLower(strings.TrimLeft(s, "+")) {
+	case ".inf":
+		return math.Inf(1), true
+	case "-.inf":
+		return math.Inf(-1), true
+	case ".nan":
+		return math.NaN(), true
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	return f, true
+}
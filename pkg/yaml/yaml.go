@@ -0,0 +1,167 @@
+// Package yaml implements a parser and an encoder for [YAML] 1.2.
+//
+// This package is used to implement the from-yaml and to-yaml builtin commands.
+// It supports the parts of YAML commonly used in configuration files: block and
+// flow collections, all the scalar styles, anchors and aliases, tags and
+// multi-document streams. Complex mapping keys (written with "?") and %TAG
+// directives are not supported. Plain scalars are resolved using the core
+// schema.
+//
+// Like the [src.elv.sh/pkg/toml] package, this package only deals with generic
+// values; this keeps it much smaller than general-purpose YAML libraries, which
+// also map documents to Go structs, and avoids adding a dependency to Elvish.
+//
+// [YAML]: https://yaml.org/spec/1.2.2/
+package yaml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kind is the kind of a [Node].
+type Kind int
+
+// Possible values of [Kind].
+const (
+	ScalarNode Kind = iota
+	SequenceNode
+	MappingNode
+	AliasNode
+)
+
+// Node is a node in a YAML document.
+type Node struct {
+	Kind Kind
+	// Tag of the node. Tags of the core schema are written in the short form,
+	// like "!!str" and "!!int"; other tags are kept as is. Plain scalars
+	// without explicit tags are resolved using the core schema, and the key
+	// "<<" is resolved to "!!merge".
+	Tag string
+	// The value of a scalar, or the name of the anchor an alias refers to.
+	Value string
+	// The name of the anchor of the node, if it has one.
+	Anchor string
+	// Elements of a sequence, or keys and values of a mapping, alternating.
+	Content []*Node
+	// The node an alias refers to.
+	Alias *Node
+	// 1-based line number of the node.
+	Line int
+}
+
+// Error is an error encountered when parsing YAML.
+type Error struct {
+	// 1-based line number.
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("yaml: line %d: %s", e.Line, e.Message)
+}
+
+// Decoder reads a stream of YAML documents.
+type Decoder struct {
+	r *bufio.Reader
+	// Number of lines read so far.
+	line int
+	// The rest of a "---" line that starts the next document, and its line
+	// number, if it has been read.
+	pending     *string
+	pendingLine int
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode parses the next document in the stream and returns its root node. An
+// empty document has a null scalar as its root node. It returns [io.EOF] when
+// there are no more documents.
+//
+// Since a document can only end with a document marker ("---" or "...") or the
+// end of the stream, which can't appear anywhere else, documents are split
+// before being parsed, and each document is returned as soon as it is read.
+func (d *Decoder) Decode() (*Node, error) {
+	var lines []string
+	firstLine := d.line + 1
+	// Whether the document is started explicitly with "---", and whether it
+	// has any content. Empty documents are only returned if they are
+	// explicit.
+	explicit, hasContent := false, false
+	if d.pending != nil {
+		lines = append(lines, *d.pending)
+		firstLine = d.pendingLine
+		explicit, hasContent = true, !isBlankOrComment(*d.pending)
+		d.pending = nil
+	}
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			if explicit || hasContent {
+				return parseDocument(lines, firstLine)
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case isDocumentMarker(line, "---"):
+			// Replace the marker with spaces, so that columns are kept.
+			rest := "   " + line[3:]
+			if explicit || hasContent {
+				d.pending, d.pendingLine = &rest, d.line
+				return parseDocument(lines, firstLine)
+			}
+			lines, firstLine = []string{rest}, d.line
+			explicit, hasContent = true, !isBlankOrComment(rest)
+		case isDocumentMarker(line, "..."):
+			if explicit || hasContent {
+				return parseDocument(lines, firstLine)
+			}
+			lines, firstLine = nil, d.line+1
+		case strings.HasPrefix(line, "%") && !explicit && !hasContent:
+			if strings.HasPrefix(line, "%TAG") {
+				return nil, &Error{d.line, "%TAG directives are not supported"}
+			}
+			// Other directives, like %YAML, are ignored.
+		default:
+			if len(lines) == 0 {
+				firstLine = d.line
+			}
+			lines = append(lines, line)
+			hasContent = hasContent || !isBlankOrComment(line)
+		}
+	}
+}
+
+// Reads a line, stripping the line terminator.
+func (d *Decoder) readLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	d.line++
+	if d.line == 1 {
+		line = strings.TrimPrefix(line, "\ufeff")
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func isDocumentMarker(line, marker string) bool {
+	return strings.HasPrefix(line, marker) &&
+		(len(line) == len(marker) || line[len(marker)] == ' ' || line[len(marker)] == '\t')
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimLeft(line, " \t")
+	return line == "" || line[0] == '#'
+}
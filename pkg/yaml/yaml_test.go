@@ -0,0 +1,206 @@
+package yaml
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var Args = tt.Args
+
+type m = map[string]any
+type l = []any
+
+// Decodes all the documents in s into generic values, with aliases replaced by
+// the values of their anchors and big integers converted to int.
+func decodeAll(s string) ([]any, error) {
+	dec := NewDecoder(strings.NewReader(s))
+	var docs []any
+	for {
+		node, err := dec.Decode()
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return docs, err
+		}
+		v, err := value(node)
+		if err != nil {
+			return docs, err
+		}
+		docs = append(docs, v)
+	}
+}
+
+func value(node *Node) (any, error) {
+	switch node.Kind {
+	case AliasNode:
+		return value(node.Alias)
+	case SequenceNode:
+		s := l{}
+		for _, elem := range node.Content {
+			v, err := value(elem)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	case MappingNode:
+		mv := m{}
+		for i := 0; i < len(node.Content); i += 2 {
+			k, err := value(node.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := value(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			mv[k.(string)] = v
+		}
+		return mv, nil
+	}
+	v, err := node.ScalarValue()
+	if i, ok := v.(*big.Int); ok && i.IsInt64() {
+		return int(i.Int64()), nil
+	}
+	return v, err
+}
+
+func TestDecode(t *testing.T) {
+	tt.Test(t, decodeAll,
+		// Streams and documents.
+		Args("").Rets([]any(nil), nil),
+		Args("# comment\n").Rets([]any(nil), nil),
+		Args("a\n---\n[b]\n---\n").Rets(l{"a", l{"b"}, nil}, nil),
+		Args("%YAML 1.2\n--- a\n...\n--- b").Rets(l{"a", "b"}, nil),
+		Args("\ufeffa\r\n").Rets(l{"a"}, nil),
+
+		// Plain scalars and resolution.
+		Args("[~, null, true, False, 42, -0x1F, 0o17, 0b101, 1_000, 4.5, -1e3, .inf, -.Inf, x, 1.2.3, <<]").Rets(
+			l{l{nil, nil, true, false, 42, -31, 15, 5, 1000, 4.5, -1e3,
+				math.Inf(1), math.Inf(-1), "x", "1.2.3", "<<"}}, nil),
+		Args("a b\n  c\n\n  d # comment").Rets(l{"a b c\nd"}, nil),
+		Args("a: http://x.com/#y").Rets(l{m{"a": "http://x.com/#y"}}, nil),
+
+		// Quoted scalars.
+		Args(`['a''b', "\"\\\t\x41\u00e9\U0001F600\N"]`).Rets(
+			l{l{"a'b", "\"\\\tAé😀\u0085"}}, nil),
+		Args("\"a  \n  b\n\n  c \\\n  d\"").Rets(l{"a b\nc d"}, nil),
+		Args("'a\n\n  b'").Rets(l{"a\nb"}, nil),
+
+		// Block scalars.
+		Args("a: |\n  x\n   y\n\n  z\n\nb: 1").Rets(
+			l{m{"a": "x\n y\n\nz\n", "b": 1}}, nil),
+		Args("- |-\n  x\n\n- |+\n  x\n\n- >\n  x\n  y\n\n  z\n    w\n  v\n").Rets(
+			l{l{"x", "x\n\n", "x y\nz\n  w\nv\n"}}, nil),
+		Args("a: |2\n    x\n").Rets(l{m{"a": "  x\n"}}, nil),
+		Args("--- |\nx\n").Rets(l{"x\n"}, nil),
+
+		// Block collections.
+		Args("a:\n  b: 1\n  c:\n  - x\n  - y\nd:\n- - z\n  - w\n- e: 2\n  f: 3\n-\n").Rets(
+			l{m{
+				"a": m{"b": 1, "c": l{"x", "y"}},
+				"d": l{l{"z", "w"}, m{"e": 2, "f": 3}, nil}}}, nil),
+		Args("'a b': 1\n\"c\": 2\n? d\n").Rets(
+			[]any(nil), &Error{3, "complex mapping keys are not supported"}),
+
+		// Flow collections.
+		Args("{a: [1, 'x', {b: c}], d: , e, \"f\":g}").Rets(
+			l{m{"a": l{1, "x", m{"b": "c"}}, "d": nil, "e": nil, "f": "g"}}, nil),
+		Args("[a: b, c\n  d,\n  ]").Rets(l{l{m{"a": "b"}, "c d"}}, nil),
+
+		// Anchors, aliases and tags.
+		Args("a: &x [1]\nb: *x\nc: &y\n  d: 1\ne: *y").Rets(
+			l{m{"a": l{1}, "b": l{1}, "c": m{"d": 1}, "e": m{"d": 1}}}, nil),
+		Args("- !!str 1\n- ! 2\n- !!int '3'\n- !<tag:yaml.org,2002:float> 4\n- !foo 5\n- !!str").Rets(
+			l{l{"1", "2", 3, 4.0, "5", ""}}, nil),
+		Args("- &a !!str 1\n- !!str &b 2\n- [*a, *b]").Rets(l{l{"1", "2", l{"1", "2"}}}, nil),
+		Args("[&a [x], &b {y: z}, !!str &c, *a, *b, *c]").Rets(
+			l{l{l{"x"}, m{"y": "z"}, "", l{"x"}, m{"y": "z"}, ""}}, nil),
+
+		// Errors.
+		Args("[a").Rets([]any(nil), &Error{1, "did not find expected ',' or ']'"}),
+		Args("{a").Rets([]any(nil), &Error{1, "did not find expected ',' or '}'"}),
+		Args("a: b: c").Rets([]any(nil), &Error{1, "mapping values are not allowed in this context"}),
+		Args("a\nb: c").Rets([]any(nil), &Error{2, "mapping values are not allowed in this context"}),
+		Args("a: - b").Rets([]any(nil), &Error{1, "block sequence entries are not allowed in this context"}),
+		Args("a: 1\n  b: 2").Rets([]any(nil), &Error{2, "mapping values are not allowed in this context"}),
+		Args("a: 'x'\n  b").Rets([]any(nil), &Error{2, "bad indentation of a mapping entry"}),
+		Args("- 'x'\n  - y").Rets([]any(nil), &Error{2, "bad indentation of a sequence entry"}),
+		Args("a: 1\n- b").Rets([]any(nil), &Error{2, "did not find expected key"}),
+		Args("[a]\n[b]").Rets([]any(nil), &Error{2, "did not find expected <document start>"}),
+		Args("*x").Rets([]any(nil), &Error{1, "unknown anchor 'x' referenced"}),
+		Args("a\n---\n'x").Rets(l{"a"}, &Error{3, "found unexpected end of stream"}),
+		Args(`"\q"`).Rets([]any(nil), &Error{1, "found unknown escape character while parsing a quoted scalar"}),
+		Args("@").Rets([]any(nil), &Error{1, "found character '@' that cannot start any token"}),
+		Args("!!int x").Rets([]any(nil), &Error{1, `cannot parse "x" as !!int`}),
+		Args("%TAG ! tag:x,2000:\n--- a").Rets([]any(nil), &Error{1, "%TAG directives are not supported"}),
+		Args("a: '\xff'").Rets([]any(nil), &Error{1, "invalid UTF-8"}),
+	)
+}
+
+func TestDecode_MergeKeyAndNaN(t *testing.T) {
+	node, err := NewDecoder(strings.NewReader("<<: .nan")).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag := node.Content[0].Tag; tag != "!!merge" {
+		t.Errorf("got tag %q for <<, want !!merge", tag)
+	}
+	if v, _ := node.Content[1].ScalarValue(); !math.IsNaN(v.(float64)) {
+		t.Errorf("got %v for .nan, want NaN", v)
+	}
+}
+
+func TestDecode_RecursiveAlias(t *testing.T) {
+	node, err := NewDecoder(strings.NewReader("&x [*x]")).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Content[0].Alias != node {
+		t.Errorf("alias doesn't refer to the node containing it")
+	}
+}
+
+func encode(v any) (string, error) {
+	var sb strings.Builder
+	err := Encode(&sb, v)
+	return sb.String(), err
+}
+
+func TestEncode(t *testing.T) {
+	tt.Test(t, encode,
+		// Scalars.
+		Args(nil).Rets("null\n", nil),
+		Args(l{true, 1, big.NewInt(2), 2.5, 1.0, 1e21, math.Inf(-1), math.NaN()}).Rets(
+			"- true\n- 1\n- 2\n- 2.5\n- 1.0\n- 1e+21\n- -.inf\n- .nan\n", nil),
+		Args(l{"a b", "", "1", "true", "null", "yes", "- a", "a: b", "a #b", "a:",
+			" a", "...", "\"\t\x01\u0085"}).Rets(
+			"- a b\n- \"\"\n- \"1\"\n- \"true\"\n- \"null\"\n- \"yes\"\n- \"- a\"\n"+
+				"- \"a: b\"\n- \"a #b\"\n- \"a:\"\n- \" a\"\n- \"...\"\n"+
+				"- \"\\\"\\t\\x01\\x85\"\n", nil),
+		// Literal block scalars.
+		Args(m{"a": "x\ny", "b": "x\n", "c": "x\n\n", "d": "\n x"}).Rets(
+			"a: |-\n  x\n  y\nb: |\n  x\nc: |+\n  x\n\nd: \"\\n x\"\n", nil),
+		// Collections.
+		Args(m{"b": l{m{"c": 1, "d": l{}}, l{"x", "z"}}, "a": m{}, "e: f": m{"g": nil}}).Rets(
+			"a: {}\nb:\n  - c: 1\n    d: []\n  - - x\n    - z\n\"e: f\":\n  g: null\n", nil),
+
+		// Errors.
+		Args(struct{}{}).Rets("", tt.Any),
+	)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	v := m{"a": l{"x\n  y\n", m{"b": 1.5, "c": l{l{}, "true"}}}, "d": "e: f"}
+	s, err := encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.Test(t, decodeAll, Args(s).Rets(l{v}, nil))
+}